@@ -0,0 +1,142 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// AddOption registers the option declared by the field named field of i, a
+// pointer to an options structure, with set.  AddOption is used to add options
+// to a set after it has been registered, such as options contributed by a
+// plugin.  The field is registered exactly as RegisterSet would register it.
+// Values for the option already read by a Flags that sets options in set are
+// applied to it, just as if the option had been registered before the flags
+// file was read.
+//
+//	if err := options.AddOption(set, &pluginOptions, "Level"); err != nil {
+//		...
+//	}
+//
+// An error is returned if i does not have the field, the field does not
+// declare an option, or the option is already in set.
+func AddOption(set *getopt.Set, i interface{}, field string) error {
+	before := optionSet(set)
+	if err := register("", i, set, &regConfig{field: field}); err != nil {
+		return err
+	}
+	added := addedOptions(set, before)
+	if len(added) == 0 {
+		return fmt.Errorf("%T: field %s does not declare an option", i, field)
+	}
+	return replayFlags(set, added)
+}
+
+// AddValue adds the option name, a long name unless it is a single character,
+// with the value value and help text help to set.  As with AddOption, values
+// already read by a Flags that sets options in set are applied to it.  Call
+// SetFlag on the returned option if it does not take a parameter.
+func AddValue(set *getopt.Set, name string, value getopt.Value, help string) (getopt.Option, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty option name")
+	}
+	if lookupName(set, name) != nil {
+		return nil, fmt.Errorf("option %s already defined", dashed(name))
+	}
+	var long string
+	var short rune
+	if utf8.RuneCountInString(name) == 1 {
+		short, _ = utf8.DecodeRuneInString(name)
+	} else {
+		long = name
+	}
+	opt := set.FlagLong(value, long, short, help)
+	setOptionInfo(opt, help, opt.String())
+	return opt, replayFlags(set, []getopt.Option{opt})
+}
+
+// optionSet returns the options currently in set.
+func optionSet(set *getopt.Set) map[getopt.Option]bool {
+	m := map[getopt.Option]bool{}
+	set.VisitAll(func(o getopt.Option) { m[o] = true })
+	return m
+}
+
+// addedOptions returns the options in set that are not in before.
+func addedOptions(set *getopt.Set, before map[getopt.Option]bool) []getopt.Option {
+	var added []getopt.Option
+	set.VisitAll(func(o getopt.Option) {
+		if !before[o] {
+			added = append(added, o)
+		}
+	})
+	return added
+}
+
+// replayFlags sets the options in opts, which were just added to set, from
+// the values previously read by each Flags that sets the options in set.
+// These are the Flags registered in set, the Flags that attach new sets, and
+// the Flags holding values for sets that were not yet registered.
+func replayFlags(set *getopt.Set, opts []getopt.Option) error {
+	var fs []*Flags
+	set.VisitAll(func(o getopt.Option) {
+		if f, ok := o.Value().(*Flags); ok && !containsFlags(fs, f) {
+			fs = append(fs, f)
+		}
+	})
+	attachMu.Lock()
+	for _, f := range attached {
+		if !containsFlags(fs, f) {
+			fs = append(fs, f)
+		}
+	}
+	for f := range pending {
+		if !containsFlags(fs, f) {
+			fs = append(fs, f)
+		}
+	}
+	attachMu.Unlock()
+
+	// Only the new options are set, so they are put in a set of their own.
+	added := getopt.New()
+	for _, o := range opts {
+		added.AddOption(o)
+	}
+	for _, f := range fs {
+		if err := f.replay(set, added); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay sets the options in added, which were added to set, from the values
+// previously read by f, if f sets the options in set.
+func (f *Flags) replay(set, added *getopt.Set) error {
+	f.lock()
+	defer f.unlockNotify()
+	if f.m == nil || f.opt == nil {
+		return nil
+	}
+	for _, s := range f.allSets() {
+		if s.Set == set {
+			return f.set(context.Background(), attachFlags, nil, []Set{{Name: s.Name, Set: added}})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestAddOption(t *testing.T) {
+	opts := &struct {
+		Flags Flags  `getopt:"--flags=PATH flags file" flags:"ignore-unknown"`
+		Name  string `getopt:"--name=NAME name"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"cmd", "--flags", "data:name=bob%0Alevel=3%0Amode=fast", "--level=4"}); err == nil {
+		t.Fatal("--level was accepted before it was added")
+	}
+	if _, err := SubParse(set, []string{"cmd", "--flags", "data:name=bob%0Alevel=3%0Amode=fast"}); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := &struct {
+		Level int    `getopt:"--level=N level"`
+		Other string `getopt:"--other"`
+		Skip  string `getopt:"-"`
+	}{}
+	if err := AddOption(set, plugin, "Level"); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.Level != 3 {
+		t.Errorf("got level %d, want 3", plugin.Level)
+	}
+	if lookup(set, "other") != nil {
+		t.Errorf("--other was added")
+	}
+	if _, err := SubParse(set, []string{"cmd", "--level=4"}); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.Level != 4 {
+		t.Errorf("got level %d, want 4", plugin.Level)
+	}
+
+	for _, tt := range []struct {
+		field, err string
+	}{
+		{"Missing", "has no field Missing"},
+		{"Skip", "does not declare an option"},
+		{"Level", "--level"},
+	} {
+		if err := AddOption(set, plugin, tt.field); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.field, err, tt.err)
+		}
+	}
+
+	var mode string
+	if _, err := AddValue(set, "mode", getopt.New().FlagLong(&mode, "mode", 0).Value(), "the mode"); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "fast" {
+		t.Errorf("got mode %q, want fast", mode)
+	}
+	if _, err := AddValue(set, "mode", getopt.New().FlagLong(&mode, "mode", 0).Value(), "the mode"); err == nil {
+		t.Errorf("adding --mode twice did not fail")
+	}
+}
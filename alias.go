@@ -6,8 +6,9 @@ import (
 	"github.com/pborman/getopt/v2"
 )
 
-// PrintUsage calls PrintUsage in the default option set.
-func PrintUsage(w io.Writer) { getopt.PrintUsage(w) }
+// PrintUsage calls PrintUsage in the default option set.  Options disabled by
+// DisableOption are not displayed.
+func PrintUsage(w io.Writer) { printSetUsage(w, getopt.CommandLine, false) }
 
 // Usage calls the usage function in the default option set.
 func Usage() { getopt.Usage() }
@@ -30,8 +31,13 @@ func SetUsage(usage func()) {
 	getopt.SetUsage(usage)
 }
 
-// SetDisplayWidth sets the width of the display when printing usage.  It defaults to 80.
+// SetDisplayWidth sets the width of the display when printing usage.  By
+// default the width is set the first time options are parsed to the value of
+// the COLUMNS environment variable or, if it is not set, the width of the
+// terminal standard error is connected to.  If neither is available the width
+// is 80.  Calling SetDisplayWidth disables this detection.
 func SetDisplayWidth(w int) {
+	displayWidthSet = true
 	getopt.DisplayWidth = w
 }
 
@@ -9,9 +9,9 @@ import (
 )
 
 func TestSetVar(t *testing.T) {
-	dw, hc, cl := getopt.DisplayWidth, getopt.HelpColumn, getopt.CommandLine
+	dw, hc, cl, dws := getopt.DisplayWidth, getopt.HelpColumn, getopt.CommandLine, displayWidthSet
 	defer func() {
-		getopt.DisplayWidth, getopt.HelpColumn, getopt.CommandLine = dw, hc, cl
+		getopt.DisplayWidth, getopt.HelpColumn, getopt.CommandLine, displayWidthSet = dw, hc, cl, dws
 	}()
 	SetDisplayWidth(42)
 	SetHelpColumn(17)
@@ -0,0 +1,159 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package analyzer statically checks the option structures used with the
+// github.com/pborman/options package.  It reports problems that would
+// otherwise only be found when the program registers its options: malformed
+// tags, options declared more than once, fields of types that cannot be
+// options, and options without help text.
+//
+// Any struct type with at least one getopt tag is treated as an option
+// structure.  Field types are checked syntactically, so only types that can
+// never be options (channels, functions, interfaces, arrays, anonymous
+// structures, and unsupported maps) are reported.
+//
+// Analyzer is a golang.org/x/tools/go/analysis Analyzer.  The optionsvet
+// command runs it, either directly or from go vet:
+//
+//	optionsvet ./...
+//	go vet -vettool=$(which optionsvet) ./...
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+
+	"github.com/pborman/options/tag"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer reports problems with the option structures declared in a package.
+var Analyzer = &analysis.Analyzer{
+	Name:     "options",
+	Doc:      "check the getopt tags of option structures",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	in.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		if st := n.(*ast.StructType); isOptions(st) {
+			checkStruct(pass, st)
+		}
+	})
+	return nil, nil
+}
+
+// isOptions reports if any field of st has a getopt tag.
+func isOptions(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if _, ok := structTag(field).Lookup("getopt"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// structTag returns the tag of field.
+func structTag(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	s, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(s)
+}
+
+// checkStruct reports the problems with the options declared by st.
+func checkStruct(pass *analysis.Pass, st *ast.StructType) {
+	declared := map[string]string{} // option name to field name
+	for _, field := range st.Fields.List {
+		stag := structTag(field)
+		for _, name := range field.Names {
+			sf := reflect.StructField{Name: name.Name, Tag: stag}
+			if !name.IsExported() {
+				sf.PkgPath = "-"
+			}
+			spec, err := tag.FieldSpec(sf)
+			if err != nil {
+				pass.Reportf(name.Pos(), "%v", err)
+				continue
+			}
+			if spec == nil {
+				continue
+			}
+			for _, opt := range optionNames(spec) {
+				if other, ok := declared[opt]; ok {
+					pass.Reportf(name.Pos(), "option %s declared by both %s and %s", opt, other, name.Name)
+				}
+				declared[opt] = name.Name
+			}
+			if reason := unsupported(field.Type); reason != "" {
+				pass.Reportf(field.Type.Pos(), "%s: unsupported option type %s: %s", name.Name, types.ExprString(field.Type), reason)
+			}
+			if _, ok := stag.Lookup("helpvar"); spec.Help == "" && !ok {
+				pass.Reportf(name.Pos(), "%s: option %s has no help text", name.Name, optionNames(spec)[0])
+			}
+		}
+	}
+}
+
+// optionNames returns the names of the option described by spec, with leading
+// dashes.
+func optionNames(spec *tag.Spec) []string {
+	var names []string
+	if spec.Long != "" {
+		names = append(names, "--"+spec.Long)
+	}
+	if spec.Short != 0 {
+		names = append(names, "-"+string(spec.Short))
+	}
+	return names
+}
+
+// unsupported returns why a field of type expr can never be an option, or ""
+// if it might be.  Named types are assumed to be supported as they may
+// implement getopt.Value or encoding.TextUnmarshaler.
+func unsupported(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return unsupported(t.X)
+	case *ast.ChanType:
+		return "channels cannot be options"
+	case *ast.FuncType:
+		return "functions cannot be options"
+	case *ast.InterfaceType:
+		return "interfaces cannot be options"
+	case *ast.StructType:
+		return "anonymous structures cannot be options"
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return "arrays cannot be options"
+		}
+		return unsupported(t.Elt)
+	case *ast.MapType:
+		k, kok := t.Key.(*ast.Ident)
+		v, vok := t.Value.(*ast.Ident)
+		if !kok || !vok || k.Name != "string" || (v.Name != "string" && v.Name != "int" && v.Name != "bool") {
+			return "only map[string]string, map[string]int, and map[string]bool are supported"
+		}
+	}
+	return ""
+}
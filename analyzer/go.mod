@@ -0,0 +1,16 @@
+module github.com/pborman/options/analyzer
+
+go 1.22.0
+
+require (
+	github.com/pborman/options v0.0.0
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
+
+// The analyzer parses tags with the tag package of the enclosing module.
+replace github.com/pborman/options => ../
@@ -0,0 +1,22 @@
+package p
+
+type good struct {
+	Name    string            `getopt:"--name=NAME name of the widget"`
+	Count   int               `getopt:"--count -c=N number of widgets"`
+	Labels  map[string]string `getopt:"--label=KEY=VALUE labels"`
+	Timeout int               `getopt:"--timeout" helpvar:"helpTimeout"`
+	ignored chan int
+}
+
+type bad struct {
+	Name    string      `getopt:"--name=NAME name"`
+	Other   string      `getopt:"--name=NAME other name"` // want `option --name declared by both Name and Other`
+	Ch      chan int    `getopt:"--ch a channel"`         // want `Ch: unsupported option type chan int: channels cannot be options`
+	Bad     int         `getopt:"--bad=X bad" param:"Y"`  // want `Bad: getopt tag declares the parameter name twice`
+	Quiet   bool        `getopt:"--quiet"`                // want `Quiet: option --quiet has no help text`
+	Weights map[int]int `getopt:"--weights weights"`      // want `Weights: unsupported option type map\[int\]int: only map\[string\]string, map\[string\]int, and map\[string\]bool are supported`
+}
+
+type notOptions struct {
+	C chan int
+}
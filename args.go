@@ -0,0 +1,243 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/tag"
+)
+
+// An argsSpec is the number of positional arguments permitted by a set and the
+// fields, if any, that receive them.
+type argsSpec struct {
+	counted   bool          // min and max have been set
+	min, max  int           // max is -1 if there is no maximum
+	fv        reflect.Value // the args field, if valid
+	dash      reflect.Value // the dashdash field, if valid
+	param     string        // name of the arguments in the usage
+	dashParam string        // name of the arguments after "--" in the usage
+}
+
+// defaultParameters is the parameters string of a new getopt.Set.
+var defaultParameters = getopt.New().Parameters()
+
+// A dashSplit is the positional arguments of a set divided at "--".
+type dashSplit struct {
+	params []string // the arguments before "--"
+	dashed []string // the arguments after "--"
+}
+
+var (
+	argsMu    sync.Mutex
+	setArgsN  = map[*getopt.Set]argsSpec{}
+	setDashed = map[*getopt.Set]dashSplit{} // set by getoptArgs
+)
+
+// SetArgs sets the number of positional arguments, the arguments remaining
+// after the options, that set accepts to at least min and at most max.  A max
+// less than 0 means there is no maximum.  The number of arguments is checked
+// once set has been parsed and an ArgsError is returned if it is out of range.
+// RegisterAndParse and Parse write the error and the usage to standard error
+// and exit the program.
+//
+//	options.SetArgs(getopt.CommandLine, 2, 2) // a source and destination
+//
+// The count can also be declared by an args tag on a []string field of the
+// options structure, which receives the arguments after parsing.  The field is
+// not an option and must be tagged getopt:"-".  The args tag is either a
+// single count, a range such as "1-3", or a minimum followed by a dash, such as
+// "1-", meaning there is no maximum:
+//
+//	var myOptions = struct {
+//		Verbose bool     `getopt:"-v be verbose"`
+//		Files   []string `getopt:"-" args:"1-"`
+//	}{}
+//
+// A []string field with a dashdash tag, also tagged getopt:"-", receives the
+// arguments following the first "--" (see ParseResult).  If the structure has
+// a dashdash field then only the arguments before the "--" are counted and
+// assigned to the args field:
+//
+//	var myOptions = struct {
+//		Timeout time.Duration `getopt:"--timeout=DURATION time limit"`
+//		Inputs  []string      `getopt:"-" args:"1-"`
+//		Command []string      `getopt:"-" dashdash:""`
+//	}{}
+//
+// Unless the parameters string of the set has already been changed with
+// SetParameters, registering a structure with an args or dashdash field sets
+// it to describe the arguments, e.g., "INPUTS [INPUTS ...] [-- COMMAND ...]"
+// for the structure above.  The arguments are named by the param tag of the
+// field, if present, or else by the name of the field in upper case:
+//
+//	Files []string `getopt:"-" args:"1-" param:"FILE"` // FILE [FILE ...]
+func SetArgs(set *getopt.Set, min, max int) {
+	if max < 0 {
+		max = -1
+	}
+	argsMu.Lock()
+	spec := setArgsN[set]
+	spec.counted, spec.min, spec.max = true, min, max
+	setArgsN[set] = spec
+	argsMu.Unlock()
+}
+
+// field records field, whose value is fv, in a if it is an args or a dashdash
+// field.  It reports if field is either, in which case field is not an option.
+func (a *argsSpec) field(field reflect.StructField, fv reflect.Value) (bool, error) {
+	min, max, isArgs, err := tag.Args(field.Tag)
+	_, isDash := field.Tag.Lookup("dashdash")
+	if !isArgs && !isDash {
+		return false, nil
+	}
+	kind := "args"
+	if isDash {
+		kind = "dashdash"
+	}
+	switch {
+	case err != nil:
+		return true, fmt.Errorf("%s: %w", field.Name, err)
+	case isArgs && isDash:
+		return true, fmt.Errorf("%s: field has both args and dashdash tags", field.Name)
+	case field.Tag.Get("getopt") != "-":
+		return true, fmt.Errorf(`%s: %s field must be tagged getopt:"-"`, field.Name, kind)
+	case !fv.CanSet() || field.Type != reflect.TypeOf([]string(nil)):
+		return true, fmt.Errorf("%s: %s field must be an exported []string", field.Name, kind)
+	case (isArgs && a.fv.IsValid()) || (isDash && a.dash.IsValid()):
+		return true, fmt.Errorf("%s: more than one %s field", field.Name, kind)
+	}
+	param := field.Tag.Get("param")
+	if param == "" {
+		param = strings.ToUpper(field.Name)
+	}
+	if isDash {
+		a.dash, a.dashParam = fv, param
+	} else {
+		a.counted, a.min, a.max, a.fv, a.param = true, min, max, fv, param
+	}
+	return true, nil
+}
+
+// parameters returns the parameters string describing the arguments of a.
+func (a *argsSpec) parameters() string {
+	var parts []string
+	switch {
+	case !a.fv.IsValid():
+		parts = append(parts, defaultParameters)
+	default:
+		for n := 0; n < a.min; n++ {
+			parts = append(parts, a.param)
+		}
+		switch {
+		case a.max == a.min:
+		case a.max == a.min+1:
+			parts = append(parts, "["+a.param+"]")
+		default:
+			parts = append(parts, "["+a.param+" ...]")
+		}
+	}
+	if a.dash.IsValid() {
+		parts = append(parts, "[-- "+a.dashParam+" ...]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// setArgsFields records the fields of spec that receive the arguments of set.
+// The count set by SetArgs is kept unless spec has an args field.
+func setArgsFields(set *getopt.Set, spec argsSpec) {
+	if !spec.fv.IsValid() && !spec.dash.IsValid() {
+		return
+	}
+	argsMu.Lock()
+	defer argsMu.Unlock()
+	old := setArgsN[set]
+	if !spec.counted {
+		spec.counted, spec.min, spec.max = old.counted, old.min, old.max
+	}
+	if !spec.dash.IsValid() {
+		spec.dash, spec.dashParam = old.dash, old.dashParam
+	}
+	setArgsN[set] = spec
+	if set.Parameters() == defaultParameters {
+		set.SetParameters(spec.parameters())
+	}
+}
+
+// setDashSplit records that the positional arguments of set, which was just
+// parsed, are params followed by "--" and then dashed.  found is false if
+// there was no "--", in which case dashed is ignored.
+func setDashSplit(set *getopt.Set, params, dashed []string, found bool) {
+	argsMu.Lock()
+	defer argsMu.Unlock()
+	if !found {
+		delete(setDashed, set)
+		return
+	}
+	if dashed == nil {
+		dashed = []string{}
+	}
+	setDashed[set] = dashSplit{params: params, dashed: dashed}
+}
+
+// splitDash returns the positional arguments of set, which has been parsed,
+// that come before and after "--".  dashed is nil if there was no "--".
+func splitDash(set *getopt.Set) (params, dashed []string) {
+	argsMu.Lock()
+	s, ok := setDashed[set]
+	argsMu.Unlock()
+	if !ok {
+		return set.Args(), nil
+	}
+	return s.params, s.dashed
+}
+
+// checkArgs returns an ArgsError if the number of arguments remaining in set,
+// which has been parsed, is not permitted by SetArgs.  Otherwise the arguments
+// are assigned to the args and dashdash fields of set, if there are any.
+func checkArgs(set *getopt.Set) error {
+	argsMu.Lock()
+	spec, ok := setArgsN[set]
+	argsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	args := set.Args()
+	var dashed []string
+	if spec.dash.IsValid() {
+		args, dashed = splitDash(set)
+	}
+	if spec.counted && (len(args) < spec.min || (spec.max >= 0 && len(args) > spec.max)) {
+		return &ArgsError{Min: spec.min, Max: spec.max, Got: len(args)}
+	}
+	if spec.fv.IsValid() {
+		spec.fv.Set(reflect.ValueOf(append([]string(nil), args...)))
+	}
+	if spec.dash.IsValid() {
+		spec.dash.Set(reflect.ValueOf(append([]string(nil), dashed...)))
+	}
+	return nil
+}
+
+// plural returns n followed by noun, made plural if n is not 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
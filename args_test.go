@@ -0,0 +1,211 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestArgsTag(t *testing.T) {
+	type opts struct {
+		Verbose bool     `getopt:"-v be verbose"`
+		Files   []string `getopt:"-" args:"1-2"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+		err  string
+	}{
+		{args: []string{"cmd", "a"}, want: []string{"a"}},
+		{args: []string{"cmd", "-v", "a", "b"}, want: []string{"a", "b"}},
+		{args: []string{"cmd", "-v"}, err: "expected 1 to 2 arguments, got 0"},
+		{args: []string{"cmd", "a", "b", "c"}, err: "expected 1 to 2 arguments, got 3"},
+	} {
+		var o opts
+		_, err := SubRegisterAndParse(&o, tt.args)
+		if tt.err != "" {
+			var ae *ArgsError
+			if !errors.As(err, &ae) || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %s", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+		} else if !reflect.DeepEqual(o.Files, tt.want) {
+			t.Errorf("%q: got files %q, want %q", tt.args, o.Files, tt.want)
+		}
+	}
+
+	for _, i := range []interface{}{
+		&struct {
+			Files []string `args:"1"`
+		}{},
+		&struct {
+			Files string `getopt:"-" args:"1"`
+		}{},
+		&struct {
+			Files []string `getopt:"-" args:"one"`
+		}{},
+		&struct {
+			A []string `getopt:"-" args:"1"`
+			B []string `getopt:"-" args:"1"`
+		}{},
+	} {
+		if err := Validate(i); err == nil {
+			t.Errorf("%T: did not get an error", i)
+		}
+	}
+}
+
+func TestArgsParameters(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts interface{}
+		set  string // parameters set before registering
+		want string
+	}{{
+		name: "default",
+		opts: &struct {
+			Name string `getopt:"--name=NAME name"`
+		}{},
+		want: "[parameters ...]",
+	}, {
+		name: "exact",
+		opts: &struct {
+			Files []string `getopt:"-" args:"2" param:"FILE"`
+		}{},
+		want: "FILE FILE",
+	}, {
+		name: "optional",
+		opts: &struct {
+			Files []string `getopt:"-" args:"0-1" param:"FILE"`
+		}{},
+		want: "[FILE]",
+	}, {
+		name: "unbounded",
+		opts: &struct {
+			Inputs  []string `getopt:"-" args:"1-"`
+			Command []string `getopt:"-" dashdash:""`
+		}{},
+		want: "INPUTS [INPUTS ...] [-- COMMAND ...]",
+	}, {
+		name: "none",
+		opts: &struct {
+			Files []string `getopt:"-" args:"0"`
+		}{},
+		want: "",
+	}, {
+		name: "dashdash only",
+		opts: &struct {
+			Command []string `getopt:"-" dashdash:"" param:"CMD"`
+		}{},
+		want: "[parameters ...] [-- CMD ...]",
+	}, {
+		name: "explicit",
+		opts: &struct {
+			Files []string `getopt:"-" args:"1-" param:"FILE"`
+		}{},
+		set:  "SRC... DST",
+		want: "SRC... DST",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			set := getopt.New()
+			if tt.set != "" {
+				set.SetParameters(tt.set)
+			}
+			if err := RegisterSet("", tt.opts, set); err != nil {
+				t.Fatal(err)
+			}
+			if got := set.Parameters(); got != tt.want {
+				t.Errorf("got parameters %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetArgs(t *testing.T) {
+	for _, tt := range []struct {
+		min, max int
+		n        int
+		err      string
+	}{
+		{min: 2, max: 2, n: 2},
+		{min: 2, max: 2, n: 1, err: "expected 2 arguments, got 1"},
+		{min: 1, max: 1, n: 0, err: "expected 1 argument, got 0"},
+		{min: 1, max: -1, n: 5},
+		{min: 1, max: -1, n: 0, err: "expected at least 1 argument, got 0"},
+		{min: 0, max: 1, n: 2, err: "expected at most 1 argument, got 2"},
+	} {
+		set := getopt.New()
+		SetArgs(set, tt.min, tt.max)
+		args := []string{"cmd"}
+		for i := 0; i < tt.n; i++ {
+			args = append(args, "arg")
+		}
+		_, err := SubParse(set, args)
+		switch {
+		case err == nil && tt.err != "":
+			t.Errorf("%d-%d with %d: did not get error %s", tt.min, tt.max, tt.n, tt.err)
+		case err != nil && err.Error() != tt.err:
+			t.Errorf("%d-%d with %d: got error %v, want %q", tt.min, tt.max, tt.n, err, tt.err)
+		}
+	}
+}
+
+func TestDashDash(t *testing.T) {
+	for _, tt := range []struct {
+		args         []string
+		interspersed bool
+		files, exec  []string
+		dashArgs     []string
+	}{
+		{args: []string{"cmd", "a"}, files: []string{"a"}},
+		{args: []string{"cmd", "-v", "--", "ls", "-l"}, exec: []string{"ls", "-l"}, dashArgs: []string{"ls", "-l"}},
+		{args: []string{"cmd", "a", "--", "ls"}, files: []string{"a"}, exec: []string{"ls"}, dashArgs: []string{"ls"}},
+		{args: []string{"cmd", "a", "-v", "b", "--", "ls"}, interspersed: true, files: []string{"a", "b"}, exec: []string{"ls"}, dashArgs: []string{"ls"}},
+	} {
+		opts := &struct {
+			Verbose bool     `getopt:"-v be verbose"`
+			Files   []string `getopt:"-" args:"0-2"`
+			Exec    []string `getopt:"-" dashdash:""`
+		}{}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		SetInterspersed(set, tt.interspersed)
+		r, err := SubParse(set, tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(opts.Files, tt.files) || !reflect.DeepEqual(opts.Exec, tt.exec) {
+			t.Errorf("%q: got %q and %q, want %q and %q", tt.args, opts.Files, opts.Exec, tt.files, tt.exec)
+		}
+		if !reflect.DeepEqual(r.DashArgs, tt.dashArgs) {
+			t.Errorf("%q: got DashArgs %q, want %q", tt.args, r.DashArgs, tt.dashArgs)
+		}
+	}
+
+	if err := Validate(&struct {
+		Exec []string `getopt:"-" args:"1" dashdash:""`
+	}{}); err == nil {
+		t.Error("did not get an error for a field with both args and dashdash tags")
+	}
+}
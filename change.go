@@ -0,0 +1,112 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/tag"
+)
+
+var (
+	changeMu    sync.Mutex
+	changeFuncs = map[getopt.Option][]func(old, new string){}
+	parsedSets  = map[*getopt.Set]bool{} // sets that have been parsed
+)
+
+// A change is a change to the value of an option to be reported to fns.
+type change struct {
+	fns      []func(old, new string)
+	old, new string
+}
+
+// OnChange arranges for fn to be called whenever the value of option, declared
+// by i, a pointer to a registered options structure, is changed by a Flags
+// after the set i was most recently registered with has been parsed, e.g., by
+// calling Flags.Set to reload a flags file or by Flags.SetMap.  Changes made
+// while parsing the command line, including by flags files named on the
+// command line, are not reported.  fn is passed the old and new values of the
+// option as strings and is only called if they differ.  option is the long or
+// short name of the option without leading dashes.  An error is returned if i
+// has not been registered.
+//
+//	options.OnChange(&myOptions, "verbose", func(old, new string) {
+//		log.Printf("verbose changed from %s to %s", old, new)
+//	})
+func OnChange(i interface{}, option string, fn func(old, new string)) error {
+	v, err := structValue(i)
+	if err != nil {
+		return err
+	}
+	t := v.Type()
+	for x := 0; x < t.NumField(); x++ {
+		field := t.Field(x)
+		fv := v.Field(x)
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
+			continue
+		}
+		o, err := tag.Lookup(field.Tag)
+		if err != nil {
+			return fieldError(field.Name, err)
+		}
+		o = autoName(o, field.Name)
+		if option == o.Long || (o.Short != 0 && option == string(o.Short)) {
+			opt := fieldOption(fv)
+			if opt == nil {
+				return fmt.Errorf("%T: %w", i, errNotRegistered)
+			}
+			changeMu.Lock()
+			changeFuncs[opt] = append(changeFuncs[opt], fn)
+			changeMu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("%T has no option named %q", i, option)
+}
+
+// setParsed records that set has been parsed.
+func setParsed(set *getopt.Set) {
+	changeMu.Lock()
+	parsedSets[set] = true
+	changeMu.Unlock()
+}
+
+// clearParsed records that set has not been parsed, e.g., once it is reset.
+func clearParsed(set *getopt.Set) {
+	changeMu.Lock()
+	delete(parsedSets, set)
+	changeMu.Unlock()
+}
+
+// onChange returns the functions to call when the value of opt, in set, is
+// changed.  It returns nil if set has not been parsed.
+func onChange(set *getopt.Set, opt getopt.Option) []func(old, new string) {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+	if !parsedSets[set] {
+		return nil
+	}
+	return changeFuncs[opt]
+}
+
+// notify calls the functions of each change.
+func notify(changes []change) {
+	for _, c := range changes {
+		for _, fn := range c.fns {
+			fn(c.old, c.new)
+		}
+	}
+}
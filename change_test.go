@@ -0,0 +1,67 @@
+package options
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestOnChange(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	first := write("first", "name = bob\ncount = 1\n")
+	second := write("second", "name = fred\ncount = 1\nv = false\n")
+
+	opts := &struct {
+		Flags   Flags  `getopt:"--flags=PATH flags file"`
+		Name    string `getopt:"--name=NAME name of the widget"`
+		Count   int    `getopt:"--count=N number of widgets"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}{}
+	var got []string
+	record := func(name string) func(old, new string) {
+		return func(old, new string) {
+			got = append(got, fmt.Sprintf("%s %s->%s", name, old, new))
+		}
+	}
+	if err := OnChange(opts, "name", record("name")); err == nil {
+		t.Error("OnChange did not return an error for an unregistered structure")
+	}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"name", "count", "v"} {
+		if err := OnChange(opts, name, record(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := OnChange(opts, "missing", record("missing")); err == nil {
+		t.Error("OnChange did not return an error for a missing option")
+	}
+
+	if _, err := SubParse(set, []string{"test", "-v", "--flags", first}); err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("changes reported while parsing: %q", got)
+	}
+	if err := opts.Flags.Set(second, nil); err != nil {
+		t.Fatal(err)
+	}
+	// count did not change and -v was set on the command line.
+	want := []string{"name bob->fred"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
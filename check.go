@@ -0,0 +1,142 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/internal/optvalue"
+)
+
+// A fieldType describes the field an option was registered from so a scratch
+// value of the same type can be created by Check.
+type fieldType struct {
+	t     reflect.Type
+	units string
+	addr  uintptr // address of the field
+}
+
+// A fieldKey identifies a field of a registered structure.
+type fieldKey struct {
+	addr uintptr
+	t    reflect.Type
+}
+
+var (
+	fieldTypesMu sync.Mutex
+	fieldTypes   = map[getopt.Option]fieldType{}
+	fieldOptions = map[fieldKey]getopt.Option{} // the reverse of fieldTypes
+)
+
+// setFieldType records that opt was registered from the field fv.
+func setFieldType(opt getopt.Option, fv reflect.Value, units string) {
+	fieldTypesMu.Lock()
+	fieldTypes[opt] = fieldType{t: fv.Type(), units: units, addr: fv.Addr().Pointer()}
+	fieldOptions[fieldKey{addr: fv.Addr().Pointer(), t: fv.Type()}] = opt
+	fieldTypesMu.Unlock()
+}
+
+// fieldOption returns the option most recently registered from the field fv,
+// or nil.
+func fieldOption(fv reflect.Value) getopt.Option {
+	fieldTypesMu.Lock()
+	defer fieldTypesMu.Unlock()
+	return fieldOptions[fieldKey{addr: fv.Addr().Pointer(), t: fv.Type()}]
+}
+
+// scratchOption returns a new option, in a new set, that parses values as opt
+// does, or nil if opt was not registered from a field.
+func scratchOption(opt getopt.Option) getopt.Option {
+	fieldTypesMu.Lock()
+	ft, ok := fieldTypes[opt]
+	fieldTypesMu.Unlock()
+	if !ok {
+		return nil
+	}
+	fv := reflect.New(ft.t).Elem()
+	var p interface{}
+	if ft.units != "" {
+		p = &unitsValue{optvalue.Units{V: fv, Units: ft.units}}
+	} else {
+		p = optionValue(fv.Addr().Interface())
+	}
+	return getopt.New().FlagLong(p, "option", 0)
+}
+
+// Check reads the flags file named by path, as Set would, and returns all the
+// problems with it without changing any option.  The values of the options in
+// f.Sets, and in the sets registered by NewSet, are parsed and each value that
+// cannot be parsed is reported.  Unknown options are reported, as an
+// UnknownOptionError, even if IgnoreUnknown is set.  If more than one problem
+// is found then an Errors is returned.  Check is intended for validating
+// configuration files, for example in continuous integration.
+//
+//	if err := opts.Flags.Check("/etc/widget.flags"); err != nil {
+//		...
+//	}
+func (f *Flags) Check(path string) error {
+	f.lock()
+	sets := f.allSets()
+	f.unlock()
+
+	m, _, err := f.read(context.Background(), expand(path), false)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+	var errs Errors
+	for _, set := range sets {
+		m := m
+		if set.Name != "" {
+			sm, ok := submap(m, set.Name)
+			if !ok {
+				continue
+			}
+			m = sm
+		}
+		set.VisitAll(func(o getopt.Option) {
+			if isDisabled(o) {
+				return
+			}
+			v, ok := takeValue(m, o)
+			if !ok {
+				return
+			}
+			ss, err := flagStrings(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", path, o.Name(), err))
+				return
+			}
+			so := scratchOption(o)
+			if so == nil {
+				return
+			}
+			if err := setValues(so, ss); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", path, o.Name(), err))
+			}
+		})
+	}
+	if names := unknownNames(nil, "", m); len(names) > 0 {
+		sort.Strings(names)
+		errs = append(errs, &UnknownOptionError{File: path, Names: names})
+	}
+	return errs.Err()
+}
@@ -0,0 +1,51 @@
+package options
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestFlagsCheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good", "name = bob\ncount = 2\n")
+	bad := write("bad", "name = bob\ncount = many\nbogus = 1\n")
+
+	opts := &struct {
+		Flags Flags  `getopt:"--flags=PATH flags file" flags:"ignore-unknown"`
+		Name  string `getopt:"--name=NAME name of the widget"`
+		Count int    `getopt:"--count=N number of widgets"`
+	}{}
+	if err := RegisterSet("", opts, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := opts.Flags.Check(good); err != nil {
+		t.Errorf("%s: unexpected error %v", good, err)
+	}
+	err := opts.Flags.Check(bad)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("%s: got error %v, want 2 errors", bad, err)
+	}
+	var ue *UnknownOptionError
+	if !errors.As(errs[1], &ue) || len(ue.Names) != 1 || ue.Names[0] != "--bogus" {
+		t.Errorf("%s: got %v, want unknown option --bogus", bad, errs[1])
+	}
+	if opts.Name != "" || opts.Count != 0 {
+		t.Errorf("Check changed the options: %+v", opts)
+	}
+	if err := opts.Flags.Check(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("did not get an error for a missing file")
+	}
+}
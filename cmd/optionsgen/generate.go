@@ -0,0 +1,170 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pborman/options/tag"
+)
+
+// generate returns the source of a file, in the package in dir, declaring a
+// function that registers the options declared by the struct type typeName.
+func generate(dir, typeName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			if st := findStruct(file, typeName); st != nil {
+				return generateStruct(pkg.Name, typeName, st)
+			}
+		}
+	}
+	return nil, fmt.Errorf("struct type %s not found in %s", typeName, dir)
+}
+
+// findStruct returns the declaration of the struct type name in file, or nil.
+func findStruct(file *ast.File, name string) *ast.StructType {
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == name {
+				return st
+			}
+		}
+	}
+	return nil
+}
+
+// generateStruct returns the source of the registration function for the
+// struct type typeName, declared by st, in package pkg.
+func generateStruct(pkg, typeName string, st *ast.StructType) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by optionsgen -type=%s; DO NOT EDIT.\n\n", typeName)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import \"github.com/pborman/getopt/v2\"\n\n")
+	fn := "Register" + exported(typeName)
+	fmt.Fprintf(&buf, "// %s registers the options declared by o with set without using\n", fn)
+	fmt.Fprintf(&buf, "// reflection.\n")
+	fmt.Fprintf(&buf, "func %s(o *%s, set *getopt.Set) {\n", fn, typeName)
+
+	var errs []string
+	for _, field := range st.Fields.List {
+		stag := structTag(field)
+		if len(field.Names) == 0 {
+			if stag.Get("getopt") != "-" {
+				errs = append(errs, fmt.Sprintf("%s: embedded fields are not supported", types.ExprString(field.Type)))
+			}
+			continue
+		}
+		for _, name := range field.Names {
+			line, err := register(name.Name, field.Type, stag)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			buf.WriteString(line)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// register returns the statement that registers the field name, of type
+// expr, with the struct tag stag.  It returns "" if the field does not declare
+// an option.
+func register(name string, expr ast.Expr, stag reflect.StructTag) (string, error) {
+	sf := reflect.StructField{Name: name, Tag: stag}
+	if !ast.IsExported(name) {
+		sf.PkgPath = "-"
+	}
+	spec, err := tag.FieldSpec(sf)
+	if err != nil || spec == nil {
+		return "", err
+	}
+	typ := types.ExprString(expr)
+	switch {
+	case typ == "options.Flags":
+		return "", fmt.Errorf("%s: Flags fields are not supported", name)
+	case stag.Get("units") != "":
+		return "", fmt.Errorf("%s: units are not supported", name)
+	case stag.Get("helpvar") != "":
+		return "", fmt.Errorf("%s: helpvar tags are not supported", name)
+	case spec.Optional:
+		return "", fmt.Errorf("%s: optional parameters are not supported", name)
+	}
+	if _, ok := expr.(*ast.MapType); ok {
+		return "", fmt.Errorf("%s: maps are not supported", name)
+	}
+	help := spec.Help
+	if help == "" {
+		help = "unspecified"
+	}
+	short := "0"
+	if spec.Short != 0 {
+		short = strconv.QuoteRune(spec.Short)
+	}
+	args := []string{"&o." + name, strconv.Quote(spec.Long), short, strconv.Quote(help)}
+	if spec.Param != "" {
+		args = append(args, strconv.Quote(spec.Param))
+	}
+	line := "\tset.FlagLong(" + strings.Join(args, ", ") + ")"
+	if typ == "bool" {
+		line += ".SetFlag()"
+	}
+	return line + "\n", nil
+}
+
+// structTag returns the tag of field.
+func structTag(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	s, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(s)
+}
+
+// exported returns name with its first letter in upper case.
+func exported(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const src = `package p
+
+import "time"
+
+type myOptions struct {
+	Name    string        ` + "`getopt:\"--name -n=NAME name of the widget\"`" + `
+	Verbose bool          ` + "`getopt:\"-v be verbose\"`" + `
+	Timeout time.Duration ` + "`getopt:\"--timeout how long to wait\"`" + `
+	Count   int
+	Ignored int ` + "`getopt:\"-\"`" + `
+	private int
+}
+
+type badOptions struct {
+	Labels map[string]string ` + "`getopt:\"--label=KEY=VALUE labels\"`" + `
+	Size   int               ` + "`getopt:\"--size=SIZE size\" units:\"iec\"`" + `
+}
+`
+
+const want = `// Code generated by optionsgen -type=myOptions; DO NOT EDIT.
+
+package p
+
+import "github.com/pborman/getopt/v2"
+
+// RegisterMyOptions registers the options declared by o with set without using
+// reflection.
+func RegisterMyOptions(o *myOptions, set *getopt.Set) {
+	set.FlagLong(&o.Name, "name", 'n', "name of the widget", "NAME")
+	set.FlagLong(&o.Verbose, "", 'v', "be verbose").SetFlag()
+	set.FlagLong(&o.Timeout, "timeout", 0, "how long to wait")
+	set.FlagLong(&o.Count, "count", 0, "unspecified")
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, "myOptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = generate(dir, "badOptions")
+	if err == nil {
+		t.Fatal("did not get an error for badOptions")
+	}
+	for _, s := range []string{"Labels: maps are not supported", "Size: units are not supported"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q does not contain %q", err, s)
+		}
+	}
+	if _, err := generate(dir, "missing"); err == nil {
+		t.Error("did not get an error for a missing type")
+	}
+}
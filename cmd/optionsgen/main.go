@@ -0,0 +1,68 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Program optionsgen generates a function that registers the options declared
+// by a structure with a getopt set without using reflection.  It is intended
+// for programs where the reflection done by options.RegisterSet at startup is
+// undesirable.  It is normally run by go generate:
+//
+//	//go:generate go run github.com/pborman/options/cmd/optionsgen -type=myOptions
+//
+// For the type myOptions, optionsgen writes myoptions_options.go containing
+//
+//	func RegisterMyOptions(o *myOptions, set *getopt.Set)
+//
+// which calls set.FlagLong for each option declared by the fields of
+// myOptions, using the same names, parameters, and help text that
+// options.RegisterSet would.  Features of the options package that require
+// reflection, such as Flags fields, units, optional parameters, and maps, are
+// reported as errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the options structure (required)")
+	output := flag.String("output", "", "output file (default TYPE_options.go)")
+	flag.Parse()
+	if *typeName == "" || flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "usage: optionsgen -type=TYPE [-output=FILE] [DIR]\n")
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	src, err := generate(dir, *typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "optionsgen: %v\n", err)
+		os.Exit(1)
+	}
+	path := *output
+	if path == "" {
+		path = strings.ToLower(*typeName) + "_options.go"
+		if dir != "." {
+			path = dir + "/" + path
+		}
+	}
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "optionsgen: %v\n", err)
+		os.Exit(1)
+	}
+}
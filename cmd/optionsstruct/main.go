@@ -0,0 +1,53 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Program optionsstruct helps convert a program that declares its options with
+// direct calls to the github.com/pborman/getopt/v2 package to the options
+// package.  It reads the Go files of a package and writes the declaration of a
+// structure with a tagged field for each option declared by calls such as
+//
+//	verbose := getopt.BoolLong("verbose", 'v', "be verbose")
+//	getopt.FlagLong(&name, "name", 'n', "name of the widget", "NAME")
+//	set.StringLong("host", 0, "localhost", "host to connect to", "HOST")
+//
+// along with a variable holding the option defaults, if any.  The output is a
+// starting point; each use of the old variables must still be changed to use
+// the structure.
+//
+//	optionsstruct [-type=NAME] [DIR]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	typeName := flag.String("type", "options", "name of the structure to declare")
+	flag.Parse()
+	if flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "usage: optionsstruct [-type=NAME] [DIR]\n")
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	src, err := generate(dir, *typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "optionsstruct: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(src)
+}
@@ -0,0 +1,494 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A constructor describes a getopt function, or Set method, that declares an
+// option and returns a pointer to its value, e.g., getopt.StringLong.
+type constructor struct {
+	long       bool   // the first argument is the long name
+	typ        string // the type of the option's value
+	hasDefault bool   // the default value follows the names
+}
+
+// constructors are the getopt functions optionsstruct understands, other than
+// Flag and FlagLong.
+var constructors = map[string]constructor{}
+
+func init() {
+	for _, typ := range []string{
+		"Duration", "Int", "Int16", "Int32", "Int64",
+		"Uint", "Uint16", "Uint32", "Uint64", "String",
+	} {
+		t := strings.ToLower(typ)
+		if typ == "Duration" {
+			t = "time.Duration"
+		}
+		constructors[typ] = constructor{typ: t, hasDefault: true}
+		constructors[typ+"Long"] = constructor{long: true, typ: t, hasDefault: true}
+	}
+	constructors["Bool"] = constructor{typ: "bool"}
+	constructors["BoolLong"] = constructor{long: true, typ: "bool"}
+	constructors["List"] = constructor{typ: "[]string"}
+	constructors["ListLong"] = constructor{long: true, typ: "[]string"}
+}
+
+// unsupported are getopt functions whose options have no equivalent field type.
+var unsupported = map[string]bool{
+	"Counter": true, "CounterLong": true,
+	"Enum": true, "EnumLong": true,
+	"Signed": true, "SignedLong": true,
+	"Unsigned": true, "UnsignedLong": true,
+}
+
+// An option is an option found in the source.
+type option struct {
+	pos   token.Pos
+	field string // name of the field
+	typ   string // type of the field
+	long  string // long name, or ""
+	short string // short name, or ""
+	help  string
+	param string
+	def   string // source of the default value, or ""
+}
+
+// generate returns the source declaring the struct type typeName with a field
+// for each option declared by getopt calls in the package in dir.
+func generate(dir, typeName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	var opts []option
+	var errs []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			o, e := scanFile(fset, file)
+			opts = append(opts, o...)
+			errs = append(errs, e...)
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("no getopt options found in %s", dir)
+	}
+	sort.Slice(opts, func(i, j int) bool { return opts[i].pos < opts[j].pos })
+	return writeStruct(typeName, opts)
+}
+
+// scanFile returns the options declared by getopt calls in file and errors
+// describing the calls that could not be converted.
+func scanFile(fset *token.FileSet, file *ast.File) ([]option, []string) {
+	pkgName := getoptName(file)
+	if pkgName == "" {
+		return nil, nil
+	}
+	// Find the variables the results of constructors are assigned to.
+	names := map[*ast.CallExpr]string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, rhs := range n.Rhs {
+				if call, ok := rhs.(*ast.CallExpr); ok && i < len(n.Lhs) {
+					if id, ok := n.Lhs[i].(*ast.Ident); ok {
+						names[call] = id.Name
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for i, v := range n.Values {
+				if call, ok := v.(*ast.CallExpr); ok && i < len(n.Names) {
+					names[call] = n.Names[i].Name
+				}
+			}
+		}
+		return true
+	})
+
+	var opts []option
+	var errs []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isGetopt(sel.X, pkgName) {
+			return true
+		}
+		fn := sel.Sel.Name
+		where := fset.Position(call.Pos()).String()
+		var o option
+		var err error
+		switch {
+		case fn == "Flag" || fn == "FlagLong":
+			o, err = flagCall(call, fn == "FlagLong")
+		case unsupported[fn]:
+			err = fmt.Errorf("getopt.%s has no equivalent field type", fn)
+		default:
+			c, ok := constructors[fn]
+			if !ok {
+				return true
+			}
+			o, err = constructorCall(call, c, names[call])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+			return true
+		}
+		o.pos = call.Pos()
+		opts = append(opts, o)
+		return true
+	})
+	return opts, errs
+}
+
+// getoptName returns the name file imports github.com/pborman/getopt/v2 as,
+// or "" if file does not import it.
+func getoptName(file *ast.File) string {
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || path != "github.com/pborman/getopt/v2" {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "getopt"
+	}
+	return ""
+}
+
+// isGetopt reports if x is the getopt package, named pkgName, or a variable
+// declared as a getopt.Set.  Other expressions, such as the fields of a
+// structure, are assumed to be a *getopt.Set.
+func isGetopt(x ast.Expr, pkgName string) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return true
+	}
+	if id.Name == pkgName {
+		return true
+	}
+	if id.Obj == nil || id.Obj.Kind != ast.Var {
+		return false
+	}
+	typ, def := varType(id.Obj)
+	return strings.Contains(typ+def, pkgName+".")
+}
+
+// flagCall returns the option declared by a call to Flag or FlagLong.
+func flagCall(call *ast.CallExpr, long bool) (option, error) {
+	var o option
+	if len(call.Args) < 2 {
+		return o, errors.New("too few arguments")
+	}
+	ue, ok := call.Args[0].(*ast.UnaryExpr)
+	if !ok || ue.Op != token.AND {
+		return o, fmt.Errorf("cannot determine the variable set by %s", types.ExprString(call.Args[0]))
+	}
+	var v *ast.Ident
+	switch x := ue.X.(type) {
+	case *ast.Ident:
+		v = x
+	case *ast.SelectorExpr:
+		v = x.Sel
+	}
+	if v == nil {
+		return o, fmt.Errorf("cannot determine the variable set by %s", types.ExprString(call.Args[0]))
+	}
+	o.field = exported(v.Name)
+	if v.Obj != nil {
+		o.typ, o.def = varType(v.Obj)
+	}
+	if o.typ == "" {
+		return o, fmt.Errorf("cannot determine the type of %s", v.Name)
+	}
+	args, err := names(call.Args[1:], long, &o)
+	if err != nil {
+		return o, err
+	}
+	return o, helpValue(args, &o)
+}
+
+// constructorCall returns the option declared by a call to the constructor c.
+// The result of the call is assigned to the variable name, if known.
+func constructorCall(call *ast.CallExpr, c constructor, name string) (option, error) {
+	o := option{typ: c.typ}
+	args, err := names(call.Args, c.long, &o)
+	if err != nil {
+		return o, err
+	}
+	if c.hasDefault {
+		if len(args) == 0 {
+			return o, errors.New("missing default value")
+		}
+		if def := types.ExprString(args[0]); !isZero(def) {
+			o.def = def
+		}
+		args = args[1:]
+	}
+	switch {
+	case name != "" && name != "_":
+		o.field = exported(name)
+	case o.long != "":
+		o.field = fieldName(o.long)
+	default:
+		o.field = strings.ToUpper(o.short)
+	}
+	return o, helpValue(args, &o)
+}
+
+// names sets the long and short names of o from the leading arguments in args
+// and returns the remaining arguments.
+func names(args []ast.Expr, long bool, o *option) ([]ast.Expr, error) {
+	if long {
+		if len(args) == 0 {
+			return nil, errors.New("missing long name")
+		}
+		s, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		o.long = s
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return nil, errors.New("missing short name")
+	}
+	lit, ok := args[0].(*ast.BasicLit)
+	switch {
+	case ok && lit.Kind == token.CHAR:
+		r, _, _, err := strconv.UnquoteChar(lit.Value[1:len(lit.Value)-1], '\'')
+		if err != nil {
+			return nil, err
+		}
+		o.short = string(r)
+	case ok && lit.Kind == token.INT && lit.Value == "0":
+	default:
+		return nil, fmt.Errorf("short name %s is not a constant", types.ExprString(args[0]))
+	}
+	if o.long == "" && o.short == "" {
+		return nil, errors.New("option has no name")
+	}
+	return args[1:], nil
+}
+
+// helpValue sets the help and parameter name of o from the helpvalue
+// arguments of a getopt call.
+func helpValue(args []ast.Expr, o *option) error {
+	if len(args) > 2 {
+		return errors.New("too many arguments")
+	}
+	var err error
+	if len(args) > 0 {
+		if o.help, err = stringArg(args[0]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if o.param, err = stringArg(args[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringArg returns the value of the string constant x.
+func stringArg(x ast.Expr) (string, error) {
+	if lit, ok := x.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return strconv.Unquote(lit.Value)
+	}
+	return "", fmt.Errorf("%s is not a string constant", types.ExprString(x))
+}
+
+// varType returns the type and initial value of the variable declared by obj,
+// if they can be determined from its declaration.
+func varType(obj *ast.Object) (typ, def string) {
+	var value ast.Expr
+	switch d := obj.Decl.(type) {
+	case *ast.ValueSpec:
+		if d.Type != nil {
+			typ = types.ExprString(d.Type)
+		}
+		for i, name := range d.Names {
+			if name.Name == obj.Name && i < len(d.Values) {
+				value = d.Values[i]
+			}
+		}
+	case *ast.AssignStmt:
+		for i, lhs := range d.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && id.Name == obj.Name && i < len(d.Rhs) {
+				value = d.Rhs[i]
+			}
+		}
+	case *ast.Field:
+		typ = types.ExprString(d.Type)
+	}
+	if value == nil {
+		return typ, ""
+	}
+	def = types.ExprString(value)
+	if typ == "" {
+		typ = literalType(value)
+	}
+	if isZero(def) {
+		def = ""
+	}
+	return typ, def
+}
+
+// literalType returns the type of the untyped constant or composite literal
+// x, or "" if it is not known.
+func literalType(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.STRING:
+			return "string"
+		case token.INT:
+			return "int"
+		case token.FLOAT:
+			return "float64"
+		}
+	case *ast.Ident:
+		if x.Name == "true" || x.Name == "false" {
+			return "bool"
+		}
+	case *ast.CompositeLit:
+		if x.Type != nil {
+			return types.ExprString(x.Type)
+		}
+	case *ast.CallExpr:
+		// A conversion such as time.Duration(0).
+		if len(x.Args) == 1 {
+			if _, ok := x.Fun.(*ast.SelectorExpr); ok {
+				return types.ExprString(x.Fun)
+			}
+		}
+	}
+	return ""
+}
+
+// isZero reports if the source def is obviously the zero value of its type.
+func isZero(def string) bool {
+	switch def {
+	case `""`, "``", "0", "false", "nil":
+		return true
+	}
+	return false
+}
+
+// writeStruct returns the declaration of the struct type typeName holding
+// opts, followed by a variable holding their defaults.
+func writeStruct(typeName string, opts []option) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	seen := map[string]bool{}
+	var errs []string
+	for _, o := range opts {
+		if seen[o.field] {
+			errs = append(errs, fmt.Sprintf("more than one option uses the field name %s", o.field))
+			continue
+		}
+		seen[o.field] = true
+		fmt.Fprintf(&buf, "\t%s %s `getopt:%s`\n", o.field, o.typ, strconv.Quote(getoptTag(o)))
+	}
+	buf.WriteString("}\n")
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	var defs []option
+	for _, o := range opts {
+		if o.def != "" {
+			defs = append(defs, o)
+		}
+	}
+	if len(defs) > 0 {
+		fmt.Fprintf(&buf, "\nvar default%s = %s{\n", exported(typeName), typeName)
+		for _, o := range defs {
+			fmt.Fprintf(&buf, "\t%s: %s,\n", o.field, o.def)
+		}
+		buf.WriteString("}\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// getoptTag returns the getopt tag declaring o.
+func getoptTag(o option) string {
+	var names []string
+	if o.long != "" {
+		names = append(names, "--"+o.long)
+	}
+	if o.short != "" {
+		names = append(names, "-"+o.short)
+	}
+	if o.param != "" && o.typ != "bool" {
+		names[len(names)-1] += "=" + o.param
+	}
+	tag := strings.Join(names, " ")
+	switch {
+	case o.help == "":
+	case strings.HasPrefix(o.help, "-"):
+		tag += " -- " + o.help
+	default:
+		tag += " " + o.help
+	}
+	return tag
+}
+
+// fieldName returns the field name for the long option name, e.g., MaxCount
+// for max-count.
+func fieldName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(exported(part))
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// exported returns name with its first letter in upper case.
+func exported(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const src = `package p
+
+import (
+	"time"
+
+	"github.com/pborman/getopt/v2"
+)
+
+var (
+	name    = "widget"
+	count   int
+	timeout = time.Duration(time.Minute)
+	set     = getopt.New()
+)
+
+func init() {
+	getopt.FlagLong(&name, "name", 'n', "name of the widget", "NAME")
+	getopt.Flag(&count, 'c', "number of widgets")
+	set.FlagLong(&timeout, "timeout", 0, "how long to wait")
+}
+
+var (
+	verbose  = getopt.BoolLong("verbose", 'v', "be verbose")
+	host     = getopt.StringLong("host", 0, "localhost", "host to connect to", "HOST")
+	_        = getopt.IntLong("max-count", 0, 0, "-1 means no limit")
+	files    = getopt.List('f', "add FILE", "FILE")
+)
+`
+
+const want = `type options struct {
+	Name     string        ` + "`getopt:\"--name -n=NAME name of the widget\"`" + `
+	Count    int           ` + "`getopt:\"-c number of widgets\"`" + `
+	Timeout  time.Duration ` + "`getopt:\"--timeout how long to wait\"`" + `
+	Verbose  bool          ` + "`getopt:\"--verbose -v be verbose\"`" + `
+	Host     string        ` + "`getopt:\"--host=HOST host to connect to\"`" + `
+	MaxCount int           ` + "`getopt:\"--max-count -- -1 means no limit\"`" + `
+	Files    []string      ` + "`getopt:\"-f=FILE add FILE\"`" + `
+}
+
+var defaultOptions = options{
+	Name:    "widget",
+	Timeout: time.Duration(time.Minute),
+	Host:    "localhost",
+}
+`
+
+const bad = `package p
+
+import "github.com/pborman/getopt/v2"
+
+var (
+	level = getopt.Counter('l', "increase level")
+	x     = getopt.StringLong(longName, 0, "", "computed name")
+)
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	dir = t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = generate(dir, "options")
+	if err == nil {
+		t.Fatal("did not get an error for bad")
+	}
+	for _, s := range []string{"getopt.Counter has no equivalent field type", "longName is not a string constant"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q does not contain %q", err, s)
+		}
+	}
+	if _, err := generate(t.TempDir(), "options"); err == nil {
+		t.Error("did not get an error for an empty directory")
+	}
+}
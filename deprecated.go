@@ -0,0 +1,175 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/tag"
+)
+
+var (
+	deprecatedMu sync.Mutex
+	// deprecated holds the uses of deprecated aliases in each set since it
+	// was last parsed.
+	deprecated = map[*getopt.Set][]error{}
+)
+
+// SetAlias declares alias as a deprecated name for the option name in set.
+// Both names are given without leading dashes and are long names unless they
+// are a single character.  Using the alias on the command line sets the option
+// name exactly as if name had been used, and a DeprecatedOptionError is
+// reported in the Warnings of the ParseResult (Parse writes it to standard
+// error).  This keeps an old name working for a release without declaring a
+// second field whose value can diverge:
+//
+//	options.SetAlias(set, "deadline", "timeout")
+//
+// An alias may also be declared with an alias-of tag on a blank field.  The
+// getopt tag of the field declares the alias, and its description, if any,
+// is the help for the alias:
+//
+//	Timeout time.Duration `getopt:"--timeout how long to wait"`
+//	_       struct{}      `getopt:"--deadline" alias-of:"timeout"`
+//
+// SetAlias returns an error if name is not an option of set or alias is
+// already an option of set.
+func SetAlias(set *getopt.Set, alias, name string) error {
+	target := lookupName(set, name)
+	if target == nil {
+		return fmt.Errorf("unknown option %s", dashed(name))
+	}
+	o := &tag.Tag{}
+	if utf8.RuneCountInString(alias) == 1 {
+		o.Short, _ = utf8.DecodeRuneInString(alias)
+	} else {
+		o.Long = alias
+	}
+	return addAlias(set, o, target)
+}
+
+// addAlias adds the options in o to set as deprecated aliases of target.
+func addAlias(set *getopt.Set, o *tag.Tag, target getopt.Option) error {
+	for _, name := range optionNames(o) {
+		if _, ok := lookupOwner(set, name); ok {
+			return fmt.Errorf("option %s already defined", name)
+		}
+	}
+	name := "--" + target.LongName()
+	if target.LongName() == "" {
+		name = "-" + target.ShortName()
+	}
+	help := o.Help
+	if help == "" {
+		help = "deprecated, use " + name
+	}
+	aliases := optionNames(o)
+	v := &aliasValue{set: set, target: target, use: &DeprecatedOptionError{Alias: aliases[0], Name: name}}
+	opt := set.FlagLong(v, o.Long, o.Short, help)
+	if target.IsFlag() {
+		opt.SetFlag()
+	}
+	setOwner(set, o, "alias of "+name)
+	return nil
+}
+
+// An aliasValue is the value of a deprecated alias.  Setting it sets target
+// and records the use of the alias.
+type aliasValue struct {
+	set    *getopt.Set
+	target getopt.Option
+	use    *DeprecatedOptionError
+}
+
+func (a *aliasValue) Set(value string, opt getopt.Option) error {
+	if err := a.target.Value().Set(value, a.target); err != nil {
+		return err
+	}
+	deprecatedMu.Lock()
+	deprecated[a.set] = append(deprecated[a.set], a.use)
+	deprecatedMu.Unlock()
+	return nil
+}
+
+func (a *aliasValue) String() string { return a.target.String() }
+
+// deprecatedUses returns the uses of deprecated aliases in set since it was
+// last parsed.
+func deprecatedUses(set *getopt.Set) []error {
+	deprecatedMu.Lock()
+	defer deprecatedMu.Unlock()
+	return append([]error(nil), deprecated[set]...)
+}
+
+// clearDeprecated forgets the uses of deprecated aliases in set.  It is called
+// before set is parsed.
+func clearDeprecated(set *getopt.Set) {
+	deprecatedMu.Lock()
+	delete(deprecated, set)
+	deprecatedMu.Unlock()
+}
+
+// An aliasSpec is an alias declared by an alias-of tag.
+type aliasSpec struct {
+	field  string
+	o      *tag.Tag // the names of the alias
+	target *tag.Tag // the name of the aliased option
+}
+
+// aliasField returns the alias declared by field, which has an alias-of tag.
+func aliasField(field reflect.StructField) (*aliasSpec, error) {
+	if field.Name != "_" {
+		return nil, fmt.Errorf("%s: alias-of tag requires a blank (_) field", field.Name)
+	}
+	o, err := tag.Lookup(field.Tag)
+	if err != nil {
+		return nil, fieldError(field.Name, err)
+	}
+	if o == nil || (o.Long == "" && o.Short == 0) {
+		return nil, errors.New("alias-of tag requires the alias to be named by a getopt tag")
+	}
+	name := field.Tag.Get("alias-of")
+	target := &tag.Tag{}
+	switch utf8.RuneCountInString(name) {
+	case 0:
+		return nil, errors.New("empty alias-of tag")
+	case 1:
+		target.Short, _ = utf8.DecodeRuneInString(name)
+	default:
+		target.Long = name
+	}
+	return &aliasSpec{field: field.Name, o: o, target: target}, nil
+}
+
+// lookupName returns the option name, without leading dashes, in set, or nil.
+func lookupName(set *getopt.Set, name string) getopt.Option {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return lookup(set, r)
+	}
+	return lookup(set, name)
+}
+
+// dashed returns name with its leading dashes.
+func dashed(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestAliasTag(t *testing.T) {
+	type aliasOptions struct {
+		Timeout time.Duration `getopt:"--timeout how long to wait"`
+		_       struct{}      `getopt:"--deadline" alias-of:"timeout"`
+		Verbose bool          `getopt:"--verbose -v be verbose"`
+		_       struct{}      `getopt:"--chatty old name for --verbose" alias-of:"v"`
+	}
+	for _, tt := range []struct {
+		args     []string
+		timeout  time.Duration
+		verbose  bool
+		warnings []string
+	}{
+		{args: []string{"cmd", "--timeout=5s"}, timeout: 5 * time.Second},
+		{
+			args:     []string{"cmd", "--deadline=7s"},
+			timeout:  7 * time.Second,
+			warnings: []string{"--deadline is deprecated, use --timeout"},
+		},
+		{
+			args:     []string{"cmd", "--chatty"},
+			verbose:  true,
+			warnings: []string{"--chatty is deprecated, use --verbose"},
+		},
+	} {
+		opts := &aliasOptions{}
+		r, err := ParseArgs(opts, tt.args)
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if opts.Timeout != tt.timeout || opts.Verbose != tt.verbose {
+			t.Errorf("%v: got %v, %v, want %v, %v", tt.args, opts.Timeout, opts.Verbose, tt.timeout, tt.verbose)
+		}
+		var warnings []string
+		for _, w := range r.Warnings {
+			var de *DeprecatedOptionError
+			if !errors.As(w, &de) {
+				t.Errorf("%v: unexpected warning %v", tt.args, w)
+			}
+			warnings = append(warnings, w.Error())
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%v: got warnings %q, want %q", tt.args, warnings, tt.warnings)
+		}
+	}
+
+	for _, tt := range []struct {
+		opts interface{}
+		err  string
+	}{
+		{&struct {
+			Old string `getopt:"--old" alias-of:"new"`
+			New string `getopt:"--new"`
+		}{}, "Old: alias-of tag requires a blank (_) field"},
+		{&struct {
+			_ struct{} `getopt:"--old" alias-of:"missing"`
+		}{}, "alias of unknown option --missing"},
+		{&struct {
+			Name string   `getopt:"--name"`
+			_    struct{} `getopt:"--name" alias-of:"name"`
+		}{}, "--name"},
+	} {
+		err := Validate(tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("got error %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestSetAlias(t *testing.T) {
+	opts := &struct {
+		Name string `getopt:"--name -n=NAME the name"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAlias(set, "label", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAlias(set, "N", "n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAlias(set, "other", "missing"); err == nil {
+		t.Errorf("SetAlias of a missing option did not fail")
+	}
+	if err := SetAlias(set, "n", "name"); err == nil {
+		t.Errorf("SetAlias of an existing option did not fail")
+	}
+	r, err := SubParse(set, []string{"cmd", "--label=bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || len(r.Warnings) != 1 {
+		t.Errorf("got %q, %v, want bob and one warning", opts.Name, r.Warnings)
+	}
+	r, err = SubParse(set, []string{"cmd", "-Nfred"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "fred" || len(r.Warnings) != 1 || r.Warnings[0].Error() != "-N is deprecated, use --name" {
+		t.Errorf("got %q, %v", opts.Name, r.Warnings)
+	}
+	r, err = SubParse(set, []string{"cmd", "--name=sam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Warnings) != 0 {
+		t.Errorf("warnings were not cleared: %v", r.Warnings)
+	}
+}
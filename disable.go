@@ -0,0 +1,75 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+var (
+	disabledMu sync.Mutex
+	// disabled is the set of options that have been disabled.
+	disabled = map[getopt.Option]bool{}
+)
+
+// DisableOption disables the option name in set, which must already be
+// registered.  The name is given without leading dashes and is a long name
+// unless it is a single character.  A program that embeds the options of a
+// library can use DisableOption to drop an option it does not support:
+//
+//	options.Register(&opts)
+//	options.DisableOption(getopt.CommandLine, "experimental-x")
+//
+// A disabled option keeps its default value.  It is an unknown option on the
+// command line and in flags files, and it is not displayed by PrintUsage,
+// Help, or HelpAll.  The set's own PrintUsage method, which is used by the
+// default usage function of getopt, still displays it; use SetUsage to
+// display the usage with PrintUsage instead.  An error is returned if set has
+// no option named name.
+func DisableOption(set *getopt.Set, name string) error {
+	o := lookupName(set, name)
+	if o == nil {
+		return fmt.Errorf("unknown option %s", dashed(name))
+	}
+	disabledMu.Lock()
+	disabled[o] = true
+	disabledMu.Unlock()
+	return nil
+}
+
+// isDisabled reports if o has been disabled.
+func isDisabled(o getopt.Option) bool {
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+	return disabled[o]
+}
+
+// checkDisabled returns an error if a disabled option of set was seen on the
+// command line.
+func checkDisabled(set *getopt.Set) error {
+	var err error
+	set.VisitAll(func(o getopt.Option) {
+		if err == nil && o.Seen() && isDisabled(o) {
+			name := o.LongName()
+			if name == "" {
+				name = o.ShortName()
+			}
+			err = fmt.Errorf("unknown option: %s", dashed(name))
+		}
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+type disableOptions struct {
+	Flags        Flags  `getopt:"--flags=PATH flags file"`
+	Name         string `getopt:"--name=NAME name"`
+	Experimental bool   `getopt:"--experimental-x -x enable experiment x"`
+}
+
+// disabledSet returns a new set with opts registered and --experimental-x
+// disabled.
+func disabledSet(t *testing.T, opts *disableOptions) *getopt.Set {
+	t.Helper()
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := DisableOption(set, "experimental-x"); err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestDisableOption(t *testing.T) {
+	if err := DisableOption(getopt.New(), "missing"); err == nil {
+		t.Error("DisableOption accepted an unknown option")
+	}
+
+	for _, args := range [][]string{
+		{"cmd", "--experimental-x"},
+		{"cmd", "-x"},
+	} {
+		_, err := SubParse(disabledSet(t, &disableOptions{}), args)
+		if err == nil || !strings.Contains(err.Error(), "unknown option") {
+			t.Errorf("%q: got error %v, want unknown option", args, err)
+		}
+	}
+
+	_, err := SubParse(disabledSet(t, &disableOptions{}), []string{"cmd", "--flags", "data:name=bob%0Aexperimental-x=true"})
+	if err == nil || !strings.Contains(err.Error(), "experimental-x") {
+		t.Errorf("got error %v, want unknown option experimental-x", err)
+	}
+
+	opts := &disableOptions{}
+	set := disabledSet(t, opts)
+	if _, err := SubParse(set, []string{"cmd", "--name=bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Experimental {
+		t.Errorf("got %q, %v, want bob, false", opts.Name, opts.Experimental)
+	}
+
+	var buf bytes.Buffer
+	printSetUsage(&buf, set, false)
+	if usage := buf.String(); strings.Contains(usage, "experimental") || !strings.Contains(usage, "--name") {
+		t.Errorf("got usage:\n%s", usage)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A DumpConfig option causes the effective configuration, the value of every
+// option after the command line and all flags files have been applied, to be
+// written to standard output once the command line has been parsed.  Normally
+// os.Exit(0) is called after the configuration is written.  As with Help,
+// setting the defaulted value to true prevents os.Exit from being called.
+//
+// The values of options with a secret tag are masked and each value is
+// annotated with its source.  The configuration is written in the simple
+// encoding, which can be read back as a flags file, unless the field has an
+// encoding tag naming another encoding, see WriteConfig.
+//
+//	var myOptions = struct {
+//		Dump     options.DumpConfig `getopt:"--dump-config display the configuration and exit"`
+//		Password string             `getopt:"--password=PASSWORD database password" secret:""`
+//		...
+//	}{}
+type DumpConfig bool
+
+// Set implements getopt.Value.  The configuration is written after parsing
+// completes, not when the option is seen.
+func (d *DumpConfig) Set(value string, opt getopt.Option) error {
+	return nil
+}
+
+// String implements getopt.Value.
+func (d *DumpConfig) String() string {
+	return fmt.Sprint(bool(*d))
+}
+
+// maskedValue is displayed in place of the value of a secret option.
+const maskedValue = "********"
+
+var (
+	dumpMu        sync.Mutex
+	secrets       = map[getopt.Option]bool{}   // options with a secret tag
+	dumpEncodings = map[getopt.Option]string{} // encoding of DumpConfig options
+)
+
+// configWriters are the encodings supported by WriteConfig.
+var configWriters = map[string]func(io.Writer, []configEntry) error{
+	"simple": writeSimpleConfig,
+	"json":   writeJSONConfig,
+}
+
+// A configEntry is the value of a single option written by WriteConfig.
+type configEntry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	File   string `json:"file,omitempty"`
+}
+
+// setSecret marks opt as holding a secret.
+func setSecret(opt getopt.Option) {
+	dumpMu.Lock()
+	secrets[opt] = true
+	dumpMu.Unlock()
+}
+
+// isSecret reports if opt holds a secret.
+func isSecret(opt getopt.Option) bool {
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	return secrets[opt]
+}
+
+// setDumpEncoding sets the encoding used by the DumpConfig option opt.
+func setDumpEncoding(opt getopt.Option, encoding string) {
+	dumpMu.Lock()
+	dumpEncodings[opt] = encoding
+	dumpMu.Unlock()
+}
+
+// checkDumpEncoding returns an error if encoding cannot be used by WriteConfig.
+func checkDumpEncoding(encoding string) error {
+	if _, ok := configWriters[encoding]; !ok {
+		var names []string
+		for name := range configWriters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("cannot write configuration as %q, must be one of %s", encoding, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// WriteConfig writes the effective configuration of set, which has been
+// parsed, to w using encoding, either "simple" or "json".  Every option other
+// than Flags, Help, HelpAll, and DumpConfig options is written along with the
+// source of its value (see ParseResult).  The values of options with a secret
+// tag are masked.
+//
+// The simple encoding writes one option per line with the source in a comment:
+//
+//	name = bob # command line
+//	timeout = 5m # file /etc/widget.flags
+//
+// The json encoding writes an array of objects with the fields name, value,
+// source, and, for values from a flags file, file.
+func WriteConfig(w io.Writer, set *getopt.Set, encoding string) error {
+	if encoding == "" {
+		encoding = "simple"
+	}
+	if err := checkDumpEncoding(encoding); err != nil {
+		return err
+	}
+	r := parseResult(set)
+	var entries []configEntry
+	dumpMu.Lock()
+	set.VisitAll(func(o getopt.Option) {
+		switch o.Value().(type) {
+		case *Flags, *Help, *HelpAll, *DumpConfig:
+			return
+		}
+		name := o.LongName()
+		if name == "" {
+			name = o.ShortName()
+		}
+		e := configEntry{
+			Name:   name,
+			Value:  o.String(),
+			Source: r.Sources[name].String(),
+			File:   r.Files[name],
+		}
+		if secrets[o] && e.Value != "" {
+			e.Value = maskedValue
+		}
+		entries = append(entries, e)
+	})
+	dumpMu.Unlock()
+	return configWriters[encoding](w, entries)
+}
+
+// dumpConfig writes the configuration of set to standard output if a
+// DumpConfig option in set was seen.
+func dumpConfig(set *getopt.Set) {
+	var d *DumpConfig
+	var encoding string
+	set.VisitAll(func(o getopt.Option) {
+		if dc, ok := o.Value().(*DumpConfig); ok && o.Seen() {
+			d = dc
+			dumpMu.Lock()
+			encoding = dumpEncodings[o]
+			dumpMu.Unlock()
+		}
+	})
+	if d == nil {
+		return
+	}
+	if err := WriteConfig(os.Stdout, set, encoding); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !*d {
+		os.Exit(0)
+	}
+}
+
+func writeSimpleConfig(w io.Writer, entries []configEntry) error {
+	for _, e := range entries {
+		source := e.Source
+		if e.File != "" {
+			source += " " + e.File
+		}
+		if _, err := fmt.Fprintf(w, "%s = %s # %s\n", e.Name, simpleQuote(e.Value), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSONConfig(w io.Writer, entries []configEntry) error {
+	if entries == nil {
+		entries = []configEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// simpleQuote returns s quoted, if necessary, so the simple decoder reads it
+// back as s.
+func simpleQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\r\n#'\"\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,75 @@
+package options
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestWriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flags")
+	if err := ioutil.WriteFile(path, []byte("count = 42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &struct {
+		Flags    Flags      `getopt:"--flags=PATH flags file"`
+		Dump     DumpConfig `getopt:"--dump-config display the configuration"`
+		Name     string     `getopt:"--name=NAME name of the widget"`
+		Count    int        `getopt:"--count=N number of widgets"`
+		Password string     `getopt:"--password=PASSWORD password" secret:""`
+		Label    string     `getopt:"--label=LABEL label"`
+	}{Label: "a #1"}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"test", "--flags", path, "--name=bob", "--password=hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteConfig(&buf, set, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := `count = 42 # file ` + path + `
+label = "a #1" # default
+name = bob # command line
+password = ******** # command line
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteConfig(&buf, set, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"file": "`+path+`"`)) {
+		t.Errorf("json configuration does not include the file:\n%s", buf.String())
+	}
+	if err := WriteConfig(&buf, set, "xml"); err == nil {
+		t.Errorf("did not get an error for an unknown encoding")
+	}
+
+	if err := RegisterSet("", &struct {
+		Dump DumpConfig `getopt:"--dump-config" encoding:"xml"`
+	}{}, getopt.New()); err == nil {
+		t.Errorf("did not get an error for an unknown DumpConfig encoding")
+	}
+}
+
+func TestSimpleQuote(t *testing.T) {
+	for _, s := range []string{"", "bob", "a b", "#", `say "hi"`, `C:\dir`, "it's"} {
+		q := simpleQuote(s)
+		got, err := unquote(q, true)
+		if err != nil {
+			t.Errorf("%q: quoted as %s: %v", s, q, err)
+		} else if got != s {
+			t.Errorf("%q: quoted as %s read back as %q", s, q, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pborman/options/internal/opterrors"
+	"github.com/pborman/options/tag"
+)
+
+// A TagError is returned when a field has an invalid getopt tag.
+type TagError = tag.Error
+
+// fieldError returns err with the field set to field if err is a *TagError,
+// otherwise err is returned prefixed by field.
+func fieldError(field string, err error) error { return opterrors.Field(field, err) }
+
+// An UnsupportedTypeError is returned when a field is of a type that cannot be
+// used as an option.
+type UnsupportedTypeError = opterrors.UnsupportedTypeError
+
+// A DuplicateOptionError is returned when the option Name, including its
+// leading dashes, is declared by more than one field, or when Name is already
+// defined in the getopt set.  When the fields are in different structures the
+// field names are qualified by the name of their structure (e.g.,
+// theOptions.Name).  Other is empty if Name was registered directly with
+// getopt.
+type DuplicateOptionError = opterrors.DuplicateOptionError
+
+// An EncodingError is returned when a Flags field names an encoding that has
+// not been registered with RegisterEncoding.
+type EncodingError struct {
+	Field    string
+	Encoding string
+}
+
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("%s: unknown flags decoding type: %q", e.Field, e.Encoding)
+}
+
+// An UnknownOptionError is returned by Flags.Set when File refers to options
+// that are not registered and IgnoreUnknown is not set.  Names are the unknown
+// options, including the leading dashes.
+type UnknownOptionError struct {
+	File  string
+	Names []string
+}
+
+func (e *UnknownOptionError) Error() string {
+	return fmt.Sprintf("%s: unrecognized flags:\n    %s", e.File, strings.Join(e.Names, "\n    "))
+}
+
+// A DeprecatedOptionError is reported in the Warnings of a ParseResult, and
+// written to standard error by Parse, when a deprecated alias declared by
+// SetAlias or an alias-of tag is used.  Alias and Name include the leading
+// dashes.
+type DeprecatedOptionError struct {
+	Alias string
+	Name  string
+}
+
+func (e *DeprecatedOptionError) Error() string {
+	return fmt.Sprintf("%s is deprecated, use %s", e.Alias, e.Name)
+}
+
+// An ArgsError is returned when a set is parsed with a number of positional
+// arguments outside the range set by SetArgs or an args tag.  Max is -1 if
+// there is no maximum.
+type ArgsError struct {
+	Min, Max int
+	Got      int
+}
+
+func (e *ArgsError) Error() string {
+	switch {
+	case e.Min == e.Max:
+		return fmt.Sprintf("expected %s, got %d", plural(e.Min, "argument"), e.Got)
+	case e.Max < 0:
+		return fmt.Sprintf("expected at least %s, got %d", plural(e.Min, "argument"), e.Got)
+	case e.Min == 0:
+		return fmt.Sprintf("expected at most %s, got %d", plural(e.Max, "argument"), e.Got)
+	}
+	return fmt.Sprintf("expected %d to %d arguments, got %d", e.Min, e.Max, e.Got)
+}
+
+// An AmbiguousOptionError is returned when an abbreviated long option, see
+// SetAbbreviations, is the prefix of more than one long option.  Name and
+// Candidates include the leading dashes.
+type AmbiguousOptionError struct {
+	Name       string
+	Candidates []string
+}
+
+func (e *AmbiguousOptionError) Error() string {
+	return fmt.Sprintf("ambiguous option %s, candidates: %s", e.Name, strings.Join(e.Candidates, ", "))
+}
+
+// Errors is a list of errors.  It is returned when more than one problem is
+// found while registering an options structure.
+type Errors = opterrors.Errors
@@ -15,11 +15,17 @@ package options
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -35,6 +41,28 @@ import (
 // It is an error if the specified file does not exist unless the pathname is
 // prefixed with a ? (the ? is stripped), e.g., --flags=?my-flags.
 //
+// Rather than a file, the flags may be provided inline as a data URL, which is
+// useful for small sets of flags injected by orchestration systems.  The data
+// is either URL encoded (%0A is a newline) or, with ;base64, base64 encoded:
+//
+//	--flags=data:name=bob%0Av=true
+//	--flags=data:;base64,bmFtZT1ib2IKdj10cnVlCg==
+//
+// The flags may also be read from an environment variable, which is decoded
+// just as a file would be.  As with files, a leading ? makes it okay for the
+// variable to not be set:
+//
+//	--flags=env:APP_FLAGS
+//	--flags=?env:APP_FLAGS
+//
+// If the pathname is a directory, such as a mounted Kubernetes ConfigMap, each
+// file in the directory is an option and the contents of the file, less any
+// trailing newlines, is its value.  Subdirectories are named Sets.  The
+// encoding is not used.  Names that start with a period are ignored.
+//
+//	my-flags/name         # contains bob
+//	my-flags/child/name   # contains jim, sets --name in the Set named child
+//
 // The format of the flags file can be specified by either using the
 // SetEncoding method or by using the "encoding" struct Flags field tag.
 //
@@ -62,6 +90,19 @@ import (
 // getopt.Set is a single element of either getopt.CommandLine or the getopt.Set
 // passed to RegisterSet or returned by RegisterNew.
 //
+// The name of a Set may be a dotted name, such as "server.tls", to address a
+// nested map in the decoded file.  Given the following JSON the Set named
+// "server.tls" sets --cert to server.pem:
+//
+//	{
+//		"server": {
+//			"port": 443,
+//			"tls": {
+//				"cert": "server.pem"
+//			}
+//		}
+//	}
+//
 // The encoding can be changed from SimpleDecoder, a.k.a. "simple" by either
 // using the SetEncoding method or by specifying the registered encoding as
 // a struct tag to the Flags field in an options structure, e.g.:
@@ -72,32 +113,235 @@ import (
 // encoding.)
 //
 // Unless IgnoreUnknown is set, it is an error to pass in a JSON blob that
-// references an unknown option.
+// references an unknown option.  IgnoreUnknown can also be set by the flags
+// struct tag, which is a comma separated list of options:
+//
+//	Flags options.Flags `getopt:"--flags specify flags file" flags:"ignore-unknown"`
+//
+// Normally a value in a flags file never overrides a value set on the command
+// line.  If Override is set (or the flags tag includes "override") the values
+// in the file take precedence, as is needed for a mandatory policy file.  Since
+// options that follow the flags option on the command line are set after the
+// file is read, Override is only fully enforced by the functions in this
+// package that parse the command line, such as RegisterAndParse and ParseArgs,
+// which reapply the file after parsing.  Override is intended for options that
+// hold a single value.  Precedence can be used to make all flags files take
+// precedence over the command line.
+//
+//	Policy options.Flags `getopt:"--policy=PATH mandatory settings" flags:"override"`
+//
+// A program may have more than one Flags, such as a global defaults file and a
+// defaults file for each subcommand.  When more than one Flags sets the same
+// option the value from the Flags registered last is used, regardless of the
+// order the files are read.  A Flags declared later in a structure, or in a
+// structure registered later, such as that of a subcommand, takes precedence
+// over those registered before it.  Values given on the command line take
+// precedence over all flags files unless a Flags has Override set, in which
+// case it always sets the options.
+//
+//	Defaults options.Flags `getopt:"--defaults=PATH site defaults"`
+//	Flags    options.Flags `getopt:"--flags=PATH flags file, overrides --defaults"`
+//
+// A Flags may be shared by sets that are parsed concurrently, such as a
+// defaults Flags added to the Sets of several sets returned by RegisterNew.
+// The Flags in a structure duplicated by Dup or RegisterNew is independent of
+// the original: it has its own Sets and does not set the options of the
+// structure it was copied from.
+// Set, SetContext, Rescan, and String are safe to call from multiple
+// goroutines.  The exported fields must not be changed once the Flags is in
+// use.
 type Flags struct {
 	Sets          []Set
 	IgnoreUnknown bool
+	Override      bool
 	Decoder       FlagsDecoder
+	ReaderDecoder ReaderDecoder // if set, used instead of Decoder
+	mu            *sync.Mutex   // protects the fields below and Sets, see lock
 	path          string
 	opt           getopt.Option
 	m             map[string]interface{}
+	values        map[getopt.Option]string // options set by f and their file
+	unknown       *UnknownOptionError      // ignored unknown options
+	onUnknown     func(key, file string)   // called for each unknown key
+	changes       []change                 // changes to report, see OnChange
+	own           []*getopt.Set            // sets the structure of f was registered with
+	rank          int                      // precedence over other Flags, see setRank
 }
 
 var (
 	decoderMu sync.Mutex
-	decoders  = map[string]FlagsDecoder{"simple": SimpleDecoder}
+	decoders  = map[string]FlagsDecoder{
+		"simple":        SimpleDecoder,
+		"simple-strict": StrictSimpleDecoder,
+	}
 )
 
 // A FlagsDecoder the data in bytes as a set of key value pairs.  The values
 // must be type assertable to a strconv.TextMarshaller, a fmt.Stringer, a
-// string, a bool, or one of the non-complex numeric types (e.g., int).
+// string, a bool, or one of the non-complex numeric types (e.g., int).  A value
+// may also be a []string or a []interface{} of such values, in which case the
+// option is set once for each element, in order.
 type FlagsDecoder func([]byte) (map[string]interface{}, error)
 
+// A ReaderDecoder is like a FlagsDecoder but decodes the data read from r.
+// When a Flags has a ReaderDecoder, flags files are decoded as they are read
+// rather than first being read into memory.  This is useful for large or
+// streamed sources, such as pipes.  SimpleReaderDecoder is the ReaderDecoder
+// of SimpleDecoder.
+type ReaderDecoder func(r io.Reader) (map[string]interface{}, error)
+
 // RegisterEncoding registers the decoder dec with the specified name.  The
 // encoder is is specified using the "encoding" tag (e.g., `encoding:"name"`).
-func RegisterEncoding(name string, dec FlagsDecoder) {
+// An error is returned, and dec is not registered, if name is already
+// registered.
+func RegisterEncoding(name string, dec FlagsDecoder) error {
 	decoderMu.Lock()
+	defer decoderMu.Unlock()
+	if _, ok := decoders[name]; ok {
+		return fmt.Errorf("encoding %q already registered", name)
+	}
 	decoders[name] = dec
+	return nil
+}
+
+// MustRegisterEncoding is like RegisterEncoding but panics if name is already
+// registered.  It is intended to be called from an init function.
+func MustRegisterEncoding(name string, dec FlagsDecoder) {
+	if err := RegisterEncoding(name, dec); err != nil {
+		panic(err)
+	}
+}
+
+// lookupEncoding returns the decoder registered with name by RegisterEncoding.
+func lookupEncoding(name string) (FlagsDecoder, bool) {
+	decoderMu.Lock()
+	defer decoderMu.Unlock()
+	dec, ok := decoders[name]
+	return dec, ok
+}
+
+// An Encodings is a private registry of flags decoders.  It lets a library use
+// its own encodings, in the encoding tags of the options structures it
+// registers, without registering them with RegisterEncoding, where they might
+// conflict with the encodings registered by the application or other
+// libraries.  An Encodings is a RegisterOption:
+//
+//	var encodings = options.NewEncodings()
+//
+//	func init() {
+//		encodings.MustRegister("lib", libDecoder)
+//	}
+//
+//	func Register(set *getopt.Set) error {
+//		return options.RegisterSet("", &libOptions, set, encodings)
+//	}
+//
+// Encodings not registered with an Encodings are looked up in the encodings
+// registered with RegisterEncoding.  An Encodings is safe to use from multiple
+// goroutines.
+type Encodings struct {
+	mu       sync.Mutex
+	decoders map[string]FlagsDecoder
+}
+
+// NewEncodings returns a new, empty, Encodings.
+func NewEncodings() *Encodings {
+	return &Encodings{decoders: map[string]FlagsDecoder{}}
+}
+
+// Register registers the decoder dec with the specified name in e.  The name
+// may also be registered with RegisterEncoding, in which case e's decoder is
+// used for the options registered with e.  An error is returned, and dec is
+// not registered, if name is already registered in e.
+func (e *Encodings) Register(name string, dec FlagsDecoder) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.decoders[name]; ok {
+		return fmt.Errorf("encoding %q already registered", name)
+	}
+	e.decoders[name] = dec
+	return nil
+}
+
+// MustRegister is like Register but panics if name is already registered in
+// e.
+func (e *Encodings) MustRegister(name string, dec FlagsDecoder) {
+	if err := e.Register(name, dec); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the decoder registered with name in e, or, if there is none,
+// the decoder registered with name by RegisterEncoding.  Lookup returns false
+// if name is not registered with either.
+func (e *Encodings) Lookup(name string) (FlagsDecoder, bool) {
+	if e != nil {
+		e.mu.Lock()
+		dec, ok := e.decoders[name]
+		e.mu.Unlock()
+		if ok {
+			return dec, true
+		}
+	}
+	return lookupEncoding(name)
+}
+
+func (e *Encodings) config(c *regConfig) {
+	c.encodings = e
+}
+
+// ListEncodings returns the sorted names of all registered encodings.
+func ListEncodings() []string {
+	decoderMu.Lock()
+	names := make([]string, 0, len(decoders))
+	for name := range decoders {
+		names = append(names, name)
+	}
 	decoderMu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// A flagsSetter is the Flags that most recently set an option and its rank.
+type flagsSetter struct {
+	f    *Flags
+	rank int
+}
+
+var (
+	ranksMu  sync.Mutex
+	lastRank int
+	setters  = map[getopt.Option]flagsSetter{}
+)
+
+// setRank gives f precedence over every Flags registered before it.  f must
+// not be locked.
+func (f *Flags) setRank() {
+	ranksMu.Lock()
+	lastRank++
+	rank := lastRank
+	ranksMu.Unlock()
+	f.lock()
+	f.rank = rank
+	f.unlock()
+}
+
+// outranked returns the Flags, other than f, that set o and has precedence
+// over f, or nil.  f must be locked.
+func (f *Flags) outranked(o getopt.Option) *Flags {
+	ranksMu.Lock()
+	defer ranksMu.Unlock()
+	if s, ok := setters[o]; ok && s.f != f && s.rank > f.rank {
+		return s.f
+	}
+	return nil
+}
+
+// setSetter records that f set o.  f must be locked.
+func (f *Flags) setSetter(o getopt.Option) {
+	ranksMu.Lock()
+	setters[o] = flagsSetter{f: f, rank: f.rank}
+	ranksMu.Unlock()
 }
 
 // NewFlags returns a new Flags registered on the standard CommandLine as a long
@@ -116,6 +360,7 @@ func NewFlags(name string) *Flags {
 		Decoder: SimpleDecoder,
 	}
 	flags.opt = getopt.FlagLong(flags, name, 0, "file containing command line parameters")
+	flags.setRank()
 	return flags
 }
 
@@ -125,6 +370,64 @@ type Set struct {
 	*getopt.Set
 }
 
+var (
+	namedSetsMu sync.Mutex
+	namedSets   = map[string]*getopt.Set{}
+)
+
+// NewSet returns a new getopt.Set registered with name, or the set already
+// registered with name.  A Flags applies the values in its file under name
+// (e.g., backup.level) to the set even if the set is not in its Sets:
+//
+//	set := options.NewSet("backup")
+//	options.RegisterSet("backup", &backupOptions, set)
+func NewSet(name string) *getopt.Set {
+	namedSetsMu.Lock()
+	defer namedSetsMu.Unlock()
+	set, ok := namedSets[name]
+	if !ok {
+		set = getopt.New()
+		namedSets[name] = set
+	}
+	return set
+}
+
+// LookupSet returns the set registered with name by NewSet, or nil.
+func LookupSet(name string) *getopt.Set {
+	namedSetsMu.Lock()
+	defer namedSetsMu.Unlock()
+	return namedSets[name]
+}
+
+// allSets returns f.Sets followed by, in name order, the sets registered by
+// NewSet whose name and set are not in f.Sets.  f must be locked.
+func (f *Flags) allSets() []Set {
+	sets := append([]Set{}, f.Sets...)
+	known := map[string]bool{}
+	for _, s := range f.Sets {
+		known[s.Name] = true
+	}
+	namedSetsMu.Lock()
+	defer namedSetsMu.Unlock()
+	var names []string
+Names:
+	for name, set := range namedSets {
+		for _, s := range f.Sets {
+			if s.Set == set {
+				continue Names
+			}
+		}
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sets = append(sets, Set{Name: name, Set: namedSets[name]})
+	}
+	return sets
+}
+
 // SetEncoding returns f after setting the decoding function to decoder.
 // For example:
 //
@@ -134,9 +437,71 @@ func (f *Flags) SetEncoding(decoder FlagsDecoder) *Flags {
 	return f
 }
 
+// SetReaderEncoding returns f after setting the streaming decoding function to
+// decoder, which is used in place of f.Decoder.  For example:
+//
+//	flags := options.NewFlags("flags").SetReaderEncoding(options.SimpleReaderDecoder)
+func (f *Flags) SetReaderEncoding(decoder ReaderDecoder) *Flags {
+	f.ReaderDecoder = decoder
+	return f
+}
+
+// OnUnknown returns f after setting fn to be called with each unknown key, in
+// sorted order, found when reading the flags file named file.  Nested keys are
+// dotted, e.g., server.port.  fn is called whether or not IgnoreUnknown is
+// set, which makes it possible to log stale keys without failing.  fn must not
+// call the methods of f:
+//
+//	flags := options.NewFlags("flags").OnUnknown(func(key, file string) {
+//		log.Printf("%s: ignoring unknown flag %s", file, key)
+//	})
+//	flags.IgnoreUnknown = true
+func (f *Flags) OnUnknown(fn func(key, file string)) *Flags {
+	f.onUnknown = fn
+	return f
+}
+
+// flagsMu protects the creation of the mutex of each Flags.  The mutex is a
+// pointer, created on first use, so Flags values can still be copied, as is
+// done when comparing options structures.
+var flagsMu sync.Mutex
+
+// lock locks f.
+func (f *Flags) lock() {
+	flagsMu.Lock()
+	if f.mu == nil {
+		f.mu = &sync.Mutex{}
+	}
+	mu := f.mu
+	flagsMu.Unlock()
+	mu.Lock()
+}
+
+// unlock unlocks f, which must be locked.
+func (f *Flags) unlock() {
+	f.mu.Unlock()
+}
+
+// unlockNotify unlocks f and then reports the changes made while f was locked
+// to the functions registered by OnChange.
+func (f *Flags) unlockNotify() {
+	changes := f.changes
+	f.changes = nil
+	f.mu.Unlock()
+	notify(changes)
+}
+
 // rescanFlags is the magic path name passed to set to cause it to
-// re-scan options but not read a file.
-var rescanFlags = string("\000\000\000")
+// re-scan options but not read a file.  reapplyFlags is similar but only
+// options that were seen on the command line are set, see overrideFlags.
+// attachFlags is also similar but unknown options are not reported, see
+// attach.  loadedFlags treats the values in f.m as newly read, see SetMap.
+var (
+	rescanFlags  = string("\000\000\000")
+	reapplyFlags = string("\000\000\001")
+	attachFlags  = string("\000\000\002")
+	loadedFlags  = string("\000\000\003")
+)
 
 // Set implements getopt.Value.  Set can be called directly by passing a nil
 // getopt.Option.  Set is a no-op if value is the empty string.  Set does
@@ -159,6 +524,21 @@ var rescanFlags = string("\000\000\000")
 //
 //	options.NewFlags("flags").Set("?${HOME}/.my.flags", nil)
 func (f *Flags) Set(value string, opt getopt.Option) error {
+	return f.SetContext(parseContext(opt), value, opt)
+}
+
+// SetContext is like Set but gives up reading and decoding the flags, and
+// returns ctx.Err(), if ctx is done first.  This bounds the time spent on slow
+// storage or a slow decoder.  Set uses the context passed to ParseContext or
+// SubParseContext, if called from them, otherwise context.Background().
+func (f *Flags) SetContext(ctx context.Context, value string, opt getopt.Option) error {
+	f.lock()
+	defer f.unlockNotify()
+	return f.set(ctx, value, opt, f.allSets())
+}
+
+// set implements SetContext for the options in sets.  f.mu must be held.
+func (f *Flags) set(ctx context.Context, value string, opt getopt.Option, sets []Set) error {
 	value = expand(value)
 	if value == "" || value == "?" {
 		return nil
@@ -172,29 +552,29 @@ func (f *Flags) Set(value string, opt getopt.Option) error {
 		return nil
 	}
 
-	if value == rescanFlags {
+	reapply := value == reapplyFlags
+	attach := value == attachFlags
+	rescan := value == rescanFlags || reapply || attach
+	if rescan || value == loadedFlags {
 		value = f.path
+		tracef("%s: applying previously read values", value)
 	} else {
-		var data []byte
-		var err error
-
-		switch value[0] {
-		case '?': // okay for the file
+		optional := value[0] == '?' // okay for the file to not exist
+		if optional {
 			value = value[1:]
-			data, err = ioutil.ReadFile(value)
-			if err != nil {
-				return nil
-			}
-		default: // filename
-			data, err = ioutil.ReadFile(value)
-			if err != nil {
-				return err
-			}
 		}
+		m, found, err := f.read(ctx, value, optional)
+		if err != nil {
+			return err
+		}
+		if !found {
+			tracef("%s: optional file not found", value)
+			return nil
+		}
+		tracef("%s: read", value)
 
 		f.path = value
-		data = bytes.TrimSpace(data)
-		if len(data) == 0 {
+		if m == nil {
 			return nil
 		}
 
@@ -203,145 +583,669 @@ func (f *Flags) Set(value string, opt getopt.Option) error {
 		// map that contains subsets of flags that we don't know about
 		// yet.  By keeping the merged list of options that we have seen
 		// we can re-play after the subset is registered.
-		m, err := f.Decoder(data)
-		if err != nil {
-			return fmt.Errorf("%s: %v", value, err)
-		}
 		f.m = mergemap(f.m, m)
 	}
 
 	// Now make a duplicate to work with.
 	m := mergemap(nil, f.m)
 
-	// matched is the names of subsets that we found
-	matched := map[string]bool{}
-	for _, set := range f.Sets {
+	for _, set := range sets {
 		var err error
 		// So we don't forget the original map
 		m := m
-		matched[set.Name] = true
 		if set.Name != "" {
-			switch sm := m[set.Name].(type) {
-			case nil:
-				continue
-			case map[string]interface{}:
-				m = sm
-			default:
+			sm, ok := submap(m, set.Name)
+			if !ok {
+				tracef("%s: no values for set %s", value, set.Name)
 				continue
 			}
+			m = sm
 		}
 		set.VisitAll(func(o getopt.Option) {
-			if err != nil {
+			if err != nil || isDisabled(o) {
 				return
 			}
-			var v interface{}
-			var ok bool
-			n := o.LongName()
-			if n != "" {
-				v, ok = m[n]
-			}
-			if !ok {
-				n = o.ShortName()
-				if n != "" {
-					v, ok = m[n]
-				}
-			}
+			v, ok := takeValue(m, o)
 			if !ok {
 				return
 			}
-			delete(m, n)
-
-			type Stringer interface {
-				String() string
+			if o.Value() == getopt.Value(f) {
+				// f cannot set itself.
+				return
 			}
-			type TextMarshaler interface {
-				MarshalText() (text []byte, err error)
+			var ss []string
+			if ss, err = flagStrings(v); err != nil {
+				return
 			}
-
-			var s string
-			switch v := v.(type) {
-			case TextMarshaler:
-				var data []byte
-				data, err = v.MarshalText()
-				if err != nil {
-					return
+			switch {
+			case o.Seen() && !f.override():
+				// Don't override set values
+				if tracing() {
+					tracef("%s: %s not set, it was set on the command line", value, o.Name())
 				}
-				s = string(data)
-			case Stringer:
-				s = v.String()
-			case string:
-				s = v
-			case float64, float32,
-				int, int64, int32, int16, int8,
-				uint, uint64, uint32, uint16, uint8:
-				s = fmt.Sprintf("%v", v)
-			case bool:
-				if v {
-					s = "true"
-				} else {
-					s = "false"
+				return
+			case reapply && !o.Seen():
+				// Only values from the command line need to be
+				// overridden again.
+				return
+			case !f.override() && f.outranked(o) != nil:
+				if tracing() {
+					tracef("%s: %s not set, it was set by a later registered flags option", value, o.Name())
 				}
-			default:
-				err = fmt.Errorf("%s: %T not a string or number", value, v)
 				return
 			}
-			// Don't override set values
-			if o.Seen() {
+			switch {
+			case !tracing():
+			case isSecret(o):
+				tracef("%s: %s set to a secret", value, o.Name())
+			default:
+				tracef("%s: %s set to %q", value, o.Name(), ss)
+			}
+			fns := onChange(set.Set, o)
+			old := ""
+			if fns != nil {
+				old = o.String()
+			}
+			if err = setValues(o, ss); err != nil {
+				err = fmt.Errorf("%s: %s: %w", value, o.Name(), err)
 				return
 			}
-			o.Value().Set(s, o)
+			if fns != nil {
+				if n := o.String(); n != old {
+					f.changes = append(f.changes, change{fns: fns, old: old, new: n})
+				}
+			}
+			if g, ok := o.Value().(genericOption); ok {
+				g.setSource(SourceFile)
+			}
+			if f.values == nil {
+				f.values = map[getopt.Option]string{}
+			}
+			f.values[o] = f.path
+			f.setSetter(o)
 		})
 		if err != nil {
 			return err
 		}
 	}
-
-	if f.IgnoreUnknown {
+	if attach {
 		return nil
 	}
 
 	// Determine if there are any unknown global flags or flags for this
 	// particular sub-command.  We ignore all other sets of flags.
-	names := make([]string, 1, len(m)+1)
-	names[0] = fmt.Sprintf("%s: unrecognized flags:", value)
+	names := unknownNames(nil, "", m)
+	f.unknown = nil
+	setPending(f, len(names) > 0)
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tracef("%s: unknown option %s", value, name)
+	}
+	if f.onUnknown != nil && !rescan {
+		for _, name := range names {
+			f.onUnknown(strings.TrimPrefix(name, "--"), value)
+		}
+	}
+	err := &UnknownOptionError{File: value, Names: names}
+	if f.IgnoreUnknown {
+		// Remember the unknown options so they can be reported as
+		// warnings by ParseArgs.
+		f.unknown = err
+		return nil
+	}
+	return err
+}
+
+// setValues sets o to each of the values in ss.  The values for a []string
+// option are set at once, as a list discards its current contents each time
+// it is set while its option has not been seen.
+func setValues(o getopt.Option, ss []string) error {
+	if l, ok := o.Value().(*listValue); ok {
+		return l.set(o, ss)
+	}
+	for _, s := range ss {
+		if err := o.Value().Set(s, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads and decodes the flags named by value, see readFlags.  found is
+// false if value does not exist and optional is true.  m is nil if there are
+// no flags.
+func (f *Flags) read(ctx context.Context, value string, optional bool) (m map[string]interface{}, found bool, err error) {
+	if f.ReaderDecoder != nil {
+		return f.readStream(ctx, value, optional)
+	}
+	var data []byte
+	err = withContext(ctx, func() (err error) {
+		data, m, found, err = readFlags(value, optional)
+		return err
+	})
+	if err != nil || !found || m != nil {
+		return m, found, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, true, nil
+	}
+	err = withContext(ctx, func() (err error) {
+		m, err = f.Decoder(data)
+		return err
+	})
+	if err != nil {
+		return nil, true, fmt.Errorf("%s: %w", value, err)
+	}
+	return m, true, nil
+}
+
+// readStream is like read but decodes value with f.ReaderDecoder as it is
+// read.
+func (f *Flags) readStream(ctx context.Context, value string, optional bool) (m map[string]interface{}, found bool, err error) {
+	var r io.ReadCloser
+	err = withContext(ctx, func() (err error) {
+		r, m, found, err = openFlags(value, optional)
+		return err
+	})
+	if err != nil || !found || m != nil {
+		return m, found, err
+	}
+	defer r.Close()
+	err = withContext(ctx, func() (err error) {
+		m, err = f.ReaderDecoder(r)
+		return err
+	})
+	if err != nil {
+		return nil, true, fmt.Errorf("%s: %w", value, err)
+	}
+	return m, true, nil
+}
+
+// takeValue removes and returns the value in m for o, looked up by o's long
+// name and then by its short name.
+func takeValue(m map[string]interface{}, o getopt.Option) (interface{}, bool) {
+	for _, n := range []string{o.LongName(), o.ShortName()} {
+		if n == "" {
+			continue
+		}
+		if v, ok := m[n]; ok {
+			delete(m, n)
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// flagStrings returns v, a value decoded from a flags file, as the strings to
+// pass to the Set method of a getopt.Value.  Repeated keys (e.g., from
+// SimpleDecoder) and arrays (e.g., from JSON) set the option once per value.
+func flagStrings(v interface{}) ([]string, error) {
+	switch l := v.(type) {
+	case []string:
+		return l, nil
+	case []interface{}:
+		ss := make([]string, len(l))
+		for i, v := range l {
+			var err error
+			if ss[i], err = flagString(v); err != nil {
+				return nil, err
+			}
+		}
+		return ss, nil
+	}
+	s, err := flagString(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// submap returns the map in m named by the dotted name, e.g., "server.tls"
+// is m["server"]["tls"].
+func submap(m map[string]interface{}, name string) (map[string]interface{}, bool) {
+	for _, n := range strings.Split(name, ".") {
+		sm, ok := m[n].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = sm
+	}
+	return m, true
+}
+
+// unknownNames appends to names the dotted names, as options, of all the
+// values remaining in m, at any depth.
+func unknownNames(names []string, prefix string, m map[string]interface{}) []string {
 	for k, v := range m {
-		// TODO(borman): are we handling suboptions correctly here?
-		// if !matched[k] {
-		// 	continue
-		// }
-		sm, ok := v.(map[string]interface{})
+		if sm, ok := v.(map[string]interface{}); ok {
+			names = unknownNames(names, prefix+k+".", sm)
+			continue
+		}
+		names = append(names, "--"+prefix+k)
+	}
+	return names
+}
+
+// flagString returns v, a value decoded from a flags file, as a string that can
+// be passed to the Set method of a getopt.Value.
+func flagString(v interface{}) (string, error) {
+	type Stringer interface {
+		String() string
+	}
+	type TextMarshaler interface {
+		MarshalText() (text []byte, err error)
+	}
+
+	switch v := v.(type) {
+	case TextMarshaler:
+		data, err := v.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case Stringer:
+		return v.String(), nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("%T not a string or number", v)
+}
+
+// readFlags reads the flags named by value, which is either a data URL, an
+// environment variable (env:NAME), a directory, or a file.  A directory is
+// returned as a map, otherwise the data to be decoded is returned.  found is
+// false if value does not exist and optional is true.
+func readFlags(value string, optional bool) (data []byte, m map[string]interface{}, found bool, err error) {
+	switch {
+	case strings.HasPrefix(value, "data:"):
+		data, err = inlineData(value[len("data:"):])
+	case strings.HasPrefix(value, "env:"):
+		name := value[len("env:"):]
+		s, ok := os.LookupEnv(name)
 		if !ok {
-			names = append(names, "--"+k)
+			if optional {
+				return nil, nil, false, nil
+			}
+			return nil, nil, false, fmt.Errorf("environment variable %s is not set", name)
+		}
+		data = []byte(s)
+	default: // filename
+		if fi, serr := os.Stat(value); serr == nil && fi.IsDir() {
+			m, err = readDir(value)
+			break
+		}
+		data, err = ioutil.ReadFile(value)
+		if err != nil && optional {
+			return nil, nil, false, nil
+		}
+	}
+	return data, m, err == nil, err
+}
+
+// openFlags is like readFlags but returns a reader of the data to decode
+// rather than the data.  A file is opened but not read.
+func openFlags(value string, optional bool) (r io.ReadCloser, m map[string]interface{}, found bool, err error) {
+	if strings.HasPrefix(value, "data:") || strings.HasPrefix(value, "env:") {
+		data, m, found, err := readFlags(value, optional)
+		return ioutil.NopCloser(bytes.NewReader(data)), m, found, err
+	}
+	if fi, serr := os.Stat(value); serr == nil && fi.IsDir() {
+		m, err = readDir(value)
+		return nil, m, err == nil, err
+	}
+	fd, err := os.Open(value)
+	if err != nil {
+		if optional {
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, err
+	}
+	return fd, nil, true, nil
+}
+
+// withContext calls fn and returns its error.  If ctx is done before fn
+// returns, ctx.Err() is returned without waiting for fn.  In that case fn must
+// not modify anything that is used once withContext returns.
+func withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+	c := make(chan error, 1)
+	go func() { c <- fn() }()
+	select {
+	case err := <-c:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readDir returns the flags in the directory dir.  Each file in dir is an
+// option whose value is the contents of the file, without trailing newlines.
+// Each subdirectory is a nested map, i.e., a named Set.  Names starting with a
+// period are skipped, such as the ..data directory of a Kubernetes ConfigMap
+// volume.  Symbolic links are followed.
+func readDir(dir string) (map[string]interface{}, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	for _, fi := range fis {
+		name := fi.Name()
+		if strings.HasPrefix(name, ".") {
 			continue
 		}
-		for sk := range sm {
-			names = append(names, "--"+k+"."+sk)
+		path := filepath.Join(dir, name)
+		if fi, err = os.Stat(path); err != nil {
+			return nil, err
 		}
+		if fi.IsDir() {
+			if m[name], err = readDir(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = strings.TrimRight(string(data), "\r\n")
 	}
-	if len(names) == 1 {
-		return nil
+	return m, nil
+}
+
+// inlineData returns the data in the data URL s, without its "data:" prefix.
+// Media types are not supported, s is either ;base64, followed by base64
+// encoded data or URL encoded data, optionally preceded by a comma.
+func inlineData(s string) ([]byte, error) {
+	if strings.HasPrefix(s, ";base64,") {
+		data, err := base64.StdEncoding.DecodeString(s[len(";base64,"):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 flags data: %v", err)
+		}
+		return data, nil
+	}
+	data, err := url.PathUnescape(strings.TrimPrefix(s, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid flags data: %v", err)
 	}
-	sort.Strings(names[1:])
-	return errors.New(strings.Join(names, "\n    "))
+	return []byte(data), nil
+}
+
+// finishParse is called after set has been parsed.  It applies the flags
+// files that override the command line, displays the configuration if a
+// DumpConfig option was seen, and then checks the number of arguments.
+func finishParse(set *getopt.Set) error {
+	if err := overrideFlags(set); err != nil {
+		return err
+	}
+	setParsed(set)
+	dumpConfig(set)
+	return checkArgs(set)
+}
+
+// overrideFlags reapplies the flags files in set that have Override set to the
+// options that were set on the command line.  It is called after set has been
+// parsed.
+func overrideFlags(set *getopt.Set) error {
+	var err error
+	set.VisitAll(func(o getopt.Option) {
+		f, ok := o.Value().(*Flags)
+		if !ok || !f.override() || err != nil {
+			return
+		}
+		err = f.Set(reapplyFlags, nil)
+	})
+	return err
 }
 
 // Rescan sets values in set from the values previously set in f.
 func (f *Flags) Rescan(name string, set *getopt.Set) error {
-	osets := f.Sets
-	defer func() { f.Sets = osets }()
-	f.Sets = []Set{{
+	f.lock()
+	defer f.unlockNotify()
+	return f.set(context.Background(), rescanFlags, nil, []Set{{
 		Name: name,
 		Set:  set,
-	}}
-	return f.Set(rescanFlags, nil)
+	}})
+}
+
+// SetMap is like Set but uses the already decoded values in m rather than
+// reading and decoding a file.  name is used in place of the file name in
+// errors and in ParseResult.Files.  SetMap lets other configuration systems
+// supply values while f remains responsible for precedence, e.g., values in m
+// do not override options set on the command line.
+func (f *Flags) SetMap(name string, m map[string]interface{}) error {
+	f.lock()
+	defer f.unlockNotify()
+	f.path = name
+	f.m = mergemap(f.m, m)
+	return f.set(context.Background(), loadedFlags, nil, f.allSets())
+}
 
+// SetReader is like SetMap but the values are decoded from r, as they are read,
+// using f.ReaderDecoder, or f.Decoder if f has no ReaderDecoder.  SetReader is
+// useful when the values do not come from a file, such as the body of a
+// network request.
+func (f *Flags) SetReader(name string, r io.Reader) error {
+	f.lock()
+	dec, rdec := f.Decoder, f.ReaderDecoder
+	f.unlock()
+	var m map[string]interface{}
+	var err error
+	if rdec != nil {
+		m, err = rdec(r)
+	} else {
+		var data []byte
+		if data, err = ioutil.ReadAll(r); err == nil {
+			m, err = dec(bytes.TrimSpace(data))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return f.SetMap(name, m)
+}
+
+// RescanAll sets values in all of f.Sets, and the sets registered by NewSet,
+// from the values previously set in f.
+func (f *Flags) RescanAll() error {
+	f.lock()
+	defer f.unlockNotify()
+	return f.set(context.Background(), rescanFlags, nil, f.allSets())
+}
+
+var (
+	attachMu sync.Mutex
+	attached []*Flags            // Flags that attach newly registered sets
+	pending  = map[*Flags]bool{} // Flags that read values for unknown sets
+)
+
+// setPending records whether f has read values that did not match any option,
+// possibly because they are for a set that has not yet been registered.
+func setPending(f *Flags, p bool) {
+	attachMu.Lock()
+	if p {
+		pending[f] = true
+	} else {
+		delete(pending, f)
+	}
+	attachMu.Unlock()
+}
+
+// hasSection reports if f has read values for the set named name.
+func (f *Flags) hasSection(name string) bool {
+	f.lock()
+	defer f.unlock()
+	_, ok := submap(f.m, name)
+	return ok
+}
+
+// AttachNewSets returns f after arranging for each set that is later registered
+// with a name, by RegisterSet or RegisterNew, to be added to f.Sets under that
+// name.  Without AttachNewSets, a set is only attached to a Flags that has
+// already read values for the set's name, such as the values of sub.level in a
+// file read before the set named sub is registered.  The values already read by
+// f are applied to the new set, so options of a subcommand registered after the
+// flags file was read still get their values from the file.  The "attach-sets"
+// flags struct tag has the same effect:
+//
+//	Flags options.Flags `getopt:"--flags specify flags file" flags:"attach-sets"`
+func (f *Flags) AttachNewSets() *Flags {
+	attachMu.Lock()
+	defer attachMu.Unlock()
+	for _, af := range attached {
+		if af == f {
+			return f
+		}
+	}
+	attached = append(attached, f)
+	return f
+}
+
+// attachSet attaches set, registered with name, to each Flags that called
+// AttachNewSets and to each Flags that has read values for name.
+func attachSet(name string, set *getopt.Set) error {
+	attachMu.Lock()
+	fs := append([]*Flags{}, attached...)
+	var ps []*Flags
+	for f := range pending {
+		ps = append(ps, f)
+	}
+	attachMu.Unlock()
+	for _, f := range ps {
+		if !containsFlags(fs, f) && f.hasSection(name) {
+			fs = append(fs, f)
+		}
+	}
+	for _, f := range fs {
+		if err := f.attach(name, set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsFlags reports if fs contains f.
+func containsFlags(fs []*Flags, f *Flags) bool {
+	for _, ff := range fs {
+		if ff == f {
+			return true
+		}
+	}
+	return false
+}
+
+// attach adds set to f.Sets, unless already present, and sets its options
+// from the values previously read by f.
+func (f *Flags) attach(name string, set *getopt.Set) error {
+	f.lock()
+	defer f.unlockNotify()
+	for _, s := range f.Sets {
+		if s.Set == set {
+			return nil
+		}
+	}
+	f.Sets = append(f.Sets, Set{Name: name, Set: set})
+	if f.m == nil || f.opt == nil {
+		return nil
+	}
+	return f.set(context.Background(), attachFlags, nil, []Set{{Name: name, Set: set}})
 }
 
 // String implements getopt.Value.
 func (f *Flags) String() string {
+	f.lock()
+	defer f.unlock()
 	return f.path
 }
 
+// reset makes f forget the values it has read, as if it has never been set.
+func (f *Flags) reset() {
+	f.lock()
+	defer f.unlock()
+	ranksMu.Lock()
+	for o := range f.values {
+		if s, ok := setters[o]; ok && s.f == f {
+			delete(setters, o)
+		}
+	}
+	ranksMu.Unlock()
+	setPending(f, false)
+	f.path = ""
+	f.m = nil
+	f.values = nil
+	f.unknown = nil
+	f.changes = nil
+}
+
+// clone returns a copy of f for a duplicate of the structure containing f, see
+// Dup.  The copy has its own lock and its own Sets, which do not include the
+// sets the structure of f was registered with, so the copy does not set the
+// options of the original.  The sets attached to f in other ways, such as by
+// NewSet, are kept.  The values f has read are copied, so RescanAll applies
+// them to the sets the copy is registered with.
+func (f *Flags) clone() Flags {
+	f.lock()
+	defer f.unlock()
+	own := map[*getopt.Set]bool{}
+	for _, set := range f.own {
+		own[set] = true
+	}
+	var sets []Set
+	for _, s := range f.Sets {
+		if !own[s.Set] {
+			sets = append(sets, s)
+		}
+	}
+	c := Flags{
+		Sets:          sets,
+		IgnoreUnknown: f.IgnoreUnknown,
+		Override:      f.Override,
+		Decoder:       f.Decoder,
+		ReaderDecoder: f.ReaderDecoder,
+		path:          f.path,
+		onUnknown:     f.onUnknown,
+	}
+	if f.m != nil {
+		c.m = mergemap(nil, f.m)
+	}
+	return c
+}
+
 // mergemap merges the entries in old into new and returns new.  If new is
 // nil then a new map is created.
 func mergemap(new, old map[string]interface{}) map[string]interface{} {
@@ -361,27 +1265,35 @@ func mergemap(new, old map[string]interface{}) map[string]interface{} {
 // It supports ${NAME} and ${NAME:-VALUE}.  If VALUE is provided then it is used
 // if NAME is either empty or not set.  User "${$" to represent a literal "${".
 func expand(s string) string {
-	var parts []string
+	x := strings.Index(s, "${") // }
+	if x < 0 || x+2 == len(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
 	for {
-		x := strings.Index(s, "${") // }
 		if x < 0 || x+2 == len(s) {
-			return strings.Join(append(parts, s), "")
+			b.WriteString(s)
+			return b.String()
 		}
 		if s[x+2] == '$' {
-			parts = append(parts, s[:x+2])
+			b.WriteString(s[:x+2])
 			s = s[x+3:]
+			x = strings.Index(s, "${") // }
 			continue
 		}
-		parts = append(parts, s[:x])
+		b.WriteString(s[:x])
 		s = s[x+2:]
 		// {
 		x = strings.Index(s, "}")
 		if x < 0 {
-			return strings.Join(append(parts, "${", s), "") // }
+			b.WriteString("${") // }
+			b.WriteString(s)
+			return b.String()
 		}
-		var name, value string
-		name = s[:x]
+		name := s[:x]
 		s = s[x+1:]
+		var value string
 		if x := strings.Index(name, ":-"); x >= 0 {
 			value = name[x+2:]
 			name = name[:x]
@@ -389,6 +1301,7 @@ func expand(s string) string {
 		if env := os.Getenv(name); env != "" {
 			value = env
 		}
-		parts = append(parts, value)
+		b.WriteString(value)
+		x = strings.Index(s, "${") // }
 	}
 }
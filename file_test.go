@@ -14,12 +14,16 @@
 package options
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -277,6 +281,16 @@ func TestFlagsBadField(t *testing.T) {
 	if err == nil {
 		t.Errorf("did not get error for unknown flags")
 	}
+
+	getopt.CommandLine = getopt.New()
+	err = NewFlags("flags").Set(tmpfile, nil)
+	var ue *UnknownOptionError
+	if !errors.As(err, &ue) {
+		t.Fatalf("got error %v of type %T, want %T", err, err, ue)
+	}
+	if ue.File != tmpfile || !reflect.DeepEqual(ue.Names, []string{"--name"}) {
+		t.Errorf("got %+v, want file %q names [--name]", ue, tmpfile)
+	}
 }
 
 func TestFlagsSet(t *testing.T) {
@@ -377,6 +391,85 @@ func TestExpand(t *testing.T) {
 	}
 }
 
+func BenchmarkExpand(b *testing.B) {
+	os.Setenv("V1", "value1")
+	b.Run("plain", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			expand("/etc/widget/flags")
+		}
+	})
+	b.Run("variables", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			expand("${HOME}/${V1}/${V0:-default}/flags")
+		}
+	})
+}
+
+func TestFlagString(t *testing.T) {
+	for _, tt := range []struct {
+		in  interface{}
+		out string
+	}{
+		{"s", "s"},
+		{true, "true"},
+		{false, "false"},
+		{42, "42"},
+		{int8(-8), "-8"},
+		{int16(-16), "-16"},
+		{int32(-32), "-32"},
+		{int64(-64), "-64"},
+		{uint(1), "1"},
+		{uint8(8), "8"},
+		{uint16(16), "16"},
+		{uint32(32), "32"},
+		{uint64(64), "64"},
+		{float32(1.5), "1.5"},
+		{0.1, "0.1"},
+		{1e21, "1e+21"},
+	} {
+		out, err := flagString(tt.in)
+		if err != nil {
+			t.Errorf("%T %v: %v", tt.in, tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("%T %v: got %q, want %q", tt.in, tt.in, out, tt.out)
+		}
+	}
+}
+
+func BenchmarkFlagsRescan(b *testing.B) {
+	type options struct {
+		Flags   Flags         `getopt:"--flags"`
+		Name    string        `getopt:"--name"`
+		Count   int           `getopt:"--count"`
+		Ratio   float64       `getopt:"--ratio"`
+		Verbose bool          `getopt:"-v"`
+		Timeout time.Duration `getopt:"--timeout"`
+	}
+	opts := &options{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		b.Fatal(err)
+	}
+	if err := opts.Flags.SetMap("bench", map[string]interface{}{
+		"name":    "bob",
+		"count":   42,
+		"ratio":   0.5,
+		"v":       true,
+		"timeout": "5s",
+	}); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := opts.Flags.Rescan("", set); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func testDecoder(data []byte) (map[string]interface{}, error) {
 	return map[string]interface{}{
 		"tm": &TM{"tmvalue"},
@@ -469,13 +562,13 @@ func TestDecoderErr(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer os.Remove(tmpfile)
-	RegisterEncoding("testdecode", func(data []byte) (map[string]interface{}, error) {
+	RegisterEncoding("testdecode-err", func(data []byte) (map[string]interface{}, error) {
 		return map[string]interface{}{
 			"v": struct{ A int }{A: 42},
 		}, nil
 	})
 	type options struct {
-		Flags Flags  `getopt:"--flags" encoding:"testdecode"`
+		Flags Flags  `getopt:"--flags" encoding:"testdecode-err"`
 		V     string `getopt:"-v"`
 	}
 	_, set := RegisterNew("", &options{})
@@ -495,13 +588,13 @@ func TestDecoderErr2(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer os.Remove(tmpfile)
-	RegisterEncoding("testdecode", func(data []byte) (map[string]interface{}, error) {
+	RegisterEncoding("testdecode-err2", func(data []byte) (map[string]interface{}, error) {
 		return map[string]interface{}{
 			"tm": &TM{"error"},
 		}, nil
 	})
 	type options struct {
-		Flags Flags  `getopt:"--flags" encoding:"testdecode"`
+		Flags Flags  `getopt:"--flags" encoding:"testdecode-err2"`
 		TM    string `getopt:"--tm"`
 	}
 	_, set := RegisterNew("", &options{})
@@ -521,11 +614,11 @@ func TestDecoderErr3(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer os.Remove(tmpfile)
-	RegisterEncoding("testdecode", func(data []byte) (map[string]interface{}, error) {
+	RegisterEncoding("testdecode-err3", func(data []byte) (map[string]interface{}, error) {
 		return nil, tmErr
 	})
 	type options struct {
-		Flags Flags `getopt:"--flags" encoding:"testdecode"`
+		Flags Flags `getopt:"--flags" encoding:"testdecode-err3"`
 	}
 	_, set := RegisterNew("", &options{})
 	err = set.Getopt([]string{"test", "--flags", tmpfile}, nil)
@@ -581,3 +674,636 @@ func TestFlagsSetError(t *testing.T) {
 		}
 	}()
 }
+
+func TestFlagsBoolSpellings(t *testing.T) {
+	tmpfile, err := mkFile("verbose=yes\nquiet=Off\ndebug=on\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	type options struct {
+		Flags   Flags `getopt:"--flags"`
+		Verbose bool  `getopt:"--verbose"`
+		Quiet   bool  `getopt:"--quiet"`
+		Debug   bool  `getopt:"--debug"`
+	}
+	opts := &options{Quiet: true}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--flags", tmpfile}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Verbose || opts.Quiet || !opts.Debug {
+		t.Errorf("got %+v, want verbose and debug", *opts)
+	}
+}
+
+func TestFlagsRepeated(t *testing.T) {
+	tmpfile, err := mkFile("include = a\ninclude = b\nname = first\nname = last\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	type options struct {
+		Flags   Flags    `getopt:"--flags"`
+		Include []string `getopt:"--include"`
+		Name    string   `getopt:"--name"`
+	}
+	opts := &options{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--flags", tmpfile}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(opts.Include, want) {
+		t.Errorf("got include %q, want %q", opts.Include, want)
+	}
+	if opts.Name != "last" {
+		t.Errorf("got name %q, want %q", opts.Name, "last")
+	}
+}
+
+func TestFlagsBadValue(t *testing.T) {
+	tmpfile, err := mkFile("count = many\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	type options struct {
+		Flags Flags `getopt:"--flags"`
+		Count int   `getopt:"--count"`
+	}
+	opts := &options{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--flags", tmpfile}, nil); err == nil || !strings.Contains(err.Error(), "--count") {
+		t.Errorf("got error %v, want an error for --count", err)
+	}
+	if _, ok := opts.Flags.values[set.Lookup("count")]; ok {
+		t.Errorf("--count recorded as set from %s", tmpfile)
+	}
+}
+
+func TestFlagsReaderDecoder(t *testing.T) {
+	tmpfile, err := mkFile("name = bob\ncount = 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	type options struct {
+		Flags Flags  `getopt:"--flags"`
+		Name  string `getopt:"--name"`
+		Count int    `getopt:"--count"`
+	}
+	opts := &options{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	opts.Flags.SetReaderEncoding(SimpleReaderDecoder)
+	if err := set.Getopt([]string{"test", "--flags", tmpfile}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 3 {
+		t.Errorf("got %+v", opts)
+	}
+	if err := set.Getopt([]string{"test", "--flags", tmpfile + ".missing"}, nil); err == nil {
+		t.Errorf("did not get an error for a missing file")
+	}
+
+	if err := opts.Flags.SetReader("body", strings.NewReader("count = 4\n")); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Count != 4 {
+		t.Errorf("got count %d, want 4", opts.Count)
+	}
+	opts.Flags.ReaderDecoder = nil
+	if err := opts.Flags.SetReader("body", strings.NewReader("count = 5\n")); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Count != 5 {
+		t.Errorf("got count %d, want 5", opts.Count)
+	}
+	if err := opts.Flags.SetReader("body", strings.NewReader("count\n")); err == nil || !strings.HasPrefix(err.Error(), "body: ") {
+		t.Errorf("got error %v, want a body: error", err)
+	}
+}
+
+func TestFlagsNestedSets(t *testing.T) {
+	tmpfile, err := mkFile(`
+[server]
+port = 443
+[server.tls]
+cert = server.pem
+bogus = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	server := &struct {
+		Port int `getopt:"--port"`
+	}{}
+	tls := &struct {
+		Cert string `getopt:"--cert"`
+	}{}
+	serverSet := getopt.New()
+	if err := RegisterSet("", server, serverSet); err != nil {
+		t.Fatal(err)
+	}
+	tlsSet := getopt.New()
+	if err := RegisterSet("", tls, tlsSet); err != nil {
+		t.Fatal(err)
+	}
+	f := &Flags{
+		Sets: []Set{
+			{Name: "server", Set: serverSet},
+			{Name: "server.tls", Set: tlsSet},
+		},
+		Decoder: SimpleDecoder,
+		opt:     getopt.New().FlagLong(new(string), "flags", 0),
+	}
+	err = f.Set(tmpfile, nil)
+	if server.Port != 443 {
+		t.Errorf("got port %d, want 443", server.Port)
+	}
+	if tls.Cert != "server.pem" {
+		t.Errorf("got cert %q, want server.pem", tls.Cert)
+	}
+	var ue *UnknownOptionError
+	if !errors.As(err, &ue) || !reflect.DeepEqual(ue.Names, []string{"--server.tls.bogus"}) {
+		t.Errorf("got error %v, want unknown option --server.tls.bogus", err)
+	}
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	if err := RegisterEncoding("simple", testDecoder); err == nil {
+		t.Error("registering simple twice did not return an error")
+	}
+	RegisterEncoding("testregister", testDecoder)
+	names := ListEncodings()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("encodings not sorted: %q", names)
+	}
+	found := map[string]bool{}
+	for _, name := range names {
+		found[name] = true
+	}
+	for _, name := range []string{"simple", "simple-strict", "testregister"} {
+		if !found[name] {
+			t.Errorf("encoding %s not listed in %q", name, names)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegisterEncoding did not panic")
+		}
+	}()
+	MustRegisterEncoding("testregister", testDecoder)
+}
+
+func TestEncodings(t *testing.T) {
+	private := func(data []byte) (map[string]interface{}, error) {
+		return map[string]interface{}{"name": "private"}, nil
+	}
+	e := NewEncodings()
+	if err := e.Register("simple", private); err != nil {
+		t.Fatalf("registering simple privately: %v", err)
+	}
+	e.MustRegister("testprivate", private)
+	if err := e.Register("testprivate", private); err == nil {
+		t.Error("registering testprivate twice did not return an error")
+	}
+	if _, ok := lookupEncoding("testprivate"); ok {
+		t.Error("private encoding registered globally")
+	}
+	if _, ok := e.Lookup("simple-strict"); !ok {
+		t.Error("global encoding simple-strict not found")
+	}
+
+	type options struct {
+		Flags Flags  `getopt:"--flags" encoding:"testprivate"`
+		Name  string `getopt:"--name"`
+	}
+	if err := RegisterSet("", &options{}, getopt.New()); err == nil {
+		t.Error("private encoding found without the Encodings")
+	}
+	opts := &options{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--flags", "data:,name=bob"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "private" {
+		t.Errorf("got name %q, want private", opts.Name)
+	}
+
+	// The private simple encoding is only used with e.
+	simple := &struct {
+		Flags Flags  `getopt:"--flags"`
+		Name  string `getopt:"--name"`
+	}{}
+	set = getopt.New()
+	if err := RegisterSet("", simple, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--flags", "data:,name=bob"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if simple.Name != "bob" {
+		t.Errorf("got name %q, want bob", simple.Name)
+	}
+}
+
+func TestFlagsTag(t *testing.T) {
+	opts := &struct {
+		Flags Flags `getopt:"--flags" flags:"ignore-unknown"`
+	}{}
+	if err := RegisterSet("", opts, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Flags.IgnoreUnknown {
+		t.Error("IgnoreUnknown not set by flags tag")
+	}
+
+	bad := &struct {
+		Flags Flags `getopt:"--flags" flags:"ignore-unknown,bogus"`
+	}{}
+	err := RegisterSet("", bad, getopt.New())
+	if want := `Flags: unknown flags option "bogus"`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestFlagsOnUnknown(t *testing.T) {
+	tmpfile, err := mkFile("name=bob\nstale=1\nchild.old=2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	getopt.FlagLong(&name, "name", 'n')
+	var got []string
+	f := NewFlags("flags").OnUnknown(func(key, file string) {
+		got = append(got, file+":"+key)
+	})
+	f.IgnoreUnknown = true
+	if err := f.Set(tmpfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" {
+		t.Errorf("got name %q, want bob", name)
+	}
+	want := []string{tmpfile + ":child.old", tmpfile + ":stale"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got unknown keys %q, want %q", got, want)
+	}
+}
+
+func TestInlineData(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+		err     string
+	}{
+		{in: "name=bob%0Av=true", out: "name=bob\nv=true"},
+		{in: ",name=bob", out: "name=bob"},
+		{in: "name=a,b", out: "name=a,b"},
+		{in: ";base64,bmFtZT1ib2IKdj10cnVlCg==", out: "name=bob\nv=true\n"},
+		{in: ";base64,!!", err: "invalid base64 flags data: illegal base64 data at input byte 0"},
+		{in: "name=%zz", err: `invalid flags data: invalid URL escape "%zz"`},
+	} {
+		data, err := inlineData(tt.in)
+		switch {
+		case err == nil && tt.err == "":
+			if string(data) != tt.out {
+				t.Errorf("%s: got %q, want %q", tt.in, data, tt.out)
+			}
+		case err == nil:
+			t.Errorf("%s: did not get error %s", tt.in, tt.err)
+		case err.Error() != tt.err:
+			t.Errorf("%s: got error %v, want %s", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestFlagsData(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	getopt.FlagLong(&name, "name", 'n')
+	if err := NewFlags("flags").Set("data:name=bob%0A", nil); err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" {
+		t.Errorf("Got name %q, want %q", name, "bob")
+	}
+}
+
+func TestFlagsEnv(t *testing.T) {
+	const env = "OPTIONS_TEST_FLAGS"
+	os.Setenv(env, "name=bob\n")
+	defer os.Unsetenv(env)
+
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	getopt.FlagLong(&name, "name", 'n')
+	if err := NewFlags("flags").Set("env:"+env, nil); err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" {
+		t.Errorf("Got name %q, want %q", name, "bob")
+	}
+
+	getopt.CommandLine = getopt.New()
+	if err := NewFlags("flags").Set("?env:OPTIONS_TEST_UNSET", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	getopt.CommandLine = getopt.New()
+	err := NewFlags("flags").Set("env:OPTIONS_TEST_UNSET", nil)
+	if want := "environment variable OPTIONS_TEST_UNSET is not set"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestFlagsDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for path, data := range map[string]string{
+		"name":       "bob\n",
+		"child/name": "jim\n",
+		".hidden":    "ignored",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	getopt.FlagLong(&name, "name", 'n')
+	name2 := "john"
+	s2 := getopt.New()
+	s2.FlagLong(&name2, "name", 'n')
+
+	f := NewFlags("flags")
+	f.Sets = append(f.Sets, Set{Name: "child", Set: s2})
+	if err := f.Set(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" {
+		t.Errorf("Got name %q, want %q", name, "bob")
+	}
+	if name2 != "jim" {
+		t.Errorf("Got child.name %q, want %q", name2, "jim")
+	}
+}
+
+func TestFlagsSetContext(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	getopt.FlagLong(&name, "name", 'n')
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewFlags("flags").SetContext(ctx, "data:name=bob", nil)
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if name != "fred" {
+		t.Errorf("Got name %q, want %q", name, "fred")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	block := make(chan struct{})
+	defer close(block)
+	err = withContext(ctx, func() error {
+		<-block
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSubParseContextShared(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	f := NewFlags("flags")
+	f.IgnoreUnknown = true
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			set := getopt.New()
+			set.FlagLong(f, "flags", 0)
+			ctx := context.Background()
+			if i%2 == 1 {
+				ctx = canceled
+			}
+			_, err := SubParseContext(ctx, set, []string{"cmd", "--flags", "data:name=bob"})
+			switch {
+			case ctx == canceled && (err == nil || !strings.Contains(err.Error(), context.Canceled.Error())):
+				t.Errorf("canceled parse: got error %v, want %v", err, context.Canceled)
+			case ctx != canceled && err != nil:
+				t.Errorf("background parse: got error %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMultipleFlags(t *testing.T) {
+	opts := &struct {
+		Defaults Flags  `getopt:"--defaults=PATH site defaults"`
+		Flags    Flags  `getopt:"--flags=PATH flags file"`
+		Name     string `getopt:"--name=NAME name"`
+		Count    int    `getopt:"--count=N count"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"cmd", "--flags", "data:name=flags", "--defaults", "data:name=defaults%0Acount=2"}
+	if _, err := SubParse(set, args); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "flags" || opts.Count != 2 {
+		t.Errorf("got %q, %d, want flags, 2", opts.Name, opts.Count)
+	}
+
+	// A subcommand's Flags takes precedence over the global Flags.
+	global := &struct {
+		Flags Flags `getopt:"--flags=PATH global flags file"`
+	}{}
+	if err := RegisterSet("", global, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+	sub := &struct {
+		Flags Flags  `getopt:"--flags=PATH sub flags file"`
+		Name  string `getopt:"--name=NAME name"`
+		Level int    `getopt:"--level=N level"`
+	}{}
+	subSet := getopt.New()
+	if err := RegisterSet("", sub, subSet); err != nil {
+		t.Fatal(err)
+	}
+	global.Flags.Sets = append(global.Flags.Sets, Set{Name: "sub", Set: subSet})
+	if _, err := SubParse(subSet, []string{"sub", "--flags", "data:name=sub"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := global.Flags.Set("data:sub.name=global%0Asub.level=3", nil); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Name != "sub" || sub.Level != 3 {
+		t.Errorf("got %q, %d, want sub, 3", sub.Name, sub.Level)
+	}
+}
+
+func TestFlagsConcurrent(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	f := NewFlags("flags")
+	f.IgnoreUnknown = true
+	if err := f.Set("data:name=bob", nil); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	names := make([]string, 8)
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			set := getopt.New()
+			set.FlagLong(&names[i], "name", 'n')
+			if err := f.Rescan("", set); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, name := range names {
+		if name != "bob" {
+			t.Errorf("%d: got name %q, want bob", i, name)
+		}
+	}
+}
+
+func TestFlagsReplayPending(t *testing.T) {
+	opts := &struct {
+		Flags Flags  `getopt:"--flags=PATH flags file" flags:"ignore-unknown"`
+		Name  string `getopt:"--name=NAME name"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"cmd", "--flags", "data:name=bob%0Areplay.level=3"}); err != nil {
+		t.Fatal(err)
+	}
+	sub := &struct {
+		Level int `getopt:"--level=N level"`
+	}{}
+	subSet := getopt.New()
+	if err := RegisterSet("replay", sub, subSet); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Level != 3 {
+		t.Errorf("got level %d, want 3", sub.Level)
+	}
+	if n := len(opts.Flags.Sets); n != 2 || opts.Flags.Sets[1].Set != subSet {
+		t.Errorf("replay set not attached: %v", opts.Flags.Sets)
+	}
+
+	// Sets with names the file does not mention are not attached.
+	if err := RegisterSet("other", &struct {
+		Level int `getopt:"--level=N level"`
+	}{}, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(opts.Flags.Sets); n != 2 {
+		t.Errorf("got %d sets, want 2", n)
+	}
+}
+
+func TestFlagsAttachNewSets(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	f := NewFlags("flags").AttachNewSets()
+	defer func() {
+		attachMu.Lock()
+		attached = nil
+		attachMu.Unlock()
+	}()
+	f.IgnoreUnknown = true
+	if err := f.Set("data:backup.level=3", nil); err != nil {
+		t.Fatal(err)
+	}
+	opts := &struct {
+		Level int `getopt:"--level"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("backup", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Level != 3 {
+		t.Errorf("got level %d, want 3", opts.Level)
+	}
+	if n := len(f.Sets); n != 2 || f.Sets[1].Name != "backup" || f.Sets[1].Set != set {
+		t.Errorf("backup set not attached: %v", f.Sets)
+	}
+
+	// RescanAll reapplies the file to every set.
+	opts.Level = 0
+	if err := f.RescanAll(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Level != 3 {
+		t.Errorf("after RescanAll got level %d, want 3", opts.Level)
+	}
+}
+
+func TestNamedSets(t *testing.T) {
+	defer func() {
+		namedSetsMu.Lock()
+		delete(namedSets, "backup")
+		namedSetsMu.Unlock()
+	}()
+	if set := LookupSet("backup"); set != nil {
+		t.Fatalf("LookupSet found unregistered set")
+	}
+	set := NewSet("backup")
+	if LookupSet("backup") != set || NewSet("backup") != set {
+		t.Fatalf("set not registered")
+	}
+	opts := &struct {
+		Level int `getopt:"--level"`
+	}{}
+	if err := RegisterSet("backup", opts, set); err != nil {
+		t.Fatal(err)
+	}
+
+	getopt.CommandLine = getopt.New()
+	f := NewFlags("flags")
+	if err := f.Set("data:backup.level=3", nil); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Level != 3 {
+		t.Errorf("got level %d, want 3", opts.Level)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package flags
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// usageState holds the settings used to display the usage of CommandLine.
+var usageState = struct {
+	mu         sync.Mutex
+	program    string
+	parameters string
+	usage      func()
+	output     io.Writer
+	width      int
+	column     int
+	registered []interface{}
+}{
+	parameters: "[parameters ...]",
+	column:     20,
+}
+
+// SetParameters sets the parameters string for printing the command line
+// usage.  It defaults to "[parameters ...]"
+func SetParameters(parameters string) {
+	usageState.mu.Lock()
+	usageState.parameters = parameters
+	usageState.mu.Unlock()
+	useUsage()
+}
+
+// SetProgram sets the program name to program.  Normally it is determined
+// from the zeroth command line argument (see os.Args).
+func SetProgram(program string) {
+	usageState.mu.Lock()
+	usageState.program = program
+	usageState.mu.Unlock()
+	useUsage()
+}
+
+// SetUsage sets the function called by Usage, which CommandLine calls to
+// display the commands usage on error.  It defaults to calling
+// PrintUsage(os.Stderr).  Passing nil restores the default.
+func SetUsage(usage func()) {
+	usageState.mu.Lock()
+	usageState.usage = usage
+	usageState.mu.Unlock()
+	useUsage()
+}
+
+// SetDisplayWidth sets the width of the display when printing usage.  Help
+// strings longer than the remaining width are wrapped.  By default the width
+// is 0, which does not wrap help strings.
+func SetDisplayWidth(w int) {
+	usageState.mu.Lock()
+	usageState.width = w
+	usageState.mu.Unlock()
+}
+
+// SetHelpColumn sets the maximum column position that help strings start to
+// display at. If the option usage is too long then the help string will be
+// displayed on the next line.  It defaults to 20.
+func SetHelpColumn(c int) {
+	usageState.mu.Lock()
+	usageState.column = c
+	usageState.mu.Unlock()
+}
+
+// PrintUsage prints the usage line and the help of the options registered
+// with CommandLine by Register to w.
+func PrintUsage(w io.Writer) {
+	usageState.mu.Lock()
+	program, parameters := usageState.program, usageState.parameters
+	registered := append([]interface{}(nil), usageState.registered...)
+	usageState.mu.Unlock()
+	if program == "" && len(os.Args) > 0 {
+		program = os.Args[0]
+	}
+	writeHelp(w, program, parameters, registered...)
+}
+
+// Usage calls the function set by SetUsage or, if none was set, prints the
+// usage to the output of CommandLine (standard error by default).
+func Usage() {
+	usageState.mu.Lock()
+	usage := usageState.usage
+	usageState.mu.Unlock()
+	if usage != nil {
+		usage()
+		return
+	}
+	usageState.mu.Lock()
+	w := usageState.output
+	usageState.mu.Unlock()
+	if w == nil {
+		w = Output(CommandLine)
+	}
+	PrintUsage(w)
+}
+
+// SetOutput sets the destination for the usage and error messages of
+// CommandLine to w.  Usage writes to w even if CommandLine does not have an
+// Output method to report it.
+func SetOutput(w io.Writer) {
+	usageState.mu.Lock()
+	usageState.output = w
+	usageState.mu.Unlock()
+	CommandLine.SetOutput(w)
+}
+
+// Output returns the destination for the usage and error messages of set, as
+// returned by its Output method.  Output returns os.Stderr if set does not
+// have an Output method, such as flag.FlagSet prior to Go 1.10.
+func Output(set FlagSet) io.Writer {
+	if o, ok := set.(interface{ Output() io.Writer }); ok {
+		return o.Output()
+	}
+	return os.Stderr
+}
+
+// SetUsageFunc sets the function set calls to display its usage on error to
+// usage and reports whether it was able to.  It uses the SetUsage method of set
+// if it has one, otherwise it sets its Usage field, as found in flag.FlagSet.
+func SetUsageFunc(set FlagSet, usage func()) bool {
+	if s, ok := set.(interface{ SetUsage(func()) }); ok {
+		s.SetUsage(usage)
+		return true
+	}
+	v := reflect.ValueOf(set)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	f := v.Elem().FieldByName("Usage")
+	if !f.IsValid() || !f.CanSet() || f.Type() != reflect.TypeOf(usage) {
+		return false
+	}
+	f.Set(reflect.ValueOf(usage))
+	return true
+}
+
+// useUsage makes CommandLine call Usage on error if it can.
+func useUsage() {
+	SetUsageFunc(CommandLine, Usage)
+}
+
+// usageLayout returns the help column and display width used by writeHelp.
+func usageLayout() (column, width int) {
+	usageState.mu.Lock()
+	defer usageState.mu.Unlock()
+	return usageState.column, usageState.width
+}
+
+// addUsage records that i was registered with CommandLine so PrintUsage
+// includes its options.
+func addUsage(i interface{}) {
+	usageState.mu.Lock()
+	usageState.registered = append(usageState.registered, i)
+	usageState.mu.Unlock()
+}
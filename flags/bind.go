@@ -0,0 +1,116 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// Bind sets the fields of i, a pointer to an options structure, to the values
+// of the flags of the same names already defined in set, such as flags defined
+// on flag.CommandLine by other packages.  Bind is normally called after set
+// has been parsed to provide a typed view of flags i did not register.  Fields
+// whose flags are not defined in set are not changed.
+//
+// If a flag's Value implements flag.Getter and its value is assignable to the
+// field, it is assigned directly.  Otherwise the field is set from the string
+// form of the flag's value, as if the flag had been registered by i and given
+// that value on the command line.
+//
+// Bind uses the Lookup method of set, as provided by flag.FlagSet, which must
+// return nil or a pointer to a structure whose Value field is a Value.  Bind
+// returns an error if set does not have a suitable Lookup method, if i has an
+// invalid getopt tag, or if a value cannot be stored in its field.
+func Bind(set FlagSet, i interface{}) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	t := v.Type()
+	var errs Errors
+	n := t.NumField()
+	for i := 0; i < n; i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
+			continue
+		}
+		o, err := parseTag(field)
+		if err != nil {
+			errs = append(errs, fieldError(field.Name, err))
+			continue
+		}
+		value, err := lookupValue(set, o.Name())
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		if err := bind(fv, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+		}
+	}
+	return errs.Err()
+}
+
+// bind sets fv to the value of value.
+func bind(fv reflect.Value, value Value) error {
+	if g, ok := value.(flag.Getter); ok {
+		if gv := reflect.ValueOf(g.Get()); gv.IsValid() && gv.Type().AssignableTo(fv.Type()) {
+			fv.Set(gv)
+			return nil
+		}
+	}
+	// Define the field in a scratch set to find its Value.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := define(fs, fv, "option", ""); err != nil {
+		return err
+	}
+	return fs.Lookup("option").Value.Set(value.String())
+}
+
+// lookupValue returns the Value of the flag name in fs, or nil if fs does not
+// define name.  It uses the Lookup method of fs in the same fashion as defined.
+func lookupValue(fs interface{}, name string) (Value, error) {
+	m := reflect.ValueOf(fs).MethodByName("Lookup")
+	if !m.IsValid() {
+		return nil, fmt.Errorf("Type %T missing Lookup method", fs)
+	}
+	t := m.Type()
+	if t.NumIn() != 1 || t.NumOut() != 1 || !stringType.AssignableTo(t.In(0)) {
+		return nil, fmt.Errorf("Type %T has the wrong signature for Lookup", fs)
+	}
+	f := m.Call([]reflect.Value{reflect.ValueOf(name)})[0]
+	if f.Kind() == reflect.Interface {
+		f = f.Elem()
+	}
+	if !f.IsValid() || f.Kind() != reflect.Ptr || f.IsNil() {
+		return nil, nil
+	}
+	if f = f.Elem(); f.Kind() == reflect.Struct {
+		if fv := f.FieldByName("Value"); fv.IsValid() && fv.CanInterface() {
+			if v, ok := fv.Interface().(Value); ok {
+				return v, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Type %T: Lookup(%q) returned %v with no Value", fs, name, f.Type())
+}
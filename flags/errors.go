@@ -0,0 +1,39 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package flags
+
+import (
+	"github.com/pborman/options/internal/opterrors"
+	"github.com/pborman/options/tag"
+)
+
+// A TagError is returned when a field has an invalid getopt tag.
+type TagError = tag.Error
+
+// fieldError returns err with the field set to field if err is a *TagError,
+// otherwise err is returned prefixed by field.
+func fieldError(field string, err error) error { return opterrors.Field(field, err) }
+
+// An UnsupportedTypeError is returned when a field is of a type that cannot be
+// used as a flag.
+type UnsupportedTypeError = opterrors.UnsupportedTypeError
+
+// A DuplicateOptionError is returned when the option Name, without leading
+// dashes, is declared by more than one field, or when Name is already defined
+// in the flag set.  Other is empty in the latter case.
+type DuplicateOptionError = opterrors.DuplicateOptionError
+
+// Errors is a list of errors.  It is returned when more than one problem is
+// found while registering an options structure.
+type Errors = opterrors.Errors
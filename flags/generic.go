@@ -0,0 +1,30 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+//go:build go1.18
+// +build go1.18
+
+package flags
+
+// RegisterNewT is like RegisterNew but returns the duplicate of i as a *T,
+// so it need not be asserted from an interface{}.
+//
+//	opts, set := flags.RegisterNewT("", &defaults)
+//	if err := set.Parse(args); err != nil {
+//		...
+//	}
+//	fmt.Println(opts.Name)
+func RegisterNewT[T any](name string, i *T) (*T, FlagSet) {
+	opts, set := RegisterNew(name, i)
+	return opts.(*T), set
+}
@@ -0,0 +1,23 @@
+//go:build go1.18
+// +build go1.18
+
+package flags
+
+import "testing"
+
+func TestRegisterNewT(t *testing.T) {
+	defaults := &struct {
+		Name  string `getopt:"--name"`
+		Count int    `getopt:"--count"`
+	}{Name: "default", Count: 1}
+	opts, set := RegisterNewT("", defaults)
+	if err := set.Parse([]string{"--name=bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 1 {
+		t.Errorf("got %+v", *opts)
+	}
+	if defaults.Name != "default" {
+		t.Errorf("defaults changed to %+v", *defaults)
+	}
+}
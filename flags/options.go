@@ -57,6 +57,34 @@
 //	Name string -> "--name unspecified"
 //	N int       -> "-n unspecified"
 //
+// A generated name is the field name in lower case (MaxCount becomes
+// maxcount).  A single letter field name is a short name and any other field
+// name is a long name.  These are the same rules github.com/pborman/options
+// uses, so an untagged structure declares equivalent options in both packages.
+// The flag package does not distinguish -n from --n, but Help displays short
+// names with a single dash as the options package does.
+//
+// The pieces of a tag may instead be declared using the long, short, param, and
+// help struct tags.  This is easier to read when the description is long:
+//
+//	Name string `long:"name" param:"NAME" help:"name of the widget"`
+//
+// These may be combined with a getopt tag as long as no piece of information is
+// declared twice.  Only one of long and short may be used.  Names are declared
+// without leading dashes.
+//
+// Following the option name with a ? declares its value to be optional.  The
+// implicit struct tag provides the value used when the value is omitted:
+//
+//	Color string `getopt:"--color?=WHEN colorize output" implicit:"auto"`
+//
+// The option may then be given as either --color or --color=WHEN.  Such options
+// are registered as boolean flags, so an explicit value must be attached with
+// an =.  The flag package cannot tell --color=true from --color, so when a
+// FlagSet is parsed directly, rather than by RegisterAndParse,
+// RegisterAndParseArgs, SubRegisterAndParse or Parse, a value of "true" is
+// treated as if the value was omitted.
+//
 // # Types
 //
 // The fields of the structure must be compatible with one of the folllowing
@@ -64,15 +92,66 @@
 //
 //	bool
 //	int
+//	int8
+//	int16
+//	int32
 //	int64
+//	float32
 //	float64
 //	string
 //	uint
+//	uint8
+//	uint16
+//	uint32
 //	uint64
 //	[]string
+//	[]int
+//	[]time.Duration
+//	map[string]string
+//	map[string]int
+//	map[string]bool
+//	func(string) error
 //	Value
+//	encoding.TextUnmarshaler
 //	time.Duration
 //
+// A field of type func(string) error is called with the value each time the
+// option is seen on the command line, similar to flag.Func.  This is useful for
+// options with side effects, such as --load=FILE.
+//
+// The units struct tag allows an integer option to be given with a suffix.
+// With units:"si" the suffixes k, M, G, T, P, and E are powers of 1000.  With
+// units:"iec" they are powers of 1024.  The suffixes Ki, Mi, Gi, Ti, Pi, and Ei
+// are always powers of 1024.
+//
+//	MaxEvents int `getopt:"--max-events=N stop after N events" units:"si"`
+//
+// Each use of a []string, []int, or []time.Duration option appends its value to
+// the slice, e.g., --backoff=1s --backoff=5s --backoff=30s.
+//
+// A bool option with a long name and a negate tag is also declared in a negated
+// form that sets it to false, e.g., --no-color for --color.  A field of type
+// Counter counts the number of times its option is used, e.g., -v -v.
+//
+//	Color   bool          `getopt:"--color colorize output" negate:""`
+//	Verbose flags.Counter `getopt:"-v      increase verbosity"`
+//
+// A bool option may be set to any of true, false, t, f, yes, no, y, n, on, off,
+// 1, or 0, ignoring case, e.g., -v=yes.
+//
+// A field whose pointer implements encoding.TextUnmarshaler, such as uuid.UUID
+// or net.IP, is set by calling UnmarshalText.  If it also implements
+// encoding.TextMarshaler and the FlagSet has a TextVar method, as flag.FlagSet
+// does starting with Go 1.19, the option is defined with TextVar.
+//
+// Each use of a map option adds a key=value pair to the map, e.g.,
+// --limit cpu=4 --limit mem=2048.  The value may be omitted for a
+// map[string]bool, in which case it is true.
+//
+// The int8, int16, int32, uint8, uint16, uint32, and float32 types are not
+// directly supported by the flag package.  They are registered as a Value that
+// returns an error if the value does not fit in the field.
+//
 // # Example Structure
 //
 // The following structure declares 7 options and sets the default value of
@@ -116,14 +195,19 @@
 package flags
 
 import (
+	"encoding"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pborman/options/internal/optvalue"
+	"github.com/pborman/options/tag"
 )
 
 // Value is the interface to the dynamic value stored in a flag. (The default
@@ -135,9 +219,19 @@ type Value interface {
 
 // NewFlagSet and CommandLine can be replaced to use a different flag package.
 // They default to the standard flag package.
+//
+// ErrorHandling is the error handling policy used by the default NewFlagSet.
+// It defaults to flag.ExitOnError.  Set ErrorHandling to flag.ContinueOnError
+// to have parsing errors returned rather than exiting the program, or to
+// flag.PanicOnError to panic.  ErrorHandling does not change CommandLine.  To
+// use a different policy with CommandLine, replace it:
+//
+//	flags.ErrorHandling = flag.ContinueOnError
+//	flags.CommandLine = flags.NewFlagSet(os.Args[0])
 var (
-	NewFlagSet          = func(name string) FlagSet { return flag.NewFlagSet(name, flag.ExitOnError) }
-	CommandLine FlagSet = flag.CommandLine
+	ErrorHandling         = flag.ExitOnError
+	NewFlagSet            = func(name string) FlagSet { return flag.NewFlagSet(name, ErrorHandling) }
+	CommandLine   FlagSet = flag.CommandLine
 )
 
 // A FlagSet implements a set of flags.  flag.FlagSet from the standard flag package implements FlagSet.
@@ -147,6 +241,12 @@ var (
 //
 // Where valueType implements the Value interface (which flag.Value does).
 // We cannot put Var in the interface due to the Value type.
+//
+// A FlagSet may also have an Output method and either a Usage field or a
+// SetUsage method, as flag.FlagSet does, to report where its messages are
+// written and to set the function it calls to display its usage.  They are not
+// in the interface so other flag packages need not provide them.  Use Output
+// and SetUsageFunc to access them.
 type FlagSet interface {
 	Parse([]string) error
 	Args() []string
@@ -162,6 +262,7 @@ type FlagSet interface {
 	BoolVar(p *bool, name string, value bool, usage string)
 }
 
+// A list is a []string that appends each value it is set to.
 type list []string
 
 func (l *list) Set(s string) error {
@@ -169,13 +270,23 @@ func (l *list) Set(s string) error {
 	return nil
 }
 
+// String returns the values in l as space separated Go quoted strings, e.g.,
+// "a" "b c", so values containing spaces or quotes are unambiguous.
 func (l *list) String() string {
-	return strings.Join(*l, " ")
+	s := make([]string, len(*l))
+	for i, v := range *l {
+		s[i] = strconv.Quote(v)
+	}
+	return strings.Join(s, " ")
 }
 
+// Get implements flag.Getter.  It returns the values as a []string.
+func (l *list) Get() interface{} { return []string(*l) }
+
 // Dup returns a shallow duplicate of i or panics.  Dup panics if i is not a
 // pointer to struct or has an invalid getopt tag.  Dup does not copy
-// non-exported fields or fields whose getopt tag is "-".
+// non-exported fields or fields whose getopt tag is "-".  Fields that are maps
+// are copied so the duplicate's options do not modify i.
 //
 // Dup is normally used to create a unique instance of the set of options so i
 // can be used multiple times.
@@ -201,30 +312,71 @@ func Dup(i interface{}) interface{} {
 		if tag == "-" || !fv.CanSet() {
 			continue
 		}
-		_, err := parseTag(tag)
+		_, err := parseTag(field)
 		if err != nil {
 			panic(err)
 		}
 		// Copy the value over
 		fv.Set(v.Field(i))
+		// Maps are copied so setting an option in the duplicate does
+		// not modify i.
+		if fv.Kind() == reflect.Map && !fv.IsNil() {
+			m := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+			fv.Set(m)
+		}
 	}
 	return ret
 }
 
 // Register registers the fields in i with the standard command-line option set.
-// It panics for the same reasons that RegisterSet panics.
+// It panics with the error RegisterSet would return.  Use RegisterSet to handle
+// the error instead.
 func Register(i interface{}) {
 	if err := register("", i, CommandLine); err != nil {
 		panic(err)
 	}
+	addUsage(i)
 }
 
-// RegisterAndParse and calls Register(i), flag.Parse(), and returns
-// getopt.Args().
+// RegisterAndParse calls Register(i), parses os.Args[1:] with CommandLine,
+// and returns the remaining arguments.  Errors, including an error registering
+// i, are handled as set by ErrorHandling: the program exits, after writing the
+// error to the output of CommandLine, with flag.ExitOnError, RegisterAndParse
+// panics with flag.PanicOnError, and nil is returned with
+// flag.ContinueOnError.  Use RegisterAndParseArgs to have errors returned.
 func RegisterAndParse(i interface{}) []string {
-	Register(i)
-	CommandLine.Parse(os.Args[1:])
-	return CommandLine.Args()
+	args, err := RegisterAndParseArgs(i, os.Args[1:])
+	if err != nil {
+		switch ErrorHandling {
+		case flag.ContinueOnError:
+		case flag.PanicOnError:
+			panic(err)
+		default:
+			fmt.Fprintln(Output(CommandLine), err)
+			os.Exit(2)
+		}
+	}
+	return args
+}
+
+// RegisterAndParseArgs registers i with CommandLine, as Register does, parses
+// args with CommandLine, and returns the remaining arguments.  Unlike
+// SubRegisterAndParse, args does not include the command name.  An error is
+// returned if i cannot be registered.  An error parsing args is only returned
+// if CommandLine was created with flag.ContinueOnError (see ErrorHandling).
+func RegisterAndParseArgs(i interface{}, args []string) ([]string, error) {
+	if err := register("", i, CommandLine); err != nil {
+		return nil, err
+	}
+	addUsage(i)
+	if err := parse(CommandLine, args); err != nil {
+		return nil, err
+	}
+	return CommandLine.Args(), nil
 }
 
 // SubRegisterAndParse is similar to RegisterAndParse except it is provided the
@@ -234,7 +386,9 @@ func RegisterAndParse(i interface{}) []string {
 // with args.
 //
 // SubRegisterAndParse is useful when you want to parse arguments other than
-// os.Args (which is what RegisterAndParse does).
+// os.Args (which is what RegisterAndParse does).  The set is created by
+// NewFlagSet, so errors are only returned when ErrorHandling is
+// flag.ContinueOnError.
 //
 // The first element of args is equivalent to a command name and is not parsed.
 //
@@ -264,7 +418,7 @@ func SubRegisterAndParse(i interface{}, args []string) ([]string, error) {
 	if err := RegisterSet(args[0], i, set); err != nil {
 		return nil, err
 	}
-	if err := set.Parse(args[1:]); err != nil {
+	if err := parse(set, args[1:]); err != nil {
 		return nil, err
 	}
 	return set.Args(), nil
@@ -272,11 +426,13 @@ func SubRegisterAndParse(i interface{}, args []string) ([]string, error) {
 
 // Parse calls flag.Parse and returns flag.Args().
 func Parse() []string {
-	CommandLine.Parse(os.Args[1:])
+	parse(CommandLine, os.Args[1:])
 	return CommandLine.Args()
 }
 
-// Validate validates i as a set of options or returns an error.
+// Validate validates i as a set of options or returns an error.  It is an
+// error for two fields to declare the same option name.  If more than one
+// problem is found then an Errors is returned describing all of them.
 //
 // Use Validate to assure that a later call to one of the Register functions
 // will not panic.  Validate is typically called by an init function on
@@ -303,6 +459,10 @@ func RegisterNew(name string, i interface{}) (interface{}, FlagSet) {
 // or contains a field of an unsupported option type.  RegisterSet ignores
 // non-exported fields or fields whose getopt tag is "-".
 //
+// RegisterSet returns an error, without registering any options, if two fields
+// declare the same option name or if an option is already defined in set.  If
+// more than one field has a problem then an Errors is returned.
+//
 // If a Flags field is encountered, name is the name used to identify the set
 // when parsing options.
 //
@@ -323,6 +483,17 @@ func register(name string, i interface{}, set FlagSet) error {
 	}
 	t := v.Type()
 
+	// Check all the fields before registering any of them so all the
+	// problems with i are reported at once and nothing is added to set.
+	type option struct {
+		fv    reflect.Value
+		o     *tag.Spec
+		units string
+	}
+	var opts []option
+	var errs Errors
+	fields := map[string]string{}
+
 	n := t.NumField()
 	for i := 0; i < n; i++ {
 		field := t.Field(i)
@@ -331,49 +502,230 @@ func register(name string, i interface{}, set FlagSet) error {
 		if tag == "-" || !fv.CanSet() {
 			continue
 		}
-		o, err := parseTag(tag)
+		o, err := parseTag(field)
 		if err != nil {
-			panic(err)
+			errs = append(errs, fieldError(field.Name, err))
+			continue
+		}
+		if f, ok := fields[o.Name()]; ok {
+			errs = append(errs, &DuplicateOptionError{Name: o.Name(), Field: field.Name, Other: f})
+		} else if defined(set, o.Name()) {
+			errs = append(errs, &DuplicateOptionError{Name: o.Name(), Field: field.Name})
+		}
+		fields[o.Name()] = field.Name
+		if o.Negate {
+			if _, ok := fv.Addr().Interface().(*bool); !ok || o.Long == "" {
+				errs = append(errs, fmt.Errorf("%s: negate tag requires a bool option with a long name", field.Name))
+				continue
+			}
+			neg := "no-" + o.Long
+			if f, ok := fields[neg]; ok {
+				errs = append(errs, &DuplicateOptionError{Name: neg, Field: field.Name, Other: f})
+			} else if defined(set, neg) {
+				errs = append(errs, &DuplicateOptionError{Name: neg, Field: field.Name})
+			}
+			fields[neg] = field.Name
+		}
+		opt := option{fv: fv, o: o}
+		if units := field.Tag.Get("units"); units != "" {
+			if err := optvalue.CheckUnits(field.Type, units); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+				continue
+			}
+			opt.units = units
 		}
-		if o == nil {
-			o = &optTag{name: strings.ToLower(field.Name)}
-		}
-		if o.help == "" {
-			o.help = "unspecified"
-		}
-		hv := []string{o.help, o.param}
-		if o.param == "" {
-			hv = hv[:1]
-		}
-		opt := fv.Addr().Interface()
-		switch t := opt.(type) {
-		case Value:
-			setvar(set, t, o.name, o.help)
-		case *[]string:
-			setvar(set, (*list)(t), o.name, o.help)
-		case *time.Duration:
-			set.DurationVar(t, o.name, *t, o.help)
-		case *string:
-			set.StringVar(t, o.name, *t, o.help)
-		case *int:
-			set.IntVar(t, o.name, *t, o.help)
-		case *int64:
-			set.Int64Var(t, o.name, *t, o.help)
-		case *uint:
-			set.UintVar(t, o.name, *t, o.help)
-		case *uint64:
-			set.Uint64Var(t, o.name, *t, o.help)
-		case *float64:
-			set.Float64Var(t, o.name, *t, o.help)
-		case *bool:
-			set.BoolVar(t, o.name, *t, o.help)
+		switch fv.Addr().Interface().(type) {
+		case Value, encoding.TextUnmarshaler,
+			*[]string, *[]int, *[]time.Duration, *func(string) error, *time.Duration, *string, *bool,
+			*map[string]string, *map[string]int, *map[string]bool,
+			*int, *int8, *int16, *int32, *int64,
+			*uint, *uint8, *uint16, *uint32, *uint64,
+			*float32, *float64:
 		default:
-			panic(fmt.Sprintf("invalid option type: %T", fv.Interface()))
+			errs = append(errs, &UnsupportedTypeError{Field: field.Name, Type: field.Type})
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	for _, opt := range opts {
+		if opt.units != "" || opt.o.Optional || opt.o.Negate || !direct(set, opt.fv) {
+			if _, err := varMethod(set); err != nil {
+				errs = append(errs, err)
+			}
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return errs.Err()
+	}
+
+	for _, opt := range opts {
+		fv, o := opt.fv, opt.o
+		if o.Help == "" {
+			o.Help = "unspecified"
+		}
+		o.Help = flagUsage(o, fv)
+		var value Value
+		if opt.units != "" {
+			value = &unitsValue{optvalue.Units{V: fv, Units: opt.units}}
+		}
+		if o.Optional && fv.Kind() != reflect.Bool {
+			if value == nil {
+				// Define the option in a scratch set to find its
+				// Value.
+				fs := flag.NewFlagSet("", flag.ContinueOnError)
+				if err := define(fs, fv, "option", ""); err != nil {
+					return err
+				}
+				value = fs.Lookup("option").Value
+			}
+			value = &implicitValue{Value: value, implicit: o.Implicit}
+		}
+		if value != nil {
+			if err := setvar(set, value, o.Name(), o.Help); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := define(set, fv, o.Name(), o.Help); err != nil {
+			return err
+		}
+		if o.Negate {
+			if err := setvar(set, (*negBoolValue)(fv.Addr().Interface().(*bool)), "no-"+o.Long, "do not "+o.Help); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// flagUsage returns the usage string to register for the option o of the
+// field fv.  The flag package treats the first back-quoted word of a usage
+// string as the name of the flag's parameter, e.g., flag.PrintDefaults displays
+// "-name NAME".  The parameter from the tag, if any, is back-quoted at its first
+// appearance as a word in o.Help or, if it does not appear, prepended to it.
+// Bool options take no parameter, and a usage that already contains a back
+// quote is returned unchanged.
+func flagUsage(o *tag.Spec, fv reflect.Value) string {
+	if o.Param == "" || fv.Kind() == reflect.Bool || strings.Contains(o.Help, "`") {
+		return o.Help
+	}
+	for i := 0; ; i++ {
+		n := strings.Index(o.Help[i:], o.Param)
+		if n < 0 {
+			break
+		}
+		i += n
+		end := i + len(o.Param)
+		if (i == 0 || !isWordByte(o.Help[i-1])) && (end == len(o.Help) || !isWordByte(o.Help[end])) {
+			return o.Help[:i] + "`" + o.Param + "`" + o.Help[end:]
+		}
+	}
+	return "`" + o.Param + "` " + o.Help
+}
+
+// isWordByte reports whether c may be part of a parameter name.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+// define defines the option name in set for the field fv.  An error is returned
+// if the option must be defined with a Var method that set does not have or if
+// defining the option panics.
+func define(set FlagSet, fv reflect.Value, name, help string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("defining %s: %v", name, p)
+		}
+	}()
+	var value Value
+	switch t := fv.Addr().Interface().(type) {
+	case Value:
+		value = t
+	case encoding.TextUnmarshaler:
+		if m, ok := t.(encoding.TextMarshaler); ok {
+			if tv := textVarMethod(set); tv.IsValid() {
+				tv.Call([]reflect.Value{
+					reflect.ValueOf(t),
+					reflect.ValueOf(name),
+					reflect.ValueOf(m),
+					reflect.ValueOf(help),
+				})
+				return nil
+			}
+		}
+		value = textValue{P: t}
+	case *[]string:
+		value = (*list)(t)
+	case *[]int:
+		value = (*intList)(t)
+	case *[]time.Duration:
+		value = (*durationList)(t)
+	case *int8:
+		value = (*int8Value)(t)
+	case *int16:
+		value = (*int16Value)(t)
+	case *int32:
+		value = (*int32Value)(t)
+	case *uint8:
+		value = (*uint8Value)(t)
+	case *uint16:
+		value = (*uint16Value)(t)
+	case *uint32:
+		value = (*uint32Value)(t)
+	case *float32:
+		value = (*float32Value)(t)
+	case *func(string) error:
+		value = (*funcValue)(t)
+	case *map[string]string:
+		value = (*stringMap)(t)
+	case *map[string]int:
+		value = (*intMap)(t)
+	case *map[string]bool:
+		value = (*boolMap)(t)
+	case *time.Duration:
+		set.DurationVar(t, name, *t, help)
+		return nil
+	case *string:
+		set.StringVar(t, name, *t, help)
+		return nil
+	case *int:
+		set.IntVar(t, name, *t, help)
+		return nil
+	case *int64:
+		set.Int64Var(t, name, *t, help)
+		return nil
+	case *uint:
+		set.UintVar(t, name, *t, help)
+		return nil
+	case *uint64:
+		set.Uint64Var(t, name, *t, help)
+		return nil
+	case *float64:
+		set.Float64Var(t, name, *t, help)
+		return nil
+	case *bool:
+		value = (*boolValue)(t)
+	default:
+		return fmt.Errorf("invalid option type: %T", fv.Interface())
+	}
+	return setvar(set, value, name, help)
+}
+
+// direct reports if fv can be defined in set without the Var method of set.
+func direct(set FlagSet, fv reflect.Value) bool {
+	switch t := fv.Addr().Interface().(type) {
+	case Value:
+		return false
+	case encoding.TextUnmarshaler:
+		_, ok := t.(encoding.TextMarshaler)
+		return ok && textVarMethod(set).IsValid()
+	case *time.Duration, *string, *int, *int64, *uint, *uint64, *float64:
+		return true
+	}
+	return false
+}
+
 // Lookup returns the value of the field in i for the specified option or nil.
 // Lookup can be used if the structure declaring the options is not available.
 // Lookup returns nil if i is invalid or does not have an option named option.
@@ -406,112 +758,77 @@ func Lookup(i interface{}, option string) interface{} {
 		if tag == "-" || !fv.CanSet() {
 			continue
 		}
-		o, err := parseTag(tag)
+		o, err := parseTag(field)
 		if err != nil {
 			return nil
 		}
-		if o == nil {
-			o = &optTag{name: strings.ToLower(field.Name)}
-		}
-		if option == o.name {
+		if option == o.Name() {
 			return fv.Interface()
 		}
 	}
 	return nil
 }
 
-// An optTag contains all the information extracted from a getopt tag.
-type optTag struct {
-	name  string
-	param string
-	help  string
-}
-
-func (o *optTag) String() string {
-	parts := make([]string, 0, 6)
-	parts = append(parts, "{")
-	switch len(o.name) {
-	case 0:
-	case 1:
-		parts = append(parts, "-"+o.name)
-	default:
-		parts = append(parts, "--"+o.name)
-	}
-	if o.param != "" {
-		parts = append(parts, "="+o.param)
-	}
-	if o.help != "" {
-		parts = append(parts, fmt.Sprintf("%q", o.help))
+// LookupSeen is like Lookup but also reports if option was seen when set was
+// parsed.  Seen is always false unless set has a Visit method compatible with
+// the Visit method of flag.FlagSet.
+//
+// # Example
+//
+//	i, set := options.RegisterNew(&struct {
+//		Name string `getopt:"--name the name"`
+//	})
+//	set.Parse(args)
+//	if name, ok := options.LookupSeen(i, set, "name"); ok {
+//		...
+//	}
+func LookupSeen(i interface{}, set FlagSet, option string) (value interface{}, seen bool) {
+	value = Lookup(i, option)
+	if value == nil {
+		return nil, false
 	}
-	parts = append(parts, "}")
-	return strings.Join(parts, " ")
+	return value, visited(set)[option]
 }
 
-// parseTag parses and returns tag as an optTag or returns an error.  nil, nil
-// is returned if tag is empty or consists only of white space.
-func parseTag(tag string) (*optTag, error) {
-	tag = strings.TrimSpace(tag)
-	if tag == "" {
-		return nil, nil
+// Visit calls fn, in lexicographical order, with the name and value of each
+// option in i that was seen when set was parsed.  Options in set that are not
+// declared by i are not visited.  As with LookupSeen, set must have a Visit
+// method compatible with flag.FlagSet for any options to be visited.
+func Visit(i interface{}, set FlagSet, fn func(name string, value interface{})) {
+	m := visited(set)
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
 	}
-	next := tag
-	var o optTag
-	var arg, param string
-	for {
-		arg, param, next = nextOption(next)
-		if arg == "" || arg == "-" || arg == "--" {
-			if param != "" {
-				// Only happens with "--=FOO" or "-=FOO"
-				return nil, fmt.Errorf("getopt tag missing option name: %q", tag)
-			}
-			if o.name == "" {
-				if next != "" {
-					return nil, fmt.Errorf("getopt tag missing option name: %q", tag)
-				}
-				return nil, nil
-			}
-			o.help = next
-			return &o, nil
+	sort.Strings(names)
+	for _, name := range names {
+		if v := Lookup(i, name); v != nil {
+			fn(name, v)
 		}
-		if param != "" {
-			if o.param != "" {
-				return nil, fmt.Errorf("getopt tag has multiple parameter names: %q", tag)
-			}
-			o.param = param
-		}
-		if o.name != "" {
-			return nil, fmt.Errorf("getopt tag has too many names: %q", tag)
-		}
-		// Strip off the leading -- or -.
-		o.name = strings.TrimPrefix(arg[1:], "-")
 	}
 }
 
-// nextOption returns the next option, optional parameter, and the rest of
-// the string parsed from s.  If the option is "" then s does not start with
-// an option (i.e., does not start with a -).
-func nextOption(s string) (option, param, rest string) {
-	if s == "" || s[0] != '-' {
-		return "", "", s
+// visited returns the set of names of the flags that have been set in fs.  It
+// requires fs to have the Visit method of flag.FlagSet.
+func visited(fs FlagSet) map[string]bool {
+	m := map[string]bool{}
+	if v, ok := fs.(interface{ Visit(func(*flag.Flag)) }); ok {
+		v.Visit(func(f *flag.Flag) { m[f.Name] = true })
 	}
-	if x := strings.Index(s, " "); x >= 0 {
-		rest = strings.TrimSpace(s[x:])
-		s = s[:x]
-	}
-	if x := strings.Index(s, "="); x >= 0 {
-		return s[:x], s[x+1:], rest
-	}
-	return s, "", rest
+	return m
 }
 
-// argPrefix returns the leading dashes in a.
-func argPrefix(a string) string {
-	for x, c := range a {
-		if c != '-' {
-			return a[:x]
-		}
-	}
-	return a
+// Inspect returns the specification of each option declared by i, a pointer
+// to an options structure, in field order.  See tag.InspectFlags.
+func Inspect(i interface{}) ([]tag.Spec, error) {
+	return tag.InspectFlags(i)
+}
+
+// parseTag returns the tag.Spec of the option declared by field, with its
+// name filled in, or an error.  nil, nil is returned if field does not declare
+// an option.
+func parseTag(field reflect.StructField) (*tag.Spec, error) {
+	return tag.FlagFieldSpec(field)
 }
 
 var (
@@ -523,18 +840,18 @@ var (
 // setvar calls fs.Var(value, name, usage).  The value type Var expects must
 // implement the Value inteface.  This enables this package to pass a Value
 // where the flag package expected a flag.Value.  An error is returned
-// if fs does not have a Var method with an appropriate signature.
-func setvar(fs interface{}, value Value, name, usage string) error {
-	v := reflect.ValueOf(fs)
-	m := v.MethodByName("Var")
-	if !m.IsValid() {
-		return fmt.Errorf("Type %v missing Var method", v.Type())
-	}
-	t := m.Type()
-	// We want to be able to call Var(value, name, usage)
-	if t.NumIn() != 3 || t.NumOut() != 0 || !t.In(0).Implements(valueType) || !t.In(1).AssignableTo(stringType) || !t.In(1).AssignableTo(stringType) {
-		return fmt.Errorf("Type %v has the wrong signature for Var", v.Type())
+// if fs does not have a Var method with an appropriate signature or if Var
+// panics, as flag.FlagSet.Var does when name is already defined.
+func setvar(fs interface{}, value Value, name, usage string) (err error) {
+	m, err := varMethod(fs)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("defining %s: %v", name, p)
+		}
+	}()
 	m.Call([]reflect.Value{
 		reflect.ValueOf(value),
 		reflect.ValueOf(name),
@@ -543,6 +860,64 @@ func setvar(fs interface{}, value Value, name, usage string) error {
 	return nil
 }
 
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// textVarMethod returns the TextVar method of fs, as provided by flag.FlagSet
+// starting with Go 1.19, or the zero Value if fs does not have a TextVar
+// method that can be called as TextVar(p, name, value, usage) where p is an
+// encoding.TextUnmarshaler and value is an encoding.TextMarshaler.
+func textVarMethod(fs interface{}) reflect.Value {
+	m := reflect.ValueOf(fs).MethodByName("TextVar")
+	if !m.IsValid() {
+		return reflect.Value{}
+	}
+	t := m.Type()
+	if t.NumIn() != 4 || t.NumOut() != 0 ||
+		!textUnmarshalerType.AssignableTo(t.In(0)) ||
+		!stringType.AssignableTo(t.In(1)) ||
+		!textMarshalerType.AssignableTo(t.In(2)) ||
+		!stringType.AssignableTo(t.In(3)) {
+		return reflect.Value{}
+	}
+	return m
+}
+
+// varMethod returns the Var method of fs or an error if fs does not have a Var
+// method that can be called as Var(value, name, usage).
+func varMethod(fs interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(fs)
+	m := v.MethodByName("Var")
+	if !m.IsValid() {
+		return reflect.Value{}, fmt.Errorf("Type %v missing Var method", v.Type())
+	}
+	t := m.Type()
+	// We want to be able to call Var(value, name, usage)
+	if t.NumIn() != 3 || t.NumOut() != 0 || !t.In(0).Implements(valueType) || !t.In(1).AssignableTo(stringType) || !t.In(2).AssignableTo(stringType) {
+		return reflect.Value{}, fmt.Errorf("Type %v has the wrong signature for Var", v.Type())
+	}
+	return m, nil
+}
+
+// defined returns true if fs has a Lookup method that reports name as already
+// being defined.  The Lookup method is expected to take a single string and
+// return a single value that is nil if name is not defined, as the Lookup
+// method of flag.FlagSet does.  If fs does not have a suitable Lookup method
+// then false is returned.
+func defined(fs interface{}, name string) bool {
+	m := reflect.ValueOf(fs).MethodByName("Lookup")
+	if !m.IsValid() {
+		return false
+	}
+	t := m.Type()
+	if t.NumIn() != 1 || t.NumOut() != 1 || !stringType.AssignableTo(t.In(0)) {
+		return false
+	}
+	return !m.Call([]reflect.Value{reflect.ValueOf(name)})[0].IsZero()
+}
+
 // Help writes help information for the flag set specified by i where i is a
 // pointer to a structure as described above.  As an example:
 //
@@ -567,9 +942,23 @@ func setvar(fs interface{}, value Value, name, usage string) error {
 //	               yes or no
 //	 -v            be verbose
 //
-// If cmd is the empty string the initial line will not be printed.
+// If cmd is the empty string the initial line will not be printed.  Help
+// strings start at the column set by SetHelpColumn and are wrapped to the width
+// set by SetDisplayWidth.
 func Help(w io.Writer, cmd, parameters string, i interface{}) {
 	if i == nil {
+		writeHelp(w, cmd, parameters)
+		return
+	}
+	writeHelp(w, cmd, parameters, i)
+}
+
+// writeHelp writes the usage line for cmd followed by the help for the
+// options of each structure in is, sorted by name.  The help column and display
+// width are those set by SetHelpColumn and SetDisplayWidth.
+func writeHelp(w io.Writer, cmd, parameters string, is ...interface{}) {
+	col, width := usageLayout()
+	if len(is) == 0 {
 		if cmd == "" {
 			return
 		}
@@ -580,19 +969,6 @@ func Help(w io.Writer, cmd, parameters string, i interface{}) {
 		}
 		return
 	}
-	v := reflect.ValueOf(i)
-	if v.Kind() != reflect.Ptr {
-		fmt.Fprintf(w, "%T is not a pointer to a struct\n", i)
-		return
-	}
-	v = v.Elem()
-	if v.Kind() != reflect.Struct {
-		fmt.Fprintf(w, "%T is not a pointer to a struct\n", i)
-		return
-	}
-	t := v.Type()
-
-	n := t.NumField()
 	type info struct {
 		prefix string
 		flag   string
@@ -600,43 +976,60 @@ func Help(w io.Writer, cmd, parameters string, i interface{}) {
 	}
 	var usage []info
 	ml := 0
-	for i := 0; i < n; i++ {
-		field := t.Field(i)
-		fv := v.Field(i)
-		tag := field.Tag.Get("getopt")
-		if tag == "-" || !fv.CanSet() {
-			continue
-		}
-		o, err := parseTag(tag)
-		if err != nil {
-			continue
-		}
-		if o == nil {
-			o = &optTag{name: strings.ToLower(field.Name)}
-		}
-		i := info{
-			prefix: "--",
-			flag:   o.name,
-			help:   o.help,
+	for _, i := range is {
+		v := reflect.ValueOf(i)
+		if v.Kind() != reflect.Ptr {
+			fmt.Fprintf(w, "%T is not a pointer to a struct\n", i)
+			return
 		}
-		if len(o.name) == 1 {
-			i.prefix = " -"
+		v = v.Elem()
+		if v.Kind() != reflect.Struct {
+			fmt.Fprintf(w, "%T is not a pointer to a struct\n", i)
+			return
 		}
-		opt := fv.Addr().Interface()
-		if _, ok := opt.(*bool); !ok {
-			if o.param == "" {
-				o.param = "VALUE"
+		t := v.Type()
+
+		n := t.NumField()
+		for i := 0; i < n; i++ {
+			field := t.Field(i)
+			fv := v.Field(i)
+			tag := field.Tag.Get("getopt")
+			if tag == "-" || !fv.CanSet() {
+				continue
+			}
+			o, err := parseTag(field)
+			if err != nil {
+				continue
+			}
+			i := info{
+				prefix: "--",
+				flag:   o.Name(),
+				help:   o.Help,
+			}
+			if o.Long == "" {
+				i.prefix = " -"
+			}
+			opt := fv.Addr().Interface()
+			if _, ok := opt.(*bool); !ok {
+				if o.Param == "" {
+					o.Param = "VALUE"
+				}
+				i.flag += "=" + o.Param
+			}
+			usage = append(usage, i)
+			if _, ok := opt.(*bool); ok && o.Negate && o.Long != "" {
+				usage = append(usage, info{prefix: "--", flag: "no-" + o.Long, help: "do not " + o.Help})
 			}
-			i.flag += "=" + o.param
 		}
-		if n := len(i.flag) + 1 + len(i.prefix); n > ml && n <= 20 {
+	}
+	for _, i := range usage {
+		if n := len(i.flag) + 1 + len(i.prefix); n > ml && n <= col {
 			ml = n
 		}
-		usage = append(usage, i)
 	}
 	sort.Slice(usage, func(i, j int) bool { return usage[i].flag < usage[j].flag })
-	if ml > 20 {
-		ml = 20
+	if ml > col {
+		ml = col
 	}
 	if cmd != "" {
 		fmt.Fprintf(w, "Usage: %s", cmd)
@@ -650,10 +1043,36 @@ func Help(w io.Writer, cmd, parameters string, i interface{}) {
 	}
 	for _, i := range usage {
 		flag := i.prefix + i.flag
+		lines := wrapHelp(i.help, width-ml-1)
 		if len(flag) > ml {
-			fmt.Fprintf(w, "%s\n%*s %s\n", flag, ml, "", i.help)
+			fmt.Fprintf(w, "%s\n", flag)
 		} else {
-			fmt.Fprintf(w, "%s%*s %s\n", flag, ml-len(flag), "", i.help)
+			fmt.Fprintf(w, "%s%*s %s\n", flag, ml-len(flag), "", lines[0])
+			lines = lines[1:]
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "%*s %s\n", ml, "", line)
+		}
+	}
+}
+
+// wrapHelp splits help into lines of no more than width bytes, breaking at
+// white space.  A single word longer than width is not broken.  help is
+// returned as a single line if width is not positive.
+func wrapHelp(help string, width int) []string {
+	words := strings.Fields(help)
+	if width <= 0 || len(words) == 0 {
+		return []string{help}
+	}
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
 		}
+		line += " " + word
 	}
+	return append(lines, line)
 }
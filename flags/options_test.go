@@ -15,13 +15,19 @@ package flags
 
 import (
 	"bytes"
+	"errors"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/openconfig/gnmi/errdiff"
+	"github.com/pborman/options/tag"
 )
 
 type X string
@@ -152,10 +158,15 @@ func TestRegister(t *testing.T) {
 				t.Errorf("Registerdid not panic on bad tag")
 			}
 		}()
-		register("test", &struct {
+		Register(&struct {
 			F int `getopt:"bad"`
-		}{}, NewFlagSet(""))
+		}{})
 	}()
+	if err := register("test", &struct {
+		F int `getopt:"bad"`
+	}{}, NewFlagSet("")); err == nil {
+		t.Errorf("Did not get an error on bad tag")
+	}
 }
 
 func TestMultiString(t *testing.T) {
@@ -189,6 +200,152 @@ func TestMultiString(t *testing.T) {
 	}
 }
 
+func TestListString(t *testing.T) {
+	for _, tt := range []struct {
+		l    list
+		want string
+	}{
+		{nil, ``},
+		{list{"a"}, `"a"`},
+		{list{"a", "b c"}, `"a" "b c"`},
+		{list{"a b", "c"}, `"a b" "c"`},
+		{list{"", `say "hi"`}, `"" "say \"hi\""`},
+	} {
+		if got := tt.l.String(); got != tt.want {
+			t.Errorf("%q: got %s, want %s", []string(tt.l), got, tt.want)
+		}
+		if got := tt.l.Get().([]string); !reflect.DeepEqual(got, []string(tt.l)) {
+			t.Errorf("Get got %q, want %q", got, []string(tt.l))
+		}
+	}
+}
+
+func TestNumericLists(t *testing.T) {
+	var opts struct {
+		Ports   []int           `getopt:"--port=PORT listen on PORT"`
+		Backoff []time.Duration `getopt:"--backoff=DELAY retry after DELAY"`
+	}
+	_, err := SubRegisterAndParse(&opts, []string{"name", "--port", "80", "--port=0x1bb", "--backoff=1s", "--backoff", "1m30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{80, 443}; !reflect.DeepEqual(opts.Ports, want) {
+		t.Errorf("got ports %v, want %v", opts.Ports, want)
+	}
+	if want := []time.Duration{time.Second, 90 * time.Second}; !reflect.DeepEqual(opts.Backoff, want) {
+		t.Errorf("got backoff %v, want %v", opts.Backoff, want)
+	}
+	if got, want := (*intList)(&opts.Ports).String(), "80 443"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := (*durationList)(&opts.Backoff).String(), "1s 1m30s"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, arg := range []string{"--port=x", "--backoff=1"} {
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		var opts struct {
+			Ports   []int           `getopt:"--port=PORT listen on PORT"`
+			Backoff []time.Duration `getopt:"--backoff=DELAY retry after DELAY"`
+		}
+		if err := RegisterSet("", &opts, set); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Parse([]string{arg}); err == nil {
+			t.Errorf("%s did not fail", arg)
+		}
+	}
+}
+
+func TestFlagUsage(t *testing.T) {
+	var opts struct {
+		Name    string `getopt:"--name=NAME  set the name to NAME"`
+		Level   int    `getopt:"--level=LEVEL set the level"`
+		Sub     string `getopt:"--sub=N      N is not NAME"`
+		Plain   string `getopt:"--plain      no parameter"`
+		Verbose bool   `getopt:"-v=BOOL      be verbose"`
+	}
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := RegisterSet("", &opts, set); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		flag, name, usage string
+	}{
+		{"name", "NAME", "set the name to NAME"},
+		{"level", "LEVEL", "LEVEL set the level"},
+		{"sub", "N", "N is not NAME"},
+		{"plain", "string", "no parameter"},
+		{"v", "", "be verbose"},
+	} {
+		name, usage := flag.UnquoteUsage(set.Lookup(tt.flag))
+		if name != tt.name || usage != tt.usage {
+			t.Errorf("%s: got %q, %q, want %q, %q", tt.flag, name, usage, tt.name, tt.usage)
+		}
+	}
+	o := &tag.Spec{Tag: tag.Tag{Long: "quoted", Param: "Q", Help: "a `file` name"}}
+	if got := flagUsage(o, reflect.ValueOf("")); got != o.Help {
+		t.Errorf("got %q, want %q", got, o.Help)
+	}
+}
+
+func TestNegateAndCounter(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		color   bool
+		verbose Counter
+	}{
+		{args: []string{"name"}, color: true},
+		{args: []string{"name", "--no-color"}},
+		{args: []string{"name", "--no-color", "--color"}, color: true},
+		{args: []string{"name", "--no-color=false"}, color: true},
+		{args: []string{"name", "-v", "-v", "-v"}, color: true, verbose: 3},
+		{args: []string{"name", "-v=5", "-v"}, color: true, verbose: 6},
+	} {
+		opts := &struct {
+			Color   bool    `getopt:"--color colorize output" negate:""`
+			Verbose Counter `getopt:"-v      increase verbosity"`
+		}{Color: true}
+		if _, err := SubRegisterAndParse(opts, tt.args); err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if opts.Color != tt.color || opts.Verbose != tt.verbose {
+			t.Errorf("%v: got %v, %d, want %v, %d", tt.args, opts.Color, opts.Verbose, tt.color, tt.verbose)
+		}
+	}
+
+	var out bytes.Buffer
+	Help(&out, "", "", &struct {
+		Color bool `getopt:"--color colorize output" negate:""`
+	}{})
+	want := `
+--color     colorize output
+--no-color  do not colorize output
+`[1:]
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	err := RegisterSet("", &struct {
+		Name    string `getopt:"--name" negate:""`
+		Color   bool   `getopt:"--color" negate:""`
+		NoColor bool   `getopt:"--no-color"`
+	}{}, set)
+	if err == nil {
+		t.Fatal("RegisterSet did not fail")
+	}
+	if !strings.Contains(err.Error(), "Name: negate tag") {
+		t.Errorf("missing negate error: %v", err)
+	}
+	var de *DuplicateOptionError
+	if !errors.As(err, &de) || de.Name != "no-color" {
+		t.Errorf("missing duplicate no-color error: %v", err)
+	}
+}
+
 func TestSubRegisterAndParse(t *testing.T) {
 	opts := struct {
 		Value string `getopt:"--the_name=VALUE help"`
@@ -230,169 +387,6 @@ func TestSubRegisterAndParse(t *testing.T) {
 	}
 }
 
-func TestParseTag(t *testing.T) {
-	for _, tt := range []struct {
-		name string
-		in   string
-		tag  *optTag
-		str  string
-		err  string
-	}{
-		{
-			name: "nothing",
-		},
-		{
-			name: "dash",
-			in:   "-",
-		},
-		{
-			name: "dash-dash",
-			in:   "--",
-		},
-		{
-			name: "long arg",
-			in:   "--option",
-			str:  "{ --option }",
-			tag: &optTag{
-				name: "option",
-			},
-		},
-		{
-			name: "short arg",
-			in:   "-o",
-			str:  "{ -o }",
-			tag: &optTag{
-				name: "o",
-			},
-		},
-		{
-			name: "long help",
-			in:   "--option this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				name: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help1",
-			in:   "--option -- this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				name: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help2",
-			in:   "--option - this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				name: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help3",
-			in:   "--option -- -this is an option",
-			str:  `{ --option "-this is an option" }`,
-			tag: &optTag{
-				name: "option",
-				help: "-this is an option",
-			},
-		},
-		{
-			name: "long arg with param",
-			in:   "--option=PARAM",
-			str:  "{ --option =PARAM }",
-			tag: &optTag{
-				name:  "option",
-				param: "PARAM",
-			},
-		},
-		{
-			name: "everything",
-			in:   "--option=PARAM -- - this is help",
-			str:  `{ --option =PARAM "- this is help" }`,
-			tag: &optTag{
-				name:  "option",
-				param: "PARAM",
-				help:  "- this is help",
-			},
-		},
-		{
-			name: "two longs",
-			in:   "--option1 --option2",
-			err:  "tag has too many names",
-		},
-		{
-			name: "two shorts",
-			in:   "-a -b",
-			err:  "tag has too many names",
-		},
-		{
-			name: "two parms",
-			in:   "--option=PARAM1 -o=PARAM2",
-			err:  "tag has multiple parameter names",
-		},
-		{
-			name: "missing option",
-			in:   "no option",
-			err:  "tag missing option name",
-		},
-		{
-			name: "long param only",
-			in:   "--=PARAM",
-			err:  "tag missing option name",
-		},
-		{
-			name: "short param only",
-			in:   "-=PARAM",
-			err:  "tag missing option name",
-		},
-	} {
-		t.Run(tt.name, func(t *testing.T) {
-			tag, err := parseTag(tt.in)
-			switch {
-			case err == nil && tt.err != "":
-				t.Fatalf("did not get expected error %v", tt.err)
-			case err != nil && tt.err == "":
-				t.Fatalf("unexpected error %v", err)
-			case err == nil:
-			case !strings.Contains(err.Error(), tt.err):
-				t.Fatalf("got error %v, want %v", err, tt.err)
-			}
-			if !reflect.DeepEqual(tag, tt.tag) {
-				t.Errorf("got %v, want %v", tag, tt.tag)
-			}
-			if tag != nil {
-				str := tag.String()
-				if str != tt.str {
-					t.Errorf("%s: got string %q, want %q", tt.name, str, tt.str)
-				}
-			}
-		})
-	}
-}
-
-func TestArgPrefix(t *testing.T) {
-	for _, tt := range []struct {
-		in  string
-		out string
-	}{
-		{"a", ""},
-		{"-a", "-"},
-		{"--a", "--"},
-		{"", ""},
-		{"-", "-"},
-		{"--", "--"},
-	} {
-		if out := argPrefix(tt.in); out != tt.out {
-			t.Errorf("argPrefix(%q) got %q want %q", tt.in, out, tt.out)
-		}
-	}
-}
-
 func TestDup(t *testing.T) {
 	// Most of Dup is tested via other test methods.  We need to test the errors.
 	func() {
@@ -495,3 +489,882 @@ Usage: xyzzy [--alpha=LEVEL] [--beta=N] [ -f=RATE] [--list=ITEM] [--the_real_fan
 		t.Errorf("got:\n%s\nwant:\n%s", got, want)
 	}
 }
+
+func TestNarrowTypes(t *testing.T) {
+	type narrow struct {
+		I8  int8    `getopt:"--i8"`
+		I16 int16   `getopt:"--i16"`
+		I32 int32   `getopt:"--i32"`
+		U8  uint8   `getopt:"--u8"`
+		U16 uint16  `getopt:"--u16"`
+		U32 uint32  `getopt:"--u32"`
+		F32 float32 `getopt:"--f32"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want narrow
+		err  string
+	}{{
+		args: []string{"name"},
+		want: narrow{I8: 1},
+	}, {
+		args: []string{"name", "--i8=-128", "--i16=32767", "--i32=0x10", "--u8=255", "--u16=65535", "--u32=42", "--f32=1.5"},
+		want: narrow{I8: -128, I16: 32767, I32: 16, U8: 255, U16: 65535, U32: 42, F32: 1.5},
+	}, {
+		args: []string{"name", "--i8=128"},
+		err:  "value out of range",
+	}, {
+		args: []string{"name", "--u8=-1"},
+		err:  "parse error",
+	}, {
+		args: []string{"name", "--u16=65536"},
+		err:  "value out of range",
+	}, {
+		args: []string{"name", "--i32=bob"},
+		err:  "parse error",
+	}, {
+		args: []string{"name", "--f32=1e39"},
+		err:  "value out of range",
+	}} {
+		opts := narrow{I8: 1}
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		set.SetOutput(&bytes.Buffer{})
+		if err := RegisterSet("", &opts, set); err != nil {
+			t.Fatal(err)
+		}
+		err := set.Parse(tt.args[1:])
+		if s := errdiff.Check(err, tt.err); s != "" {
+			t.Errorf("%q: %s", tt.args, s)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if opts != tt.want {
+			t.Errorf("%q got %+v, want %+v", tt.args, opts, tt.want)
+		}
+	}
+}
+
+func TestFuncOption(t *testing.T) {
+	var loaded []string
+	errBad := errors.New("bad file")
+	opts := &struct {
+		Load  func(string) error `getopt:"--load=FILE load FILE"`
+		Unset func(string) error `getopt:"--unset"`
+	}{
+		Load: func(s string) error {
+			if s == "bad" {
+				return errBad
+			}
+			loaded = append(loaded, s)
+			return nil
+		},
+	}
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.SetOutput(&bytes.Buffer{})
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Parse([]string{"--load", "a", "--load=b"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, []string{"a", "b"}) {
+		t.Errorf("got %q, want %q", loaded, []string{"a", "b"})
+	}
+	if s := errdiff.Check(set.Parse([]string{"--load", "bad"}), errBad.Error()); s != "" {
+		t.Errorf("%s", s)
+	}
+	if err := set.Parse([]string{"--unset", "a"}); err == nil {
+		t.Errorf("nil function did not return an error")
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	eh, cl := ErrorHandling, CommandLine
+	defer func() { ErrorHandling, CommandLine = eh, cl }()
+
+	ErrorHandling = flag.ContinueOnError
+	opts := &struct {
+		Count int `getopt:"--count"`
+	}{}
+	var out bytes.Buffer
+	CommandLine = NewFlagSet("test")
+	CommandLine.SetOutput(&out)
+	if _, err := RegisterAndParseArgs(opts, []string{"--count=bob"}); err == nil {
+		t.Errorf("RegisterAndParseArgs did not return an error")
+	}
+
+	dup := &struct {
+		Name  string `getopt:"--name"`
+		Other string `getopt:"--name"`
+	}{}
+	if _, err := RegisterAndParseArgs(dup, nil); err == nil {
+		t.Errorf("RegisterAndParseArgs did not return a registration error")
+	}
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	os.Args = []string{"test", "--count=bob"}
+	CommandLine = NewFlagSet("test")
+	CommandLine.SetOutput(&out)
+	if args := RegisterAndParse(&struct {
+		Count int `getopt:"--count"`
+	}{}); args != nil {
+		t.Errorf("RegisterAndParse returned %q on error, want nil", args)
+	}
+
+	args, err := SubRegisterAndParse(&struct {
+		Count int `getopt:"--count"`
+	}{}, []string{"name", "--count=1", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"a"}) {
+		t.Errorf("got args %q, want %q", args, []string{"a"})
+	}
+
+	ErrorHandling = flag.PanicOnError
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Errorf("did not panic with PanicOnError")
+			}
+		}()
+		set := NewFlagSet("test")
+		set.SetOutput(&out)
+		RegisterSet("", &struct {
+			Count int `getopt:"--count"`
+		}{}, set)
+		set.Parse([]string{"--count=bob"})
+	}()
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Errorf("RegisterAndParse did not panic with PanicOnError")
+			}
+		}()
+		CommandLine = NewFlagSet("test")
+		CommandLine.SetOutput(&out)
+		RegisterAndParse(dup)
+	}()
+}
+
+func TestDuplicateNames(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts interface{}
+		err  string
+	}{{
+		name: "unique",
+		opts: &struct {
+			Name  string `getopt:"--name"`
+			Count int
+		}{},
+	}, {
+		name: "explicit",
+		opts: &struct {
+			Name  string `getopt:"--name"`
+			Other string `getopt:"--name"`
+		}{},
+		err: `option "name" declared by both Name and Other`,
+	}, {
+		name: "auto",
+		opts: &struct {
+			Count int `getopt:"--name"`
+			Name  string
+		}{},
+		err: `option "name" declared by both Count and Name`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			if s := errdiff.Check(Validate(tt.opts), tt.err); s != "" {
+				t.Errorf("%s", s)
+			}
+		})
+	}
+
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.String("name", "", "already defined")
+	err := RegisterSet("", &struct {
+		Count int    `getopt:"--count"`
+		Name  string `getopt:"--name"`
+	}{}, set)
+	if s := errdiff.Check(err, `Name: option "name" already defined`); s != "" {
+		t.Errorf("%s", s)
+	}
+	if set.Lookup("count") != nil {
+		t.Errorf("--count registered after error")
+	}
+}
+
+func TestVisit(t *testing.T) {
+	opts := &struct {
+		Name    string `getopt:"--name"`
+		Count   int    `getopt:"--count"`
+		Verbose bool   `getopt:"-v"`
+	}{
+		Name: "bob",
+	}
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.Bool("other", false, "not in opts")
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Parse([]string{"--count=4", "-v", "--other"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, seen := LookupSeen(opts, set, "name"); v.(string) != "bob" || seen {
+		t.Errorf("--name got %v, %v, want bob, false", v, seen)
+	}
+	if v, seen := LookupSeen(opts, set, "count"); v.(int) != 4 || !seen {
+		t.Errorf("--count got %v, %v, want 4, true", v, seen)
+	}
+	if v, seen := LookupSeen(opts, set, "other"); v != nil || seen {
+		t.Errorf("--other got %v, %v, want nil, false", v, seen)
+	}
+
+	var got []string
+	Visit(opts, set, func(name string, value interface{}) {
+		got = append(got, fmt.Sprintf("%s=%v", name, value))
+	})
+	want := []string{"count=4", "v=true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Visit got %q, want %q", got, want)
+	}
+}
+
+func TestSeparateTags(t *testing.T) {
+	opts := &struct {
+		Name  string `long:"name" param:"NAME" help:"name of the widget"`
+		Count int    `getopt:"--count" help:"number of widgets"`
+		V     bool   `help:"be verbose"`
+	}{}
+	want := `
+--count=VALUE  number of widgets
+--name=NAME    name of the widget
+ -v            be verbose
+`[1:]
+	var out bytes.Buffer
+	Help(&out, "", "", opts)
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := SubRegisterAndParse(opts, []string{"name", "--name=bob", "--count=2", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 2 || !opts.V {
+		t.Errorf("got %+v", opts)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	opts := &struct {
+		A     int      `getopt:"bad tag"`
+		B     chan int `getopt:"--chan"`
+		Name  string   `getopt:"--name"`
+		Other string   `getopt:"--name"`
+		Good  string
+	}{}
+	err := Validate(opts)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("got error %v of type %T, want %T", err, err, errs)
+	}
+	want := []string{
+		"A: getopt tag missing option name",
+		"B: unsupported option type: chan int",
+		`option "name" declared by both Name and Other`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(want), err)
+	}
+	for i, w := range want {
+		if s := errdiff.Check(errs[i], w); s != "" {
+			t.Errorf("error %d: %s", i, s)
+		}
+	}
+
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := RegisterSet("", opts, set); err == nil {
+		t.Fatalf("RegisterSet did not return an error")
+	}
+	set.VisitAll(func(f *flag.Flag) {
+		t.Errorf("flag %s registered after an error", f.Name)
+	})
+}
+
+// A noVarSet is a FlagSet without Var and Lookup methods.
+type noVarSet struct{ FlagSet }
+
+func TestRegisterNoPanic(t *testing.T) {
+	set := noVarSet{flag.NewFlagSet("", flag.ContinueOnError)}
+	set.SetOutput(ioutil.Discard)
+	opts := &struct {
+		Name string   `getopt:"--name"`
+		List []string `getopt:"--list"`
+	}{}
+	if err := RegisterSet("", opts, set); err == nil || !strings.Contains(err.Error(), "missing Var method") {
+		t.Errorf("got error %v, want missing Var method", err)
+	}
+	if err := set.Parse([]string{"--name=bob"}); err == nil {
+		t.Errorf("--name was defined after a failed RegisterSet")
+	}
+
+	set = noVarSet{flag.NewFlagSet("", flag.ContinueOnError)}
+	set.SetOutput(ioutil.Discard)
+	if err := RegisterSet("", &struct {
+		Name string `getopt:"--name"`
+	}{}, set); err != nil {
+		t.Errorf("RegisterSet without Var: %v", err)
+	}
+	if err := RegisterSet("", &struct {
+		Name string `getopt:"--name"`
+	}{}, set); err == nil {
+		t.Errorf("redefining --name did not fail")
+	}
+}
+
+func TestBind(t *testing.T) {
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.String("name", "", "")
+	set.Duration("timeout", 0, "")
+	set.Int("level", 0, "")
+	set.Var(new(X), "x", "")
+	if err := set.Parse([]string{"--name=bob", "--timeout=5s", "--level=3", "--x=xyzzy"}); err != nil {
+		t.Fatal(err)
+	}
+	opts := &struct {
+		Name    string        `getopt:"--name"`
+		Timeout time.Duration `getopt:"--timeout"`
+		Level   int8          `getopt:"--level"`
+		X       string        `getopt:"--x"`
+		Missing string        `getopt:"--missing"`
+	}{Missing: "unchanged"}
+	if err := Bind(set, opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Timeout != 5*time.Second || opts.Level != 3 || opts.X != "xyzzy" || opts.Missing != "unchanged" {
+		t.Errorf("got %+v", *opts)
+	}
+
+	set = flag.NewFlagSet("", flag.ContinueOnError)
+	set.Int("level", 300, "")
+	if err := Bind(set, &struct {
+		Level int8 `getopt:"--level"`
+	}{}); err == nil {
+		t.Errorf("Bind did not fail with an out of range value")
+	}
+	if err := Bind(noVarSet{set}, &struct {
+		Level int `getopt:"--level"`
+	}{}); err == nil {
+		t.Errorf("Bind did not fail without a Lookup method")
+	}
+}
+
+func TestGetter(t *testing.T) {
+	opts := &struct {
+		I8       int8              `getopt:"--i8"`
+		U16      uint16            `getopt:"--u16"`
+		F32      float32           `getopt:"--f32"`
+		B        bool              `getopt:"--bee" negate:""`
+		List     []string          `getopt:"--list"`
+		Ints     []int             `getopt:"--ints"`
+		Backoff  []time.Duration   `getopt:"--backoff"`
+		Limits   map[string]int    `getopt:"--limits"`
+		Verbose  Counter           `getopt:"-v"`
+		Size     int               `getopt:"--size" units:"si"`
+		Optional string            `getopt:"--optional" implicit:"yes"`
+		Labels   map[string]string `getopt:"--labels"`
+	}{}
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	err := set.Parse([]string{
+		"--i8=-3", "--u16=7", "--f32=1.5", "--bee", "--list=a", "--ints=1",
+		"--backoff=1s", "--limits=cpu=4", "-v", "-v", "--size=2k",
+		"--optional", "--labels=a=b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]interface{}{
+		"i8":       int8(-3),
+		"u16":      uint16(7),
+		"f32":      float32(1.5),
+		"bee":      true,
+		"no-bee":   false,
+		"list":     []string{"a"},
+		"ints":     []int{1},
+		"backoff":  []time.Duration{time.Second},
+		"limits":   map[string]int{"cpu": 4},
+		"v":        2,
+		"size":     2000,
+		"optional": "yes",
+		"labels":   map[string]string{"a": "b"},
+	} {
+		g, ok := set.Lookup(name).Value.(flag.Getter)
+		if !ok {
+			t.Errorf("%s: %T is not a flag.Getter", name, set.Lookup(name).Value)
+			continue
+		}
+		if got := g.Get(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", name, got, want)
+		}
+	}
+}
+
+// A noTextVarSet is a flag.FlagSet whose TextVar method cannot be used.
+type noTextVarSet struct{ *flag.FlagSet }
+
+func (noTextVarSet) TextVar() {}
+
+func TestTextVar(t *testing.T) {
+	for _, set := range []FlagSet{
+		flag.NewFlagSet("", flag.ContinueOnError),
+		noTextVarSet{flag.NewFlagSet("", flag.ContinueOnError)},
+	} {
+		opts := &struct {
+			IP net.IP `getopt:"--ip"`
+		}{IP: net.ParseIP("127.0.0.1")}
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Parse([]string{"--ip=10.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+		if !opts.IP.Equal(net.ParseIP("10.0.0.1")) {
+			t.Errorf("%T: got %v, want 10.0.0.1", set, opts.IP)
+		}
+		f := set.(interface{ Lookup(string) *flag.Flag }).Lookup("ip")
+		if f.DefValue != "127.0.0.1" {
+			t.Errorf("%T: got default %q, want 127.0.0.1", set, f.DefValue)
+		}
+		_, isText := f.Value.(textValue)
+		if want := !textVarMethod(set).IsValid(); isText != want {
+			t.Errorf("%T: got textValue %v, want %v", set, isText, want)
+		}
+	}
+}
+
+func TestAutoNames(t *testing.T) {
+	opts := &struct {
+		N        int
+		MaxCount int
+		Ä        bool
+		Name     string `help:"the name"`
+	}{}
+	want := []struct {
+		long  string
+		short rune
+		help  string
+	}{
+		{short: 'n', help: " -n=VALUE"},
+		{long: "maxcount", help: "--maxcount=VALUE"},
+		{short: 'ä', help: " -ä"},
+		{long: "name", help: "--name=VALUE"},
+	}
+	var out bytes.Buffer
+	Help(&out, "", "", opts)
+	help := strings.Split(out.String(), "\n")
+	st := reflect.TypeOf(opts).Elem()
+	for i, w := range want {
+		field := st.Field(i)
+		o, err := parseTag(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Long != w.long || o.Short != w.short {
+			t.Errorf("%s: got %q/%q, want %q/%q", field.Name, o.Long, o.Short, w.long, w.short)
+		}
+		// The options package must generate the same names.
+		oo, err := tag.FieldSpec(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Long != oo.Long || o.Short != oo.Short {
+			t.Errorf("%s: got %q/%q, options package has %q/%q", field.Name, o.Long, o.Short, oo.Long, oo.Short)
+		}
+		found := false
+		for _, line := range help {
+			found = found || strings.HasPrefix(line, w.help+" ")
+		}
+		if !found {
+			t.Errorf("%s: help does not display %s:\n%s", field.Name, w.help, out.String())
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	opts := &struct {
+		A     int      `getopt:"bad tag"`
+		B     chan int `getopt:"--chan"`
+		Name  string   `getopt:"--name"`
+		Other string   `getopt:"--name"`
+	}{}
+	errs, ok := Validate(opts).(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("got %v, want 3 errors", errs)
+	}
+
+	var te *TagError
+	if !errors.As(errs[0], &te) {
+		t.Errorf("got %T, want %T", errs[0], te)
+	} else if te.Field != "A" || te.Tag != "bad tag" {
+		t.Errorf("got field %q tag %q, want field %q tag %q", te.Field, te.Tag, "A", "bad tag")
+	}
+
+	var ue *UnsupportedTypeError
+	if !errors.As(errs[1], &ue) {
+		t.Errorf("got %T, want %T", errs[1], ue)
+	} else if ue.Field != "B" || ue.Type != reflect.TypeOf(opts.B) {
+		t.Errorf("got field %q type %v, want field %q type %T", ue.Field, ue.Type, "B", opts.B)
+	}
+
+	var de *DuplicateOptionError
+	if !errors.As(errs[2], &de) {
+		t.Errorf("got %T, want %T", errs[2], de)
+	} else if de.Name != "name" || de.Field != "Other" || de.Other != "Name" {
+		t.Errorf("got %+v", de)
+	}
+
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.String("name", "", "already defined")
+	err := RegisterSet("", &struct{ Name string }{}, set)
+	if !errors.As(err, &de) {
+		t.Fatalf("got %T, want %T", err, de)
+	}
+	if de.Name != "name" || de.Field != "Name" || de.Other != "" {
+		t.Errorf("got %+v", de)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	type options struct {
+		Color string `getopt:"--color?=WHEN colorize output" implicit:"auto"`
+		Level int    `getopt:"--level?=N set the level"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+	}{
+		{nil, options{Color: "never", Level: 1}},
+		{[]string{"--color"}, options{Color: "auto", Level: 1}},
+		{[]string{"--color=always"}, options{Color: "always", Level: 1}},
+		{[]string{"--level"}, options{Color: "never", Level: 1}},
+		{[]string{"--level=3", "--color"}, options{Color: "auto", Level: 3}},
+	} {
+		opts := &options{Color: "never", Level: 1}
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Parse(tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if *opts != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+	}
+}
+
+func TestOptionalExplicit(t *testing.T) {
+	type options struct {
+		Color string `getopt:"--color?=WHEN colorize output" implicit:"auto"`
+		Name  string `getopt:"--name=NAME name"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+	}{
+		{[]string{"cmd", "--color"}, options{Color: "auto"}},
+		{[]string{"cmd", "--color=true"}, options{Color: "true"}},
+		{[]string{"cmd", "-color=true", "--color"}, options{Color: "auto"}},
+		{[]string{"cmd", "--color", "--color=true"}, options{Color: "true"}},
+		{[]string{"cmd", "--name", "--color", "--color=true"}, options{Color: "true", Name: "--color"}},
+		{[]string{"cmd", "--name", "--color", "--color"}, options{Color: "auto", Name: "--color"}},
+	} {
+		opts := &options{}
+		if _, err := SubRegisterAndParse(opts, tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if *opts != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	type options struct {
+		Env      map[string]string `getopt:"--env=KEY=VALUE set an environment variable"`
+		Limit    map[string]int    `getopt:"--limit=KEY=N set a limit"`
+		Features map[string]bool   `getopt:"--feature=NAME enable a feature"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+		err  string
+	}{{
+		want: options{Limit: map[string]int{"cpu": 1}},
+	}, {
+		args: []string{"--env", "a=b=c", "--env=d=", "--limit", "cpu=4", "--limit=mem=0x800", "--feature=fast", "--feature", "slow=false"},
+		want: options{
+			Env:      map[string]string{"a": "b=c", "d": ""},
+			Limit:    map[string]int{"cpu": 4, "mem": 2048},
+			Features: map[string]bool{"fast": true, "slow": false},
+		},
+	}, {
+		args: []string{"--limit", "mem=lots"},
+		err:  `key "mem": invalid integer "lots"`,
+	}, {
+		args: []string{"--env", "novalue"},
+		err:  `"novalue" is not of the form key=value`,
+	}, {
+		args: []string{"--feature", "fast=maybe"},
+		err:  `key "fast": invalid boolean "maybe"`,
+	}} {
+		defaults := &options{Limit: map[string]int{"cpu": 1}}
+		opts := Dup(defaults).(*options)
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		err := set.Parse(tt.args)
+		if s := errdiff.Check(err, tt.err); s != "" {
+			t.Errorf("%q: %s", tt.args, s)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(*opts, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+		if defaults.Limit["cpu"] != 1 || len(defaults.Limit) != 1 {
+			t.Errorf("%q: defaults modified: %v", tt.args, defaults.Limit)
+		}
+	}
+}
+
+func TestBoolSpellings(t *testing.T) {
+	for _, tt := range []struct {
+		arg  string
+		want bool
+		err  string
+	}{
+		{"-v", true, ""},
+		{"-v=yes", true, ""},
+		{"-v=ON", true, ""},
+		{"-v=y", true, ""},
+		{"-v=1", true, ""},
+		{"-v=No", false, ""},
+		{"-v=off", false, ""},
+		{"-v=0", false, ""},
+		{"-v=false", false, ""},
+		{"-v=maybe", false, "parse error"},
+	} {
+		opts := &struct {
+			V bool `getopt:"-v be verbose"`
+		}{V: !tt.want}
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		err := set.Parse([]string{tt.arg})
+		if s := errdiff.Check(err, tt.err); s != "" {
+			t.Errorf("%s: %s", tt.arg, s)
+			continue
+		}
+		if err == nil && opts.V != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.arg, opts.V, tt.want)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	type options struct {
+		Events int    `getopt:"--events=N number of events" units:"si"`
+		Buffer uint16 `getopt:"--buffer=N buffer size" units:"iec"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+		err  string
+	}{
+		{[]string{"--events=10k"}, options{Events: 10000}, ""},
+		{[]string{"--events=2M"}, options{Events: 2000000}, ""},
+		{[]string{"--events=-1G"}, options{Events: -1000000000}, ""},
+		{[]string{"--events=2Mi"}, options{Events: 2 << 20}, ""},
+		{[]string{"--events=42"}, options{Events: 42}, ""},
+		{[]string{"--events=0x10k"}, options{Events: 16000}, ""},
+		{[]string{"--buffer=4k"}, options{Buffer: 4096}, ""},
+		{[]string{"--buffer=63Ki"}, options{Buffer: 63 << 10}, ""},
+		{[]string{"--buffer=64k"}, options{}, "value out of range"},
+		{[]string{"--events=20E"}, options{}, "value out of range"},
+		{[]string{"--events=10x"}, options{}, "parse error"},
+	} {
+		opts := &options{}
+		set := flag.NewFlagSet("", flag.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		err := set.Parse(tt.args)
+		if s := errdiff.Check(err, tt.err); s != "" {
+			t.Errorf("%q: %s", tt.args, s)
+			continue
+		}
+		if err == nil && *opts != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+	}
+
+	err := Validate(&struct {
+		A string        `units:"si"`
+		B time.Duration `units:"si"`
+		C int           `units:"metric"`
+	}{})
+	want := []string{
+		"A: units do not apply to string",
+		"B: units do not apply to time.Duration",
+		`C: unknown units "metric"`,
+	}
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != len(want) {
+		t.Fatalf("got error %v, want %d errors", err, len(want))
+	}
+	for i, w := range want {
+		if s := errdiff.Check(errs[i], w); s != "" {
+			t.Errorf("error %d: %s", i, s)
+		}
+	}
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	opts := &struct {
+		Addr net.IP `getopt:"--addr=IP address to use"`
+	}{}
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Parse([]string{"--addr=10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := net.ParseIP("10.0.0.1"); !opts.Addr.Equal(want) {
+		t.Errorf("got %v, want %v", opts.Addr, want)
+	}
+	if got := set.Lookup("addr").Value.String(); got != "10.0.0.1" {
+		t.Errorf("got string %q, want %q", got, "10.0.0.1")
+	}
+	err := set.Parse([]string{"--addr=bogus"})
+	if s := errdiff.Check(err, `invalid value "bogus"`); s != "" {
+		t.Error(s)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	defer func(cl FlagSet) {
+		CommandLine = cl
+		usageState.program = ""
+		usageState.parameters = "[parameters ...]"
+		usageState.usage = nil
+		usageState.width = 0
+		usageState.column = 20
+		usageState.registered = nil
+	}(CommandLine)
+	usageState.registered = nil
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var errOut bytes.Buffer
+	fs.SetOutput(&errOut)
+	CommandLine = fs
+
+	Register(&struct {
+		Name    string `getopt:"--name=NAME  set the name of the thing to NAME"`
+		Verbose bool   `getopt:"-v          be verbose"`
+	}{})
+	SetProgram("prog")
+	SetParameters("FILE ...")
+	SetDisplayWidth(40)
+	SetHelpColumn(14)
+
+	want := `
+Usage: prog [--name=NAME] [ -v] FILE ...
+--name=NAME  set the name of the thing
+             to NAME
+ -v          be verbose
+`[1:]
+	var out bytes.Buffer
+	PrintUsage(&out)
+	if got := out.String(); got != want {
+		t.Errorf("PrintUsage got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// CommandLine reports errors with Usage.
+	if err := fs.Parse([]string{"--bad"}); err == nil {
+		t.Fatalf("Parse did not fail")
+	}
+	if got := errOut.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("error output got:\n%s\nwant suffix:\n%s", got, want)
+	}
+
+	called := false
+	SetUsage(func() { called = true })
+	Usage()
+	if !called {
+		t.Errorf("Usage did not call the function set by SetUsage")
+	}
+}
+
+// A usageSet is a FlagSet with a SetUsage method but no Output method.
+type usageSet struct {
+	FlagSet
+	usage func()
+}
+
+func (s *usageSet) SetUsage(usage func()) { s.usage = usage }
+
+func TestOutput(t *testing.T) {
+	defer func(cl FlagSet) {
+		CommandLine = cl
+		usageState.program = ""
+		usageState.output = nil
+		usageState.registered = nil
+	}(CommandLine)
+	usageState.registered = nil
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	set := &usageSet{FlagSet: fs}
+	CommandLine = set
+	if w := Output(set); w != os.Stderr {
+		t.Errorf("Output got %v, want os.Stderr", w)
+	}
+	var out bytes.Buffer
+	SetOutput(&out)
+	if w := Output(fs); w != &out {
+		t.Errorf("SetOutput did not set the output of CommandLine")
+	}
+	SetProgram("prog")
+	if set.usage == nil {
+		t.Fatal("SetProgram did not set the usage function of CommandLine")
+	}
+	set.usage()
+	if got, want := out.String(), "Usage: prog [parameters ...]\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	called := false
+	if !SetUsageFunc(fs, func() { called = true }) {
+		t.Errorf("SetUsageFunc failed with a flag.FlagSet")
+	}
+	fs.Usage()
+	if !called {
+		t.Errorf("SetUsageFunc did not set Usage")
+	}
+	if SetUsageFunc(noVarSet{fs}, func() {}) {
+		t.Errorf("SetUsageFunc succeeded without a Usage field")
+	}
+}
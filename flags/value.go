@@ -0,0 +1,412 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package flags
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pborman/options/internal/optvalue"
+)
+
+// The flag package only directly supports int, int64, uint, uint64, and
+// float64.  The types below adapt the remaining numeric widths to the Value
+// interface.  Each Set method checks that the parsed value fits in the
+// underlying type.
+//
+// Each of the Value types in this file also implements flag.Getter.  Get
+// returns the value in the type of the field it sets, e.g., an int8 or a
+// map[string]int, so the values of a set populated by this package can be
+// retrieved without knowing its structure.
+
+// errParse and errRange mirror the errors returned by the flag package.
+var (
+	errParse = errors.New("parse error")
+	errRange = errors.New("value out of range")
+)
+
+// numError converts a strconv.NumError into errParse or errRange.
+func numError(err error) error {
+	ne, ok := err.(*strconv.NumError)
+	if !ok {
+		return err
+	}
+	switch ne.Err {
+	case strconv.ErrSyntax:
+		return errParse
+	case strconv.ErrRange:
+		return errRange
+	}
+	return err
+}
+
+type int8Value int8
+
+func (i *int8Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 8)
+	if err != nil {
+		return numError(err)
+	}
+	*i = int8Value(v)
+	return nil
+}
+
+func (i *int8Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int8Value) Get() interface{} { return int8(*i) }
+
+type int16Value int16
+
+func (i *int16Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 16)
+	if err != nil {
+		return numError(err)
+	}
+	*i = int16Value(v)
+	return nil
+}
+
+func (i *int16Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int16Value) Get() interface{} { return int16(*i) }
+
+type int32Value int32
+
+func (i *int32Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return numError(err)
+	}
+	*i = int32Value(v)
+	return nil
+}
+
+func (i *int32Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int32Value) Get() interface{} { return int32(*i) }
+
+type uint8Value uint8
+
+func (i *uint8Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return numError(err)
+	}
+	*i = uint8Value(v)
+	return nil
+}
+
+func (i *uint8Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint8Value) Get() interface{} { return uint8(*i) }
+
+type uint16Value uint16
+
+func (i *uint16Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return numError(err)
+	}
+	*i = uint16Value(v)
+	return nil
+}
+
+func (i *uint16Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint16Value) Get() interface{} { return uint16(*i) }
+
+type uint32Value uint32
+
+func (i *uint32Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return numError(err)
+	}
+	*i = uint32Value(v)
+	return nil
+}
+
+func (i *uint32Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint32Value) Get() interface{} { return uint32(*i) }
+
+type float32Value float32
+
+func (f *float32Value) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return numError(err)
+	}
+	*f = float32Value(v)
+	return nil
+}
+
+func (f *float32Value) String() string {
+	return strconv.FormatFloat(float64(*f), 'g', -1, 32)
+}
+
+func (f *float32Value) Get() interface{} { return float32(*f) }
+
+// A funcValue calls the function it points to each time the flag is set, in
+// the same fashion as flag.Func.  The function is looked up when the flag is
+// set, not when it is registered.
+type funcValue func(string) error
+
+func (f *funcValue) Set(s string) error {
+	if *f == nil {
+		return errors.New("no function to call")
+	}
+	return (*f)(s)
+}
+
+func (f *funcValue) String() string { return "" }
+
+func (f *funcValue) Get() interface{} { return (func(string) error)(*f) }
+
+// An intList is a []int that appends each value it is set to, in the same
+// fashion list does for a []string.
+type intList []int
+
+func (l *intList) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return numError(err)
+	}
+	*l = append(*l, int(v))
+	return nil
+}
+
+func (l *intList) String() string {
+	s := make([]string, len(*l))
+	for i, v := range *l {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *intList) Get() interface{} { return []int(*l) }
+
+// A durationList is a []time.Duration that appends each value it is set to.
+type durationList []time.Duration
+
+func (l *durationList) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errParse
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+func (l *durationList) String() string {
+	s := make([]string, len(*l))
+	for i, v := range *l {
+		s[i] = v.String()
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *durationList) Get() interface{} { return []time.Duration(*l) }
+
+// An implicitValue wraps a Value so that its flag may be used without a value,
+// in the same fashion as a bool flag.  The flag package sets a bool flag to
+// "true" when it is used without a value, so a value of "true" is replaced by
+// implicit unless markExplicit recorded that it was given explicitly.  The
+// Value is not changed if implicit is empty.
+type implicitValue struct {
+	Value
+	implicit string
+	explicit []bool // whether each use in the args being parsed has a value
+}
+
+func (v *implicitValue) Set(s string) error {
+	explicit := false
+	if len(v.explicit) > 0 {
+		explicit, v.explicit = v.explicit[0], v.explicit[1:]
+	}
+	if s == "true" && !explicit {
+		if v.implicit == "" {
+			return nil
+		}
+		s = v.implicit
+	}
+	return v.Value.Set(s)
+}
+
+// markExplicit records, for each use of an option with an optional value in
+// args, whether a value was attached with an =.  The flag package passes
+// "true" to Set both for --color and --color=true, so this is the only way to
+// tell them apart.  Nothing is recorded unless set has a Lookup method that
+// returns a *flag.Flag, as flag.FlagSet does.
+func markExplicit(set FlagSet, args []string) {
+	fs, ok := set.(interface{ Lookup(string) *flag.Flag })
+	if !ok {
+		return
+	}
+	if vs, ok := set.(interface{ VisitAll(func(*flag.Flag)) }); ok {
+		vs.VisitAll(func(f *flag.Flag) {
+			if v, ok := f.Value.(*implicitValue); ok {
+				v.explicit = nil
+			}
+		})
+	}
+	for x := 0; x < len(args); x++ {
+		arg := args[x]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			return
+		}
+		name := strings.TrimPrefix(arg[1:], "-")
+		hasValue := false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, hasValue = name[:i], true
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		switch v := f.Value.(type) {
+		case *implicitValue:
+			v.explicit = append(v.explicit, hasValue)
+		case interface{ IsBoolFlag() bool }:
+			if !hasValue && !v.IsBoolFlag() {
+				x++
+			}
+		default:
+			if !hasValue {
+				x++
+			}
+		}
+	}
+}
+
+// parse parses args with set after marking which uses of options with
+// optional values have explicit values.
+func parse(set FlagSet, args []string) error {
+	markExplicit(set, args)
+	return set.Parse(args)
+}
+
+func (v *implicitValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// Get returns the value of the wrapped Value if it is a flag.Getter, otherwise
+// its string form.
+func (v *implicitValue) Get() interface{} {
+	if g, ok := v.Value.(interface{ Get() interface{} }); ok {
+		return g.Get()
+	}
+	return v.String()
+}
+
+func (v *implicitValue) IsBoolFlag() bool { return true }
+
+// The map types below add a single key=value pair to the map each time the
+// flag is set.
+type (
+	stringMap = optvalue.StringMap
+	intMap    = optvalue.IntMap
+	boolMap   = optvalue.BoolMap // sets a key to true if no value is provided
+)
+
+// parseBool is optvalue.ParseBool returning the error of the flag package.
+func parseBool(s string) (bool, error) {
+	b, err := optvalue.ParseBool(s)
+	if err != nil {
+		return false, errParse
+	}
+	return b, nil
+}
+
+// A boolValue is a bool flag that accepts the values accepted by parseBool.
+type boolValue bool
+
+func (b *boolValue) Set(s string) error {
+	v, err := parseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = boolValue(v)
+	return nil
+}
+
+func (b *boolValue) String() string { return strconv.FormatBool(bool(*b)) }
+
+func (b *boolValue) Get() interface{} { return bool(*b) }
+
+func (b *boolValue) IsBoolFlag() bool { return true }
+
+// A negBoolValue is the negated form of a bool flag, e.g., --no-verbose for
+// --verbose.  Setting it to true sets the bool to false.
+type negBoolValue bool
+
+func (b *negBoolValue) Set(s string) error {
+	v, err := parseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = negBoolValue(!v)
+	return nil
+}
+
+func (b *negBoolValue) String() string { return strconv.FormatBool(!bool(*b)) }
+
+func (b *negBoolValue) Get() interface{} { return !bool(*b) }
+
+func (b *negBoolValue) IsBoolFlag() bool { return true }
+
+// A Counter is an int option that counts the number of times it is used, e.g.,
+// -v -v -v sets a Counter to 3.  A Counter is used as a bool flag, so it does
+// not take a parameter, but it may be given an explicit count, e.g., -v=2.
+//
+//	Verbose flags.Counter `getopt:"-v increase verbosity"`
+type Counter int
+
+func (c *Counter) Set(s string) error {
+	if s == "true" {
+		*c++
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return numError(err)
+	}
+	*c = Counter(v)
+	return nil
+}
+
+func (c *Counter) String() string { return strconv.Itoa(int(*c)) }
+
+// Get implements flag.Getter.  It returns the count as an int.
+func (c *Counter) Get() interface{} { return int(*c) }
+
+func (c *Counter) IsBoolFlag() bool { return true }
+
+// A unitsValue is an integer field that accepts the SI or IEC suffixes
+// declared by the units struct tag, e.g., 10k or 2M.
+type unitsValue struct {
+	optvalue.Units
+}
+
+func (u *unitsValue) Set(s string) error { return numError(u.Units.Set(s)) }
+
+// A textValue adapts an encoding.TextUnmarshaler, such as a uuid.UUID or a
+// net.IP, to the Value interface.
+type textValue = optvalue.Text
@@ -0,0 +1,97 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+//go:build go1.18
+// +build go1.18
+
+package options
+
+import (
+	"reflect"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// An Option is a field type that records, along with the value of an option,
+// whether the option was set and where its value came from.  T may be any
+// type supported as the type of a field.
+//
+//	var myOptions = struct {
+//		Timeout options.Option[time.Duration] `getopt:"--timeout how long to wait"`
+//	}{
+//		Timeout: options.Option[time.Duration]{Value: time.Minute},
+//	}
+//	...
+//	if myOptions.Timeout.IsSet() {
+//		...
+//	}
+type Option[T any] struct {
+	Value  T      // The value of the option
+	source Source // Where Value came from
+
+	// v is the getopt.Value that sets *vp.  v is rebuilt if the Option
+	// has been copied, e.g., by Dup, so that v sets the copy's Value.
+	v  getopt.Value
+	vp *T
+}
+
+// Get returns the value of o.
+func (o *Option[T]) Get() T {
+	return o.Value
+}
+
+// IsSet reports if o was set on the command line or by a flags file.
+func (o *Option[T]) IsSet() bool {
+	return o.source != SourceDefault
+}
+
+// Source returns where the value of o came from.
+func (o *Option[T]) Source() Source {
+	return o.source
+}
+
+// Set implements getopt.Value.
+func (o *Option[T]) Set(value string, opt getopt.Option) error {
+	if err := o.value().Set(value, opt); err != nil {
+		return err
+	}
+	o.source = SourceCommandLine
+	return nil
+}
+
+// String implements getopt.Value.
+func (o *Option[T]) String() string {
+	return o.value().String()
+}
+
+// value returns a getopt.Value that sets o.Value.  The value is built on first
+// use and then reused.
+func (o *Option[T]) value() getopt.Value {
+	if o.v == nil || o.vp != &o.Value {
+		o.v = getopt.New().FlagLong(o.valuePointer(), "option", 0).Value()
+		o.vp = &o.Value
+	}
+	return o.v
+}
+
+func (o *Option[T]) valuePointer() interface{} {
+	return optionValue(&o.Value)
+}
+
+func (o *Option[T]) isFlag() bool {
+	return reflect.TypeOf(&o.Value).Elem().Kind() == reflect.Bool
+}
+
+func (o *Option[T]) setSource(s Source) {
+	o.source = s
+}
@@ -0,0 +1,78 @@
+//go:build go1.18
+// +build go1.18
+
+package options
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestOption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags")
+	if err := ioutil.WriteFile(path, []byte("count = 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &struct {
+		Flags   Flags                 `getopt:"--flags=PATH flags file"`
+		Name    Option[string]        `getopt:"--name=NAME name of the widget"`
+		Count   Option[int]           `getopt:"--count=N number of widgets"`
+		Timeout Option[time.Duration] `getopt:"--timeout how long to wait"`
+		Verbose Option[bool]          `getopt:"-v be verbose"`
+	}{
+		Timeout: Option[time.Duration]{Value: time.Minute},
+	}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"test", "--name=bob", "-v", "--flags", path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"name", opts.Name.Get(), "bob"},
+		{"count", opts.Count.Get(), 3},
+		{"timeout", opts.Timeout.Get(), time.Minute},
+		{"verbose", opts.Verbose.Get(), true},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if s := opts.Name.Source(); s != SourceCommandLine {
+		t.Errorf("name: got source %v, want %v", s, SourceCommandLine)
+	}
+	if s := opts.Count.Source(); s != SourceFile {
+		t.Errorf("count: got source %v, want %v", s, SourceFile)
+	}
+	if opts.Timeout.IsSet() {
+		t.Errorf("timeout is set")
+	}
+	if !opts.Verbose.IsSet() {
+		t.Errorf("verbose is not set")
+	}
+
+	// A copy must set its own Value, not the Value of the original.
+	name := opts.Name
+	if err := name.Set("alice", nil); err != nil {
+		t.Fatal(err)
+	}
+	if name.Get() != "alice" || opts.Name.Get() != "bob" {
+		t.Errorf("got %q and %q, want alice and bob", name.Get(), opts.Name.Get())
+	}
+
+	err := RegisterSet("", &struct {
+		Chan Option[chan int] `getopt:"--chan a channel"`
+	}{}, getopt.New())
+	if _, ok := err.(*UnsupportedTypeError); !ok {
+		t.Errorf("got error %v, want an UnsupportedTypeError", err)
+	}
+}
@@ -15,9 +15,14 @@ package options
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/tag"
 )
 
 // A Help option causes PrintUsage to be called if the the option is set.
@@ -30,6 +35,10 @@ import (
 //		Help options.Help `getopt:"--help display command usage"`
 //		...
 //	}{}
+//
+// Options with an advanced tag (see tag.Advanced) are not displayed by Help.
+// Instead a line is added to the usage telling how many options were not
+// displayed and the name of the HelpAll option, if there is one.
 type Help bool
 
 // Set implements getopt.Value.
@@ -37,7 +46,7 @@ func (h *Help) Set(value string, opt getopt.Option) error {
 	if !opt.Seen() {
 		return nil
 	}
-	getopt.PrintUsage(os.Stderr)
+	printBasicUsage(os.Stderr, getopt.CommandLine)
 	if !*h {
 		os.Exit(0)
 	}
@@ -48,3 +57,255 @@ func (h *Help) Set(value string, opt getopt.Option) error {
 func (h *Help) String() string {
 	return fmt.Sprint(bool(*h))
 }
+
+// A HelpAll option is like a Help option but displays all the options,
+// including advanced options.
+//
+//	var myOptions = struct {
+//		Help    options.Help    `getopt:"--help display command usage"`
+//		HelpAll options.HelpAll `getopt:"--help-all display all options"`
+//		Trace   bool            `getopt:"--trace trace requests" advanced:""`
+//		...
+//	}{}
+type HelpAll bool
+
+// Set implements getopt.Value.
+func (h *HelpAll) Set(value string, opt getopt.Option) error {
+	if !opt.Seen() {
+		return nil
+	}
+	printSetUsage(os.Stderr, getopt.CommandLine, false)
+	printExamples(os.Stderr, getopt.CommandLine)
+	if !*h {
+		os.Exit(0)
+	}
+	return nil
+}
+
+// String implements getopt.Value.
+func (h *HelpAll) String() string {
+	return fmt.Sprint(bool(*h))
+}
+
+// advanced is the set of options registered from fields with an advanced tag.
+var advanced = map[getopt.Option]bool{}
+
+// setAdvanced marks opt as an advanced option.
+func setAdvanced(opt getopt.Option) {
+	helpMu.Lock()
+	advanced[opt] = true
+	helpMu.Unlock()
+}
+
+// printBasicUsage writes the usage of set to w without its advanced options.
+func printBasicUsage(w io.Writer, set *getopt.Set) {
+	hidden, all := printSetUsage(w, set, true)
+	printExamples(w, set)
+	switch {
+	case hidden == 0:
+	case all != "":
+		fmt.Fprintf(w, "\n%d advanced options not shown, use %s to display all options.\n", hidden, all)
+	default:
+		fmt.Fprintf(w, "\n%d advanced options not shown.\n", hidden)
+	}
+}
+
+// printSetUsage writes the usage of set to w without its disabled options and,
+// if basic is set, without its advanced options.  It returns the number of
+// advanced options not shown and the name of the HelpAll option of set, if
+// any.
+func printSetUsage(w io.Writer, set *getopt.Set, basic bool) (hidden int, all string) {
+	shown := getopt.New()
+	shown.SetProgram(set.Program())
+	shown.SetParameters(set.Parameters())
+	filtered := false
+	helpMu.Lock()
+	set.VisitAll(func(o getopt.Option) {
+		if _, ok := o.Value().(*HelpAll); ok {
+			all = o.Name()
+		}
+		switch {
+		case isDisabled(o):
+			filtered = true
+		case basic && advanced[o]:
+			hidden++
+			filtered = true
+		default:
+			shown.AddOption(o)
+		}
+	})
+	helpMu.Unlock()
+	if filtered {
+		shown.PrintUsage(w)
+	} else {
+		set.PrintUsage(w)
+	}
+	return hidden, all
+}
+
+var (
+	typeExamples = map[reflect.Type][]string{} // set by SetExamples
+	setExamples  = map[*getopt.Set][]string{}  // registered examples
+)
+
+// SetExamples sets the example arguments displayed in the Examples section of
+// the usage of programs using i, a pointer to an options structure.  Each
+// example is displayed following the program name.  As with SetHelp, the
+// examples apply to all structures of the same type as i and take effect the
+// next time a structure of that type is registered.  An example of a single
+// option can also be provided by its field's example tag.  The examples are
+// displayed by Help, HelpAll, and WriteUsage.
+//
+//	var myOptions = struct {
+//		Name    string        `getopt:"--name=NAME name of the widget" example:"--name=bob"`
+//		Timeout time.Duration `getopt:"--timeout how long to wait"`
+//	}{}
+//
+//	func init() {
+//		options.SetExamples(&myOptions, "--name=bob --timeout=5m", "--timeout=0 FILE")
+//	}
+func SetExamples(i interface{}, examples ...string) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	helpMu.Lock()
+	typeExamples[v.Elem().Type()] = examples
+	helpMu.Unlock()
+	return nil
+}
+
+// lookupExamples returns the examples set by SetExamples for the struct type
+// t.
+func lookupExamples(t reflect.Type) []string {
+	helpMu.Lock()
+	defer helpMu.Unlock()
+	return typeExamples[t]
+}
+
+// addExamples adds examples to the examples displayed in the usage of set.
+func addExamples(set *getopt.Set, examples ...string) {
+	if len(examples) == 0 {
+		return
+	}
+	helpMu.Lock()
+	setExamples[set] = append(setExamples[set], examples...)
+	helpMu.Unlock()
+}
+
+// printExamples writes the examples registered with set to w.
+func printExamples(w io.Writer, set *getopt.Set) {
+	helpMu.Lock()
+	examples := setExamples[set]
+	helpMu.Unlock()
+	writeExamples(w, set.Program(), examples)
+}
+
+// writeExamples writes an Examples section showing program invoked with each
+// of examples to w.  Nothing is written if there are no examples.
+func writeExamples(w io.Writer, program string, examples []string) {
+	if len(examples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nExamples:\n")
+	for _, ex := range examples {
+		fmt.Fprintf(w, "  %s %s\n", program, ex)
+	}
+}
+
+// Help text that is too long to comfortably fit in a struct tag can be provided
+// separately with SetHelp or the helpvar struct tag.
+var (
+	helpMu   sync.Mutex
+	helpText = map[helpKey]string{}
+	helpVars = map[string]string{}
+)
+
+// A helpKey identifies a field in an options structure.
+type helpKey struct {
+	t     reflect.Type
+	field string
+}
+
+// SetHelp sets the help text of the option declared in i by name, overriding
+// the help text provided in the struct tags.  The name may be either the name
+// of the field or the long or short name of the option (without leading dashes).
+// The help text applies to all structures of the same type as i and takes effect
+// the next time a structure of that type is registered.  An error is returned
+// if i is not a pointer to a struct or does not declare name.
+//
+// The help text may span multiple lines.  White space, including newlines, is
+// collapsed into single spaces so the text is wrapped when usage is displayed.
+//
+//	options.SetHelp(&myOptions, "timeout", `
+//	The timeout is the maximum amount of time to wait for the widget
+//	to be ready.  A timeout of 0 waits forever.
+//	`)
+func SetHelp(i interface{}, name, text string) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	t := v.Elem().Type()
+	n := t.NumField()
+	for x := 0; x < n; x++ {
+		field := t.Field(x)
+		if field.Tag.Get("getopt") == "-" || field.PkgPath != "" {
+			continue
+		}
+		o, err := tag.Lookup(field.Tag)
+		if err != nil {
+			return err
+		}
+		o = autoName(o, field.Name)
+		if name == field.Name || name == o.Long || (o.Short != 0 && name == string(o.Short)) {
+			helpMu.Lock()
+			helpText[helpKey{t, field.Name}] = text
+			helpMu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("%T has no option named %q", i, name)
+}
+
+// SetHelpVar sets the help text used by fields with a helpvar struct tag of
+// name.  Since Go cannot look up a variable by name, the variable named by the
+// tag must be provided to SetHelpVar before the structure is registered:
+//
+//	var helpTimeout = `
+//	The timeout is the maximum amount of time to wait for the widget
+//	to be ready.  A timeout of 0 waits forever.
+//	`
+//
+//	var myOptions = struct {
+//		Timeout time.Duration `getopt:"--timeout" helpvar:"helpTimeout"`
+//	}{}
+//
+//	func init() {
+//		options.SetHelpVar("helpTimeout", helpTimeout)
+//	}
+//
+// As with SetHelp, white space in text is collapsed into single spaces.
+func SetHelpVar(name, text string) {
+	helpMu.Lock()
+	helpVars[name] = text
+	helpMu.Unlock()
+}
+
+// lookupHelp returns the help text provided for field of the struct type t by
+// either SetHelp or the helpvar tag.  SetHelp takes precedence.  ok is false if
+// neither provided help text.
+func lookupHelp(t reflect.Type, field reflect.StructField) (text string, ok bool, err error) {
+	helpMu.Lock()
+	defer helpMu.Unlock()
+	if text, ok = helpText[helpKey{t, field.Name}]; !ok {
+		name, has := field.Tag.Lookup("helpvar")
+		if !has {
+			return "", false, nil
+		}
+		if text, ok = helpVars[name]; !ok {
+			return "", false, fmt.Errorf("%s: unknown help variable: %q", field.Name, name)
+		}
+	}
+	return strings.Join(strings.Fields(text), " "), true, nil
+}
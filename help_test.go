@@ -1,7 +1,10 @@
 package options
 
 import (
+	"bytes"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/pborman/getopt/v2"
@@ -20,3 +23,89 @@ func TestHelpType(t *testing.T) {
 		t.Errorf("Got %v want true", v)
 	}
 }
+
+func TestSetHelp(t *testing.T) {
+	type helpOptions struct {
+		Name    string `getopt:"--name=NAME name of the widget"`
+		Count   int    `getopt:"--count -c=COUNT number of widgets"`
+		Timeout int    `getopt:"--timeout" helpvar:"testHelpTimeout"`
+		Unknown int    `getopt:"--unknown" helpvar:"testHelpUnknown"`
+	}
+	opts := &helpOptions{}
+	if err := SetHelp(opts, "name", "the name\n\tof the   widget\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHelp(opts, "c", "how many"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHelp(opts, "Timeout", "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHelp(opts, "missing", "text"); err == nil {
+		t.Errorf("SetHelp did not return an error for a missing option")
+	}
+	if err := SetHelp("a", "a", "text"); err == nil {
+		t.Errorf("SetHelp did not return an error for a string")
+	}
+	SetHelpVar("testHelpTimeout", "the timeout")
+
+	for _, tt := range []struct {
+		field string
+		want  string
+		err   bool
+	}{
+		{field: "Name", want: "the name of the widget"},
+		{field: "Count", want: "how many"},
+		{field: "Timeout", want: "ignored"},
+		{field: "Unknown", err: true},
+	} {
+		field, _ := reflect.TypeOf(opts).Elem().FieldByName(tt.field)
+		got, ok, err := lookupHelp(reflect.TypeOf(*opts), field)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("%s: did not get an error", tt.field)
+		case !tt.err && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.field, err)
+		case !tt.err && (!ok || got != tt.want):
+			t.Errorf("%s: got %q, %v, want %q", tt.field, got, ok, tt.want)
+		}
+	}
+	if _, ok, _ := lookupHelp(reflect.TypeOf(struct{ A int }{}), reflect.StructField{Name: "A"}); ok {
+		t.Errorf("found help for an unrelated type")
+	}
+}
+
+func TestAdvancedHelp(t *testing.T) {
+	opts := &struct {
+		Help    Help    `getopt:"--help display help"`
+		HelpAll HelpAll `getopt:"--help-all display all help"`
+		Name    string  `getopt:"--name=NAME name of the widget"`
+		Trace   bool    `getopt:"--trace trace requests" advanced:""`
+		Debug   int     `getopt:"--debug=LEVEL debug level" advanced:"true"`
+		Quiet   bool    `getopt:"--quiet be quiet" advanced:"false"`
+	}{}
+	set := getopt.New()
+	set.SetProgram("prog")
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	printBasicUsage(&buf, set)
+	got := buf.String()
+	for _, s := range []string{"--name", "--quiet", "--help-all", "2 advanced options not shown, use --help-all"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("basic usage does not contain %q:\n%s", s, got)
+		}
+	}
+	for _, s := range []string{"--trace", "--debug"} {
+		if strings.Contains(got, s) {
+			t.Errorf("basic usage contains %q:\n%s", s, got)
+		}
+	}
+
+	if err := RegisterSet("", &struct {
+		Bad bool `advanced:"sometimes"`
+	}{}, getopt.New()); err == nil {
+		t.Errorf("did not get an error for an invalid advanced tag")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package opterrors defines the registration errors shared by the
+// github.com/pborman/options and github.com/pborman/options/flags packages.
+// Each package re-exports them under its own name.
+package opterrors
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pborman/options/tag"
+)
+
+// Field returns err with the field set to field if err is a *tag.Error,
+// otherwise err is returned prefixed by field.
+func Field(field string, err error) error {
+	var te *tag.Error
+	if errors.As(err, &te) {
+		te.Field = field
+		return te
+	}
+	return fmt.Errorf("%s: %w", field, err)
+}
+
+// An UnsupportedTypeError is returned when a field is of a type that cannot be
+// used as an option.
+type UnsupportedTypeError struct {
+	Field string
+	Type  reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("%s: unsupported option type: %v", e.Field, e.Type)
+}
+
+// A DuplicateOptionError is returned when the option Name is declared by more
+// than one field, or when Name is already defined in the option set.  Other is
+// empty in the latter case.  The options package includes the leading dashes
+// in Name, the flags package does not.  A Name without leading dashes is
+// quoted in the error message.
+type DuplicateOptionError struct {
+	Name  string // The option
+	Field string // The field that redeclared the option
+	Other string // The field that first declared the option, if any
+}
+
+func (e *DuplicateOptionError) Error() string {
+	name := e.Name
+	if !strings.HasPrefix(name, "-") {
+		name = fmt.Sprintf("%q", name)
+	}
+	if e.Other == "" {
+		return fmt.Sprintf("%s: option %s already defined", e.Field, name)
+	}
+	return fmt.Sprintf("option %s declared by both %s and %s", name, e.Other, e.Field)
+}
+
+// Errors is a list of errors.  It is returned when more than one problem is
+// found while registering an options structure.
+type Errors []error
+
+// Error returns the errors in e, one per line.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors in e.
+func (e Errors) Unwrap() []error { return e }
+
+// Err returns nil if e is empty, the only error in e if e has one error, or
+// else e.
+func (e Errors) Err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
@@ -0,0 +1,39 @@
+package opterrors
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	e1, e2 := errors.New("one"), errors.New("two")
+	if err := Errors(nil).Err(); err != nil {
+		t.Errorf("empty Errors returned %v", err)
+	}
+	if err := (Errors{e1}).Err(); err != e1 {
+		t.Errorf("got %v, want %v", err, e1)
+	}
+	err := (Errors{e1, e2}).Err()
+	if got, want := err.Error(), "one\ntwo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !errors.Is(err, e2) {
+		t.Errorf("%v does not wrap %v", err, e2)
+	}
+}
+
+func TestDuplicateOptionError(t *testing.T) {
+	for _, tt := range []struct {
+		err  *DuplicateOptionError
+		want string
+	}{
+		{&DuplicateOptionError{Name: "--name", Field: "B", Other: "A"}, "option --name declared by both A and B"},
+		{&DuplicateOptionError{Name: "--name", Field: "B"}, "B: option --name already defined"},
+		{&DuplicateOptionError{Name: "name", Field: "B", Other: "A"}, `option "name" declared by both A and B`},
+		{&DuplicateOptionError{Name: "name", Field: "B"}, `B: option "name" already defined`},
+	} {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package optvalue parses the option values shared by the
+// github.com/pborman/options and github.com/pborman/options/flags packages.
+// Each package adapts the types here to its own Value interface.
+package optvalue
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseBool returns the boolean value of s.  In addition to the values
+// accepted by strconv.ParseBool, ParseBool accepts y, yes, n, no, on, and off,
+// ignoring case.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "n", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value %q", s)
+}
+
+// SplitKeyValue splits s into a key and value.  ok is false if s does not
+// contain an =.
+func SplitKeyValue(s string) (key, value string, ok bool) {
+	x := strings.Index(s, "=")
+	if x < 0 {
+		return s, "", false
+	}
+	return s[:x], s[x+1:], true
+}
+
+// mapString returns the keys and values of a map, in key order, as a comma
+// separated list of key=value pairs.
+func mapString(keys []string, value func(string) string) string {
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + value(k)
+	}
+	return strings.Join(parts, ",")
+}
+
+// A StringMap adds a single key=value pair to the map each time it is set.
+type StringMap map[string]string
+
+func (m *StringMap) Set(s string) error {
+	k, v, ok := SplitKeyValue(s)
+	if !ok {
+		return fmt.Errorf("%q is not of the form key=value", s)
+	}
+	if *m == nil {
+		*m = StringMap{}
+	}
+	(*m)[k] = v
+	return nil
+}
+
+func (m *StringMap) String() string {
+	keys := make([]string, 0, len(*m))
+	for k := range *m {
+		keys = append(keys, k)
+	}
+	return mapString(keys, func(k string) string { return (*m)[k] })
+}
+
+func (m *StringMap) Get() interface{} { return map[string]string(*m) }
+
+// An IntMap adds a single key=N pair to the map each time it is set.
+type IntMap map[string]int
+
+func (m *IntMap) Set(s string) error {
+	k, v, ok := SplitKeyValue(s)
+	if !ok {
+		return fmt.Errorf("%q is not of the form key=value", s)
+	}
+	n, err := strconv.ParseInt(v, 0, strconv.IntSize)
+	if err != nil {
+		return fmt.Errorf("key %q: invalid integer %q", k, v)
+	}
+	if *m == nil {
+		*m = IntMap{}
+	}
+	(*m)[k] = int(n)
+	return nil
+}
+
+func (m *IntMap) String() string {
+	keys := make([]string, 0, len(*m))
+	for k := range *m {
+		keys = append(keys, k)
+	}
+	return mapString(keys, func(k string) string { return strconv.Itoa((*m)[k]) })
+}
+
+func (m *IntMap) Get() interface{} { return map[string]int(*m) }
+
+// A BoolMap sets a key to true if no value is provided (e.g., --feature=fast).
+type BoolMap map[string]bool
+
+func (m *BoolMap) Set(s string) error {
+	k, v, ok := SplitKeyValue(s)
+	b := true
+	if ok {
+		var err error
+		if b, err = ParseBool(v); err != nil {
+			return fmt.Errorf("key %q: invalid boolean %q", k, v)
+		}
+	}
+	if *m == nil {
+		*m = BoolMap{}
+	}
+	(*m)[k] = b
+	return nil
+}
+
+func (m *BoolMap) String() string {
+	keys := make([]string, 0, len(*m))
+	for k := range *m {
+		keys = append(keys, k)
+	}
+	return mapString(keys, func(k string) string { return strconv.FormatBool((*m)[k]) })
+}
+
+func (m *BoolMap) Get() interface{} { return map[string]bool(*m) }
+
+// unitMultipliers maps the suffixes accepted by the units struct tag to their
+// values.  The single letter suffixes are powers of 1000 for "si" and powers of
+// 1024 for "iec".  The two letter IEC suffixes (e.g., Ki) are always powers of
+// 1024.
+var unitMultipliers = map[string]map[string]uint64{
+	"si": {
+		"k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	},
+	"iec": {
+		"k": 1 << 10, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30,
+		"T": 1 << 40, "P": 1 << 50, "E": 1 << 60,
+	},
+}
+
+var iecMultipliers = map[string]uint64{
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30,
+	"Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+}
+
+// CheckUnits returns an error if units is not a valid value for the units
+// struct tag of a field of type t.  Units may only be used with the builtin
+// integer types.
+func CheckUnits(t reflect.Type, units string) error {
+	if _, ok := unitMultipliers[units]; !ok {
+		return fmt.Errorf("unknown units %q", units)
+	}
+	if t.PkgPath() == "" {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+	}
+	return fmt.Errorf("units do not apply to %v", t)
+}
+
+// parseUnits returns the number in s and its multiplier.  s may end in one of
+// the suffixes of units.
+func parseUnits(s, units string) (string, uint64) {
+	if len(s) > 2 {
+		if m, ok := iecMultipliers[s[len(s)-2:]]; ok {
+			return s[:len(s)-2], m
+		}
+	}
+	if len(s) > 1 {
+		if m, ok := unitMultipliers[units][s[len(s)-1:]]; ok {
+			return s[:len(s)-1], m
+		}
+	}
+	return s, 1
+}
+
+// A Units is an integer, V, that accepts the SI or IEC suffixes declared by
+// the units struct tag, e.g., 10k or 2M.  Set returns a *strconv.NumError if
+// the value is not a number or is out of range for V.
+type Units struct {
+	V     reflect.Value // the integer field
+	Units string        // "si" or "iec"
+}
+
+func (u *Units) Set(value string) error {
+	s, m := parseUnits(value, u.Units)
+	switch u.V.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		if n > math.MaxInt64/int64(m) || n < math.MinInt64/int64(m) || u.V.OverflowInt(n*int64(m)) {
+			return &strconv.NumError{Func: "ParseInt", Num: value, Err: strconv.ErrRange}
+		}
+		u.V.SetInt(n * int64(m))
+	default:
+		n, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		if n > math.MaxUint64/m || u.V.OverflowUint(n*m) {
+			return &strconv.NumError{Func: "ParseUint", Num: value, Err: strconv.ErrRange}
+		}
+		u.V.SetUint(n * m)
+	}
+	return nil
+}
+
+func (u *Units) String() string {
+	if !u.V.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(u.V.Interface())
+}
+
+func (u *Units) Get() interface{} {
+	if !u.V.IsValid() {
+		return nil
+	}
+	return u.V.Interface()
+}
+
+// A Text is an option value that is an encoding.TextUnmarshaler, P, such as a
+// uuid.UUID or a net.IP.  The value is displayed using MarshalText if it is
+// also an encoding.TextMarshaler.
+type Text struct {
+	P encoding.TextUnmarshaler
+}
+
+func (t Text) Set(s string) error {
+	if err := t.P.UnmarshalText([]byte(s)); err != nil {
+		return fmt.Errorf("invalid value %q: %v", s, err)
+	}
+	return nil
+}
+
+func (t Text) String() string {
+	if m, ok := t.P.(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return ""
+}
+
+// Get returns the value t.P points to, e.g., a net.IP.
+func (t Text) Get() interface{} {
+	v := reflect.ValueOf(t.P)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return t.P
+	}
+	return v.Elem().Interface()
+}
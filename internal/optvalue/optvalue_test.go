@@ -0,0 +1,127 @@
+package optvalue
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseBool(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want bool
+		err  bool
+	}{
+		{in: "true", want: true},
+		{in: "YES", want: true},
+		{in: "on", want: true},
+		{in: "0"},
+		{in: "No"},
+		{in: "off"},
+		{in: "maybe", err: true},
+		{in: "", err: true},
+	} {
+		got, err := ParseBool(tt.in)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("ParseBool(%q) did not return an error", tt.in)
+		case !tt.err && err != nil:
+			t.Errorf("ParseBool(%q): %v", tt.in, err)
+		case got != tt.want:
+			t.Errorf("ParseBool(%q) got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	var sm StringMap
+	for _, s := range []string{"b=2", "a=1", "a=x=y"} {
+		if err := sm.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := sm.String(), "a=x=y,b=2"; got != want {
+		t.Errorf("StringMap got %q, want %q", got, want)
+	}
+	if err := sm.Set("novalue"); err == nil {
+		t.Error("StringMap accepted a value without an =")
+	}
+
+	var im IntMap
+	for _, s := range []string{"b=0x10", "a=-1"} {
+		if err := im.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := im.String(), "a=-1,b=16"; got != want {
+		t.Errorf("IntMap got %q, want %q", got, want)
+	}
+	if err := im.Set("a=one"); err == nil {
+		t.Error("IntMap accepted a non-integer")
+	}
+
+	var bm BoolMap
+	for _, s := range []string{"fast", "safe=no"} {
+		if err := bm.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := bm.String(), "fast=true,safe=false"; got != want {
+		t.Errorf("BoolMap got %q, want %q", got, want)
+	}
+	if err := bm.Set("fast=maybe"); err == nil {
+		t.Error("BoolMap accepted an invalid boolean")
+	}
+}
+
+func TestUnits(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		units string
+		want  int32
+		err   error
+	}{
+		{in: "10k", units: "si", want: 10000},
+		{in: "10k", units: "iec", want: 10240},
+		{in: "2Ki", units: "si", want: 2048},
+		{in: "-1M", units: "si", want: -1000000},
+		{in: "3G", units: "si", err: strconv.ErrRange},
+		{in: "ten", units: "si", err: strconv.ErrSyntax},
+	} {
+		var n int32
+		u := &Units{V: reflect.ValueOf(&n).Elem(), Units: tt.units}
+		err := u.Set(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s %s: got error %v, want %v", tt.in, tt.units, err, tt.err)
+			continue
+		}
+		if n != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.in, tt.units, n, tt.want)
+		}
+	}
+	if err := CheckUnits(reflect.TypeOf(""), "si"); err == nil {
+		t.Error("CheckUnits accepted a string")
+	}
+	if err := CheckUnits(reflect.TypeOf(0), "metric"); err == nil {
+		t.Error("CheckUnits accepted unknown units")
+	}
+}
+
+func TestText(t *testing.T) {
+	var ip net.IP
+	v := Text{P: &ip}
+	if err := v.Set("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.String(), "10.0.0.1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, ok := v.Get().(net.IP); !ok || !got.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("Get returned %v", v.Get())
+	}
+	if err := v.Set("bogus"); err == nil {
+		t.Error("Set accepted an invalid address")
+	}
+}
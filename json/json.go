@@ -13,7 +13,8 @@
 
 // Package json provides JSON flag decoding for the github.com/pborman/options
 // packge.  This package registers itself with the options package as the
-// json encoding.  Normal usage is one of:
+// json encoding, and registers TolerantDecoder as the jsonc encoding.  Normal
+// usage is one of:
 //
 //	options.NewFlags("flags").SetEncoding(json.Decoder)
 //
@@ -26,6 +27,13 @@
 //		"v": true,
 //		"n": 42
 //	}
+//
+// An array sets its option once per element, in order, as if the option was
+// repeated on the command line.  This is useful with list options:
+//
+//	{
+//		"include": ["/usr/include", "/usr/local/include"]
+//	}
 package json
 
 import (
@@ -52,6 +60,71 @@ func Decoder(data []byte) (map[string]interface{}, error) {
 	return m, nil
 }
 
+// TolerantDecoder is like Decoder but first removes // and /* */ comments and
+// trailing commas from data, as is permitted by JSONC.  This makes hand
+// maintained flags files easier to write:
+//
+//	{
+//		// The name of the widget
+//		"name": "bob",
+//		"n": 42, /* the number of widgets */
+//	}
+func TolerantDecoder(data []byte) (map[string]interface{}, error) {
+	data, err := strip(data)
+	if err != nil {
+		return nil, err
+	}
+	return Decoder(data)
+}
+
+// strip returns data with comments and trailing commas removed.  Comments and
+// commas within strings are not changed.  Each comment is replaced by a space,
+// or a newline for // comments, so byte offsets in decoding errors remain
+// close.
+func strip(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	inString := false
+	escape := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case escape:
+			escape = false
+		case inString:
+			switch c {
+			case '\\':
+				escape = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			x := bytes.Index(data[i+2:], []byte("*/"))
+			if x < 0 {
+				return nil, fmt.Errorf("JSON decoding error: unterminated comment")
+			}
+			i += x + 3
+			out = append(out, ' ')
+			continue
+		case c == ']' || c == '}':
+			t := bytes.TrimRight(out, " \t\r\n")
+			if len(t) > 0 && t[len(t)-1] == ',' {
+				out = append(t[:len(t)-1], out[len(t):]...)
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
 func init() {
-	options.RegisterEncoding("json", Decoder)
+	options.MustRegisterEncoding("json", Decoder)
+	options.MustRegisterEncoding("jsonc", TolerantDecoder)
 }
@@ -110,3 +110,104 @@ func TestParse(t *testing.T) {
 		t.Errorf("Got child.name %q, want %q", name2, "jim")
 	}
 }
+
+// listValue is a getopt.Value that records each value it is set to.
+type listValue []string
+
+func (l *listValue) Set(value string, _ getopt.Option) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (l *listValue) String() string { return fmt.Sprint(*l) }
+
+func TestParseArray(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	var include listValue
+	getopt.FlagLong(&include, "include", 'I')
+	tmpfile, err := mkFile(`
+{
+    "include": ["/usr/include", "/usr/local/include", 42]
+}
+`)
+	defer os.Remove(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := options.NewFlags("flags")
+	f.SetEncoding(Decoder)
+	if err := f.Set(tmpfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := listValue{"/usr/include", "/usr/local/include", "42"}
+	if !reflect.DeepEqual(include, want) {
+		t.Errorf("Got include %q, want %q", include, want)
+	}
+}
+
+func TestTolerantDecoder(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		out  map[string]interface{}
+		err  string
+	}{
+		{
+			name: "comments",
+			in: `
+			// leading comment
+			{
+				"key": "value", // trailing comment
+				/* block
+				   comment */
+				"n": 42
+			}`,
+			out: map[string]interface{}{
+				"key": "value",
+				"n":   json.Number("42"),
+			},
+		},
+		{
+			name: "trailing commas",
+			in: `
+			{
+				"list": ["a", "b",],
+				"child": {"key": 1, /* comment */ },
+			}`,
+			out: map[string]interface{}{
+				"list":  []interface{}{"a", "b"},
+				"child": map[string]interface{}{"key": json.Number("1")},
+			},
+		},
+		{
+			name: "strings",
+			in:   `{"url": "http://host/path", "s": "/* \"x\", */", "t": ",}"}`,
+			out: map[string]interface{}{
+				"url": "http://host/path",
+				"s":   `/* "x", */`,
+				"t":   ",}",
+			},
+		},
+		{
+			name: "unterminated",
+			in:   `{"key": "value"} /* comment`,
+			err:  "JSON decoding error: unterminated comment",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := TolerantDecoder([]byte(tt.in))
+			switch {
+			case err == nil && tt.err == "":
+			case err == nil:
+				t.Fatalf("did not get expected error %v", tt.err)
+			case err.Error() != tt.err:
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			default:
+				return
+			}
+			if !reflect.DeepEqual(out, tt.out) {
+				t.Errorf("Got:\n%v\nWant:\n%v", out, tt.out)
+			}
+		})
+	}
+}
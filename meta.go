@@ -0,0 +1,220 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A Meta, embedded in an options structure, is filled in when the structure is
+// registered.  It provides access to the set the structure was registered with
+// and the options registered from the structure, so the structure can be
+// inspected without also keeping the set.  The Meta field must be tagged
+// getopt:"-":
+//
+//	type theOptions struct {
+//		options.Meta `getopt:"-"`
+//		Name    string `getopt:"--name=NAME name of the widget"`
+//		Verbose bool   `getopt:"-v be verbose"`
+//	}
+//	...
+//	opts := &theOptions{}
+//	args, err := options.SubRegisterAndParse(opts, args)
+//	...
+//	if opts.Seen("name") {
+//		...
+//	}
+//
+// Register also returns a Meta describing the registration, so structures
+// registered with the standard command-line option set can be inspected
+// without a Meta field.
+//
+// The Meta describes the most recent registration of the structure.  Validate
+// does not change the Meta and Dup does not copy it.  The methods of a Meta of
+// a structure that has not been registered return zero values.
+type Meta struct {
+	m *meta
+}
+
+// meta is the shared state of a Meta.  It is not changed once set.
+type meta struct {
+	i       interface{}              // the registered structure
+	set     *getopt.Set              // the set i was registered with
+	name    string                   // the name i was registered with
+	c       *regConfig               // the configuration i was registered with
+	options []getopt.Option          // the options registered from i
+	byName  map[string]getopt.Option // options by long and short name
+}
+
+var (
+	metaType         = reflect.TypeOf(Meta{})
+	errNotRegistered = errors.New("options structure has not been registered")
+)
+
+// newMeta returns the meta of the structure i registered with set as name
+// using c.  opts are the options registered from i.
+func newMeta(i interface{}, name string, set *getopt.Set, c *regConfig, opts []getopt.Option) *meta {
+	m := &meta{i: i, set: set, name: name, c: c, options: opts, byName: map[string]getopt.Option{}}
+	for _, o := range opts {
+		if n := o.LongName(); n != "" {
+			m.byName[n] = o
+		}
+		if n := o.ShortName(); n != "" {
+			m.byName[n] = o
+		}
+	}
+	return m
+}
+
+// metaOf returns the meta of the Meta field of i, or nil if i does not have a
+// Meta field or has not been registered.
+func metaOf(i interface{}) *meta {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	for x := 0; x < v.NumField(); x++ {
+		if v.Type().Field(x).Type == metaType && v.Field(x).CanInterface() {
+			return v.Field(x).Interface().(Meta).m
+		}
+	}
+	return nil
+}
+
+// Set returns the set the structure was registered with.
+func (m *Meta) Set() *getopt.Set {
+	if m.m == nil {
+		return nil
+	}
+	return m.m.set
+}
+
+// Name returns the name the structure was registered with, see RegisterSet.
+func (m *Meta) Name() string {
+	if m.m == nil {
+		return ""
+	}
+	return m.m.name
+}
+
+// Options returns the options registered from the structure, in field order.
+func (m *Meta) Options() []getopt.Option {
+	if m.m == nil {
+		return nil
+	}
+	return append([]getopt.Option(nil), m.m.options...)
+}
+
+// Option returns the option registered from the structure with the long or
+// short name, without leading dashes, or nil.  Names include any prefix added
+// by RegisterSetPrefixed.
+func (m *Meta) Option(name string) getopt.Option {
+	if m.m == nil {
+		return nil
+	}
+	return m.m.byName[name]
+}
+
+// Seen reports if the option name was seen on the command line.
+func (m *Meta) Seen(name string) bool {
+	o := m.Option(name)
+	return o != nil && o.Seen()
+}
+
+// Default returns the value of the option name when it was registered.
+func (m *Meta) Default(name string) (string, bool) {
+	o := m.Option(name)
+	if o == nil {
+		return "", false
+	}
+	return OptionDefault(o)
+}
+
+// Source returns the source of the value of the option name, see ParseResult.
+func (m *Meta) Source(name string) Source {
+	o := m.Option(name)
+	if o == nil {
+		return SourceDefault
+	}
+	key := o.LongName()
+	if key == "" {
+		key = o.ShortName()
+	}
+	return parseResult(m.m.set).Sources[key]
+}
+
+// Reset resets each option registered from the structure to its default value
+// and marks it as not seen.
+func (m *Meta) Reset() {
+	if m.m == nil {
+		return
+	}
+	for _, o := range m.m.options {
+		o.Reset()
+	}
+}
+
+// SetParameters sets the parameters string displayed in the usage of the set
+// the structure was registered with, see SetParameters.
+func (m *Meta) SetParameters(parameters string) {
+	if m.m != nil {
+		m.m.set.SetParameters(parameters)
+	}
+}
+
+// SetUsage sets the function called to display the usage of the set the
+// structure was registered with when it fails to parse, see SetUsage.
+func (m *Meta) SetUsage(usage func()) {
+	if m.m != nil {
+		m.m.set.SetUsage(usage)
+	}
+}
+
+// PrintUsage writes the usage of the set the structure was registered with to
+// w.
+func (m *Meta) PrintUsage(w io.Writer) {
+	if m.m != nil {
+		m.m.set.PrintUsage(w)
+	}
+}
+
+// Save returns a snapshot of the options in the structure, see Snapshot.
+func (m *Meta) Save() (*State, error) {
+	if m.m == nil {
+		return nil, errNotRegistered
+	}
+	return Snapshot(m.m.i)
+}
+
+// Restore restores the options in the structure from s, see Restore.
+func (m *Meta) Restore(s *State) error {
+	if m.m == nil {
+		return errNotRegistered
+	}
+	return Restore(m.m.i, s)
+}
+
+// WriteConfig writes the effective configuration of the set the structure was
+// registered with to w, see WriteConfig.
+func (m *Meta) WriteConfig(w io.Writer, encoding string) error {
+	if m.m == nil {
+		return errNotRegistered
+	}
+	return WriteConfig(w, m.m.set, encoding)
+}
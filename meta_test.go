@@ -0,0 +1,159 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestMeta(t *testing.T) {
+	type theOptions struct {
+		Meta    `getopt:"-"`
+		Name    string `getopt:"--name=NAME name"`
+		Count   int    `getopt:"--count -c=N count"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}
+	opts := &theOptions{Count: 1}
+	if opts.Set() != nil || opts.Seen("v") || opts.Option("name") != nil {
+		t.Error("unregistered Meta is not empty")
+	}
+	if err := Validate(opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Set() != nil {
+		t.Error("Validate filled in the Meta")
+	}
+	if _, err := SubRegisterAndParse(opts, []string{"cmd", "-v", "-c", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Set() == nil || opts.Meta.Name() != "cmd" {
+		t.Fatalf("got set %v named %q, want a set named cmd", opts.Set(), opts.Meta.Name())
+	}
+	if got := len(opts.Options()); got != 3 {
+		t.Errorf("got %d options, want 3", got)
+	}
+	if !opts.Seen("v") || !opts.Seen("count") || opts.Seen("name") {
+		t.Errorf("got seen %v, %v, %v, want true, true, false", opts.Seen("v"), opts.Seen("count"), opts.Seen("name"))
+	}
+	if opts.Source("c") != SourceCommandLine || opts.Source("name") != SourceDefault {
+		t.Errorf("got sources %v and %v", opts.Source("c"), opts.Source("name"))
+	}
+	if def, ok := opts.Default("count"); !ok || def != "1" {
+		t.Errorf("got default %q, %v, want 1, true", def, ok)
+	}
+
+	state, err := opts.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Reset()
+	if opts.Count != 1 || opts.Verbose || opts.Seen("v") {
+		t.Errorf("after Reset got %d, %v, seen %v", opts.Count, opts.Verbose, opts.Seen("v"))
+	}
+	if err := opts.Restore(state); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Count != 3 || !opts.Verbose {
+		t.Errorf("after Restore got %d, %v, want 3, true", opts.Count, opts.Verbose)
+	}
+	var buf bytes.Buffer
+	if err := opts.WriteConfig(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("count = 3")) {
+		t.Errorf("configuration does not include count:\n%s", buf.String())
+	}
+
+	d := Dup(opts).(*theOptions)
+	if d.Set() != nil {
+		t.Error("Dup copied the Meta")
+	}
+
+	if err := Validate(&struct {
+		Meta
+	}{}); err == nil {
+		t.Error("did not get an error for an untagged Meta")
+	}
+}
+
+func TestReRegister(t *testing.T) {
+	path, err := mkFile("[cmd]\nthe-name = file\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	type theOptions struct {
+		Meta    `getopt:"-"`
+		Flags   Flags  `getopt:"--flags=PATH flags file"`
+		Name    string `getopt:"--name=NAME name"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}
+	other := getopt.New()
+	opts := &theOptions{}
+	opts.Flags.Sets = append(opts.Flags.Sets, Set{Name: "other", Set: other})
+	set := getopt.New()
+	if err := RegisterSet("cmd", opts, set, WithRename(map[string]string{"name": "the-name"})); err != nil {
+		t.Fatal(err)
+	}
+	SetInterspersed(set, true)
+
+	vd, dset := ReRegister(opts)
+	d := vd.(*theOptions)
+	if dset == set || d.Set() != dset || d.Meta.Name() != "cmd" {
+		t.Fatalf("duplicate is registered with %v named %q", d.Set(), d.Meta.Name())
+	}
+	if len(d.Flags.Sets) != 2 || d.Flags.Sets[0].Set != other || d.Flags.Sets[1].Set != dset {
+		t.Errorf("duplicate Flags has sets %v", d.Flags.Sets)
+	}
+	if _, err := SubParse(dset, []string{"cmd", "a", "-v", "--flags", path}); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Verbose || d.Name != "file" || opts.Verbose || opts.Name != "" {
+		t.Errorf("got %v, %q and %v, %q, want true, file and false, empty", d.Verbose, d.Name, opts.Verbose, opts.Name)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("ReRegister of an unregistered structure did not panic")
+		}
+	}()
+	ReRegister(&theOptions{})
+}
+
+func TestRegisterMeta(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	opts := &struct {
+		Name    string `getopt:"--name=NAME name"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}{}
+	m := Register(opts)
+	if m.Set() != getopt.CommandLine {
+		t.Fatalf("got set %v, want CommandLine", m.Set())
+	}
+	if err := getopt.CommandLine.Getopt([]string{"cmd", "-v"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Seen("v") || m.Seen("name") || len(m.Options()) != 2 {
+		t.Errorf("got seen %v, %v and %d options", m.Seen("v"), m.Seen("name"), len(m.Options()))
+	}
+	m.SetParameters("FILE ...")
+	var buf bytes.Buffer
+	m.PrintUsage(&buf)
+	if !bytes.Contains(buf.Bytes(), []byte("FILE ...")) {
+		t.Errorf("usage does not include the parameters:\n%s", buf.String())
+	}
+}
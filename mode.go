@@ -0,0 +1,331 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A parseMode is a set of flags that change how the command line is parsed.
+type parseMode uint
+
+const (
+	modeInterspersed  parseMode = 1 << iota // see SetInterspersed
+	modeAbbreviations                       // see SetAbbreviations
+	modeSingleDash                          // see SetSingleDash
+)
+
+// A modeChange is the modes turned on and off by a SetType function.
+type modeChange struct {
+	on, off parseMode
+}
+
+var (
+	modesMu   sync.Mutex
+	setModes  = map[*getopt.Set]parseMode{}
+	typeModes = map[reflect.Type]modeChange{}
+)
+
+// setMode turns m on or off for set.
+func setMode(set *getopt.Set, m parseMode, on bool) {
+	modesMu.Lock()
+	if on {
+		setModes[set] |= m
+	} else {
+		setModes[set] &^= m
+	}
+	modesMu.Unlock()
+}
+
+// setTypeMode turns m on or off for the sets that structures of the same type
+// as i are registered with.
+func setTypeMode(i interface{}, m parseMode, on bool) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	t := v.Elem().Type()
+	modesMu.Lock()
+	c := typeModes[t]
+	if on {
+		c.on, c.off = c.on|m, c.off&^m
+	} else {
+		c.on, c.off = c.on&^m, c.off|m
+	}
+	typeModes[t] = c
+	modesMu.Unlock()
+	return nil
+}
+
+// applyModes applies the modes set for the struct type t, if any, to set.
+func applyModes(set *getopt.Set, t reflect.Type) {
+	modesMu.Lock()
+	defer modesMu.Unlock()
+	if c, ok := typeModes[t]; ok {
+		setModes[set] = setModes[set]&^c.off | c.on
+	}
+}
+
+// modes returns the modes of set.
+func modes(set *getopt.Set) parseMode {
+	modesMu.Lock()
+	defer modesMu.Unlock()
+	return setModes[set]
+}
+
+// SetInterspersed sets whether options in set may be interspersed with its
+// positional arguments.  By default parsing follows POSIX and stops at the
+// first argument that is not an option, so in
+//
+//	prog -v file -n 3
+//
+// the arguments are file, -n, and 3.  When on is true parsing continues past
+// positional arguments and the arguments are just file.  Parsing always stops
+// at "--".  Leave set POSIX ordered for wrapper commands that pass the
+// arguments following a command on to it.
+//
+// SetInterspersed, and the other parsing modes, affect Parse,
+// RegisterAndParse, RegisterAndParseArgs, SubParse, and SubParseContext.  Use
+// SetTypeInterspersed for sets created by SubRegisterAndParse and ParseArgs.
+func SetInterspersed(set *getopt.Set, on bool) {
+	setMode(set, modeInterspersed, on)
+}
+
+// SetTypeInterspersed is like SetInterspersed but applies to each set that a
+// structure of the same type as i, a pointer to a struct, is registered with
+// after SetTypeInterspersed is called.
+//
+//	options.SetTypeInterspersed(&myOptions, true)
+//	args, err := options.SubRegisterAndParse(&myOptions, args)
+func SetTypeInterspersed(i interface{}, on bool) error {
+	return setTypeMode(i, modeInterspersed, on)
+}
+
+// SetAbbreviations sets whether a long option in set may be abbreviated to any
+// prefix of its name that is not the prefix of another long option in set, as
+// with GNU getopt_long.  For example, --time is accepted for --timeout unless
+// set also has a --timestamp option, in which case an AmbiguousOptionError is
+// returned.  A name that exactly matches a long option is never ambiguous.
+// Abbreviations are not accepted by default.
+func SetAbbreviations(set *getopt.Set, on bool) {
+	setMode(set, modeAbbreviations, on)
+}
+
+// SetTypeAbbreviations is like SetAbbreviations but applies to each set that a
+// structure of the same type as i, a pointer to a struct, is registered with
+// after SetTypeAbbreviations is called.
+func SetTypeAbbreviations(i interface{}, on bool) error {
+	return setTypeMode(i, modeAbbreviations, on)
+}
+
+// SetSingleDash sets whether a long option in set may be given with a single
+// dash, as with the standard flag package.  For example,
+//
+//	prog -timeout 5s -name=bob
+//
+// is parsed as --timeout 5s --name=bob.  This eases moving programs from the
+// flag package without breaking scripts that call them.  An argument is only
+// treated as a long option if the name following the dash, up to any =, is
+// exactly the name of a long option in set.  Otherwise it is parsed as a group
+// of short options, so -vx remains -v -x unless set has a --vx option.
+// Single dash long options are not accepted by default.
+func SetSingleDash(set *getopt.Set, on bool) {
+	setMode(set, modeSingleDash, on)
+}
+
+// SetTypeSingleDash is like SetSingleDash but applies to each set that a
+// structure of the same type as i, a pointer to a struct, is registered with
+// after SetTypeSingleDash is called.
+func SetTypeSingleDash(i interface{}, on bool) error {
+	return setTypeMode(i, modeSingleDash, on)
+}
+
+// getoptArgs calls set.Getopt with args, as modified by the modes of set.  If
+// set is interspersed then parsing resumes after each positional argument and
+// set.Args returns all of them.  The positional arguments are also split at
+// the first "--", see splitDash.
+func getoptArgs(set *getopt.Set, args []string) (err error) {
+	clearDeprecated(set)
+	defer func() {
+		if err == nil {
+			err = checkDisabled(set)
+		}
+	}()
+	m := modes(set)
+	if m&(modeAbbreviations|modeSingleDash) != 0 {
+		if args, err = rewriteArgs(set, m, args); err != nil {
+			return err
+		}
+	}
+	if m&modeInterspersed == 0 || len(args) == 0 {
+		if err := set.Getopt(args, nil); err != nil {
+			return err
+		}
+		rest := set.Args()
+		if set.State() == getopt.DashDash {
+			setDashSplit(set, []string{}, rest, true)
+			return nil
+		}
+		for i, arg := range rest {
+			if arg == "--" {
+				setDashSplit(set, rest[:i], rest[i+1:], true)
+				return nil
+			}
+		}
+		setDashSplit(set, nil, nil, false)
+		return nil
+	}
+	params := []string{}
+	var dashed []string
+	for {
+		if err := set.Getopt(args, nil); err != nil {
+			return err
+		}
+		rest := set.Args()
+		if set.State() == getopt.DashDash {
+			dashed = rest
+			break
+		}
+		if set.State() != getopt.EndOfOptions || len(rest) == 0 {
+			params = append(params, rest...)
+			break
+		}
+		params = append(params, rest[0])
+		args = append([]string{args[0]}, rest[1:]...)
+	}
+	// Parse the positional arguments alone so set.Args returns them.
+	all := append(append([]string{args[0], "--"}, params...), dashed...)
+	if err := set.Getopt(all, nil); err != nil {
+		return err
+	}
+	setDashSplit(set, params, dashed, dashed != nil)
+	return nil
+}
+
+// rewriteArgs returns a copy of args with each abbreviated long option
+// replaced by its full name and, in single dash mode, each long option given
+// with a single dash given with two.  Parameters of options are not
+// rewritten.
+func rewriteArgs(set *getopt.Set, m parseMode, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	longs := map[string]getopt.Option{}
+	set.VisitAll(func(o getopt.Option) {
+		if name := o.LongName(); name != "" {
+			longs[name] = o
+		}
+	})
+	args = append([]string(nil), args...)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return args, nil
+		case strings.HasPrefix(arg, "--"):
+			name, value := splitParam(arg[2:])
+			o, full, err := lookupLong(longs, name, m&modeAbbreviations != 0)
+			if o == nil {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			args[i] = "--" + full + value
+			if value == "" && takesParam(o) {
+				i++
+			}
+		case len(arg) > 1 && arg[0] == '-':
+			if m&modeSingleDash != 0 {
+				name, value := splitParam(arg[1:])
+				if o := longs[name]; o != nil && utf8.RuneCountInString(name) > 1 {
+					args[i] = "-" + arg
+					if value == "" && takesParam(o) {
+						i++
+					}
+					continue
+				}
+			}
+			// Skip the parameter of the last short option in arg.
+			for x, r := range arg[1:] {
+				o := lookup(set, r)
+				if o == nil {
+					break
+				}
+				if takesParam(o) {
+					if x+1+utf8.RuneLen(r) == len(arg) {
+						i++
+					}
+					break
+				}
+			}
+		case m&modeInterspersed == 0:
+			return args, nil
+		}
+	}
+	return args, nil
+}
+
+// splitParam splits arg, an option without its leading dashes, into its name
+// and its attached parameter, if any, including the =.
+func splitParam(arg string) (name, param string) {
+	if x := strings.Index(arg, "="); x >= 0 {
+		return arg[:x], arg[x:]
+	}
+	return arg, ""
+}
+
+// lookupLong returns the option in longs named name along with its full name.
+// If abbrev is true and name is not in longs then the option is the only one
+// whose long name starts with name.  An AmbiguousOptionError is returned if
+// name is the prefix of more than one name.
+func lookupLong(longs map[string]getopt.Option, name string, abbrev bool) (getopt.Option, string, error) {
+	if o, ok := longs[name]; ok || name == "" || !abbrev {
+		return o, name, nil
+	}
+	var names []string
+	for long := range longs {
+		if strings.HasPrefix(long, name) {
+			names = append(names, long)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return nil, "", nil
+	case 1:
+		return longs[names[0]], names[0], nil
+	}
+	sort.Strings(names)
+	for i, n := range names {
+		names[i] = "--" + n
+	}
+	return nil, "", &AmbiguousOptionError{Name: "--" + name, Candidates: names}
+}
+
+// takesParam reports if o requires a parameter.  Options with an optional
+// parameter must have their parameter attached.
+func takesParam(o getopt.Option) bool {
+	if o.IsFlag() {
+		return false
+	}
+	_, optional := o.Value().(*implicitValue)
+	return !optional
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestInterspersed(t *testing.T) {
+	args := []string{"cmd", "-v", "a", "--name=bob", "b", "--", "-c"}
+	for _, tt := range []struct {
+		on   bool
+		name string
+		want []string
+	}{
+		{on: false, want: []string{"a", "--name=bob", "b", "--", "-c"}},
+		{on: true, name: "bob", want: []string{"a", "b", "-c"}},
+	} {
+		opts := &struct {
+			Verbose bool   `getopt:"-v be verbose"`
+			Name    string `getopt:"--name=NAME name"`
+		}{}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		SetInterspersed(set, tt.on)
+		r, err := SubParse(set, args)
+		if err != nil {
+			t.Errorf("%v: %v", tt.on, err)
+			continue
+		}
+		if !opts.Verbose || opts.Name != tt.name {
+			t.Errorf("%v: got %v, %q, want true, %q", tt.on, opts.Verbose, opts.Name, tt.name)
+		}
+		if !reflect.DeepEqual(r.Args, tt.want) {
+			t.Errorf("%v: got args %q, want %q", tt.on, r.Args, tt.want)
+		}
+	}
+}
+
+func TestSetTypeInterspersed(t *testing.T) {
+	type subOptions struct {
+		Verbose bool `getopt:"-v be verbose"`
+	}
+	if err := SetTypeInterspersed(&subOptions{}, true); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTypeInterspersed(&subOptions{}, false)
+	var opts subOptions
+	args, err := SubRegisterAndParse(&opts, []string{"sub", "a", "-v", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !opts.Verbose || !reflect.DeepEqual(args, want) {
+		t.Errorf("got %v, %q, want true, %q", opts.Verbose, args, want)
+	}
+	if err := SetTypeInterspersed(opts, true); err == nil {
+		t.Error("SetTypeInterspersed accepted a struct")
+	}
+}
+
+func TestAbbreviations(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		timeout string
+		name    string
+		rest    []string
+		err     string
+	}{
+		{args: []string{"cmd", "--timeout=1s"}, timeout: "1s"},
+		{args: []string{"cmd", "--timeo=1s"}, timeout: "1s"},
+		{args: []string{"cmd", "--timeo", "1s", "--na", "--timeo"}, timeout: "1s", name: "--timeo"},
+		{args: []string{"cmd", "-n", "--timeo", "--timeo=1s"}, timeout: "1s", name: "--timeo"},
+		{args: []string{"cmd", "-n--timeo", "--timeo=1s"}, timeout: "1s", name: "--timeo"},
+		{args: []string{"cmd", "a", "--timeo=1s"}, rest: []string{"a", "--timeo=1s"}},
+		{args: []string{"cmd", "--", "--timeo=1s"}, rest: []string{"--timeo=1s"}},
+		{args: []string{"cmd", "--time=1s"}, err: "ambiguous option --time, candidates: --timeout, --timestamp"},
+		{args: []string{"cmd", "--t=1s"}, err: "ambiguous option --t, candidates: --timeout, --timestamp"},
+	} {
+		opts := &struct {
+			Timeout   string `getopt:"--timeout=DURATION timeout"`
+			Timestamp bool   `getopt:"--timestamp include timestamps"`
+			Name      string `getopt:"--name -n=NAME name"`
+		}{}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		SetAbbreviations(set, true)
+		r, err := SubParse(set, tt.args)
+		if tt.err != "" {
+			var ae *AmbiguousOptionError
+			if !errors.As(err, &ae) || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %s", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if opts.Timeout != tt.timeout || opts.Name != tt.name {
+			t.Errorf("%q: got %q, %q, want %q, %q", tt.args, opts.Timeout, opts.Name, tt.timeout, tt.name)
+		}
+		if len(r.Args) != 0 || len(tt.rest) != 0 {
+			if !reflect.DeepEqual(r.Args, tt.rest) {
+				t.Errorf("%q: got args %q, want %q", tt.args, r.Args, tt.rest)
+			}
+		}
+	}
+
+	set := getopt.New()
+	set.FlagLong(new(string), "timeout", 0)
+	if _, err := SubParse(set, []string{"cmd", "--timeo=1s"}); err == nil {
+		t.Error("abbreviation accepted by default")
+	}
+	SetAbbreviations(set, true)
+	if _, err := SubParse(set, []string{"cmd", "-x", "--timeo=1s"}); err == nil {
+		t.Error("unknown option -x accepted")
+	}
+}
+
+func TestSingleDash(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		timeout string
+		verbose bool
+		x       bool
+		rest    []string
+	}{
+		{args: []string{"cmd", "-timeout", "5s"}, timeout: "5s"},
+		{args: []string{"cmd", "-timeout=5s", "-vx"}, timeout: "5s", verbose: true, x: true},
+		{args: []string{"cmd", "--timeout=5s", "-verbose"}, timeout: "5s", verbose: true},
+		{args: []string{"cmd", "-timeout", "-verbose"}, timeout: "-verbose"},
+		{args: []string{"cmd", "a", "-timeout=5s"}, rest: []string{"a", "-timeout=5s"}},
+	} {
+		opts := &struct {
+			Timeout string `getopt:"--timeout=DURATION timeout"`
+			Verbose bool   `getopt:"--verbose -v be verbose"`
+			X       bool   `getopt:"-x extra"`
+		}{}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		SetSingleDash(set, true)
+		r, err := SubParse(set, tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if opts.Timeout != tt.timeout || opts.Verbose != tt.verbose || opts.X != tt.x {
+			t.Errorf("%q: got %q, %v, %v, want %q, %v, %v", tt.args, opts.Timeout, opts.Verbose, opts.X, tt.timeout, tt.verbose, tt.x)
+		}
+		if len(r.Args) != 0 || len(tt.rest) != 0 {
+			if !reflect.DeepEqual(r.Args, tt.rest) {
+				t.Errorf("%q: got args %q, want %q", tt.args, r.Args, tt.rest)
+			}
+		}
+	}
+}
@@ -58,12 +58,65 @@
 //	Name string -> "--name unspecified"
 //	N int       -> "-n unspecified"
 //
+// The generated name is the lower case field name.  It is a short option if the
+// field name is a single letter and a long option otherwise.  The flags
+// subpackage generates names the same way.
+//
+// The pieces of a tag may instead be declared using the long, short, param, and
+// help struct tags.  This is easier to read when the description is long:
+//
+//	Name string `long:"name" short:"n" param:"NAME" help:"name of the widget"`
+//
+// These may be combined with a getopt tag as long as no piece of information is
+// declared twice.  Names are declared without leading dashes.
+//
+// Following an option name with a ? declares its parameter to be optional, as
+// with GNU style --color[=WHEN] options.  The implicit struct tag provides the
+// value used when the parameter is omitted.  An optional parameter must be
+// attached to the option (e.g., --color=always or -calways).
+//
+//	Color string `getopt:"--color?=WHEN colorize output" implicit:"auto"`
+//
+// Help text that is too long to fit comfortably in a struct tag can be provided
+// with SetHelp or by naming a variable with the helpvar struct tag (see
+// SetHelpVar).
+//
 // # Types
 //
 // The fields of the structure can be any type that can be passed to getopt.Flag
 // as a pointer (e.g., string, []string, int, bool, time.Duration, etc).  This
 // includes any type that implements getopt.Value.
 //
+// Boolean values, whether on the command line or in a flags file, may be any of
+// true, false, t, f, yes, no, y, n, on, off, 1, or 0, ignoring case.
+//
+// Fields whose pointer implements encoding.TextUnmarshaler, such as uuid.UUID
+// and net.IP, are set by calling UnmarshalText.
+//
+// Integer options accept Go style literals, such as 0x1f, 0o755, and 0b101.  The
+// FileMode type is set and displayed in octal and is useful for permissions.
+//
+// The units struct tag allows an integer option to be given with a suffix.
+// With units:"si" the suffixes k, M, G, T, P, and E are powers of 1000.  With
+// units:"iec" they are powers of 1024.  The suffixes Ki, Mi, Gi, Ti, Pi, and Ei
+// are always powers of 1024.
+//
+//	MaxEvents int `getopt:"--max-events=N stop after N events" units:"si"`
+//
+// Fields may also be of type map[string]string, map[string]int, or
+// map[string]bool.  Each use of the option adds a key=value pair to the map,
+// e.g., --limit cpu=4 --limit mem=2048.  The value may be omitted for a
+// map[string]bool, in which case it is true.
+//
+// A []string field tagged getopt:"-" with an args tag is not an option.  It
+// receives the arguments remaining after parsing and the args tag sets how many
+// there must be, see SetArgs.
+//
+//	Files []string `getopt:"-" args:"1-2"`
+//
+// Similarly, a []string field tagged getopt:"-" with a dashdash tag receives
+// the arguments following "--".
+//
 // # Example Structure
 //
 // The following structure declares 7 options and sets the default value of
@@ -119,27 +172,45 @@
 package options
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/internal/optvalue"
+	"github.com/pborman/options/tag"
 )
 
 // Dup returns a shallow duplicate of i or panics.  Dup panics if i is not a
 // pointer to struct or has an invalid getopt tag.  Dup does not copy
-// non-exported fields or fields whose getopt tag is "-".
+// non-exported fields or fields whose getopt tag is "-".  Fields that are maps
+// are copied so the duplicate's options do not modify i.  A Flags field is
+// copied without the sets i was registered with, see Flags.
 //
 // Dup is normally used to create a unique instance of the set of options so i
 // can be used multiple times.
 func Dup(i interface{}) interface{} {
+	d, err := dup(i)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// dup is Dup but returns an error rather than panicking.
+func dup(i interface{}) (interface{}, error) {
 	v := reflect.ValueOf(i)
 	if v.Kind() != reflect.Ptr {
-		panic(fmt.Errorf("%T is not a pointer to a struct", i))
+		return nil, fmt.Errorf("%T is not a pointer to a struct", i)
 	}
 	v = v.Elem()
 	if v.Kind() != reflect.Struct {
-		panic(fmt.Errorf("%T is not a pointer to a struct", i))
+		return nil, fmt.Errorf("%T is not a pointer to a struct", i)
 	}
 	t := v.Type()
 	newi := reflect.New(t) // Same type as i
@@ -150,34 +221,159 @@ func Dup(i interface{}) interface{} {
 	for i := 0; i < n; i++ {
 		field := t.Field(i)
 		fv := newi.Field(i)
-		tag := field.Tag.Get("getopt")
-		if tag == "-" || !fv.CanSet() {
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
 			continue
 		}
-		_, err := parseTag(tag)
+		_, err := tag.Lookup(field.Tag)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		// Copy the value over
+		if f, ok := v.Field(i).Addr().Interface().(*Flags); ok {
+			fv.Set(reflect.ValueOf(f.clone()))
+			continue
+		}
 		fv.Set(v.Field(i))
+		// Maps are copied so setting an option in the duplicate does
+		// not modify i.
+		if fv.Kind() == reflect.Map && !fv.IsNil() {
+			m := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+			fv.Set(m)
+		}
+	}
+	return ret, nil
+}
+
+// DupWith returns a duplicate of i, as returned by Dup, with the options named
+// by the keys of overrides set to their values.  The values are applied as if
+// they were read from a flags file: they are converted to strings, and slices
+// set the option once per element, and then passed to the option's Set method,
+// so they are parsed and validated as they would be on the command line.  The
+// keys are the long or short names of the options without leading dashes.
+// DupWith returns an error if i is not a valid options structure, a key does
+// not name an option, or a value is invalid.
+//
+//	tenant, err := options.DupWith(&defaults, map[string]interface{}{
+//		"name":    "tenant1",
+//		"timeout": "5s",
+//	})
+func DupWith(i interface{}, overrides map[string]interface{}) (interface{}, error) {
+	if err := Validate(i); err != nil {
+		return nil, err
+	}
+	d := Dup(i)
+	set := getopt.New()
+	defer releaseSet(set)
+	if err := RegisterSet("", d, set); err != nil {
+		return nil, err
+	}
+	m := mergemap(nil, overrides)
+	var errs Errors
+	set.VisitAll(func(o getopt.Option) {
+		v, ok := takeValue(m, o)
+		if !ok {
+			return
+		}
+		ss, err := flagStrings(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.Name(), err))
+			return
+		}
+		if err := setValues(o, ss); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.Name(), err))
+		}
+	})
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("%T has no option named %q", i, name))
 	}
-	return ret
+	if len(errs) > 0 {
+		return nil, errs.Err()
+	}
+	return d, nil
 }
 
-// Register registers the fields in i with the standard command-line option set.
-// It panics for the same reasons that RegisterSet panics.
-func Register(i interface{}) {
-	if err := register("", i, getopt.CommandLine); err != nil {
+// Register registers the fields in i with the standard command-line option set
+// and returns a Meta describing the registration.  It panics for the same
+// reasons that RegisterSet panics.  The Meta provides the registered options,
+// and controls the usage, without a Meta field in i:
+//
+//	m := options.Register(&myOptions)
+//	options.Parse()
+//	if m.Seen("verbose") {
+//		...
+//	}
+func Register(i interface{}) *Meta {
+	m := &Meta{}
+	if err := register("", i, getopt.CommandLine, &regConfig{handle: m}); err != nil {
 		panic(err)
 	}
+	return m
 }
 
 // RegisterAndParse and calls Register(i), getopt.Parse(), and returns
-// getopt.Args().
+// getopt.Args().  If the number of arguments is not permitted by SetArgs, or
+// an args tag in i, the error and the usage are written to standard error and
+// the program exits.
 func RegisterAndParse(i interface{}) []string {
 	Register(i)
-	getopt.Parse()
-	return getopt.Args()
+	return Parse()
+}
+
+// RegisterAndParseArgs is similar to RegisterAndParse except it is provided the
+// arguments as args and errors are returned rather than causing a panic or
+// writing to standard error and exiting the program.  Unlike
+// SubRegisterAndParse, i is registered with the standard command-line option
+// set, so options registered by NewFlags or other calls to Register are also
+// parsed.  This makes RegisterAndParseArgs useful in tests and servers.
+//
+// As with getopt.Getopt, the first element of args is the program name and is
+// not parsed.
+func RegisterAndParseArgs(i interface{}, args []string) ([]string, error) {
+	if err := register("", i, getopt.CommandLine, nil); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if err := ParseCommandLine(args); err != nil {
+		return nil, err
+	}
+	return getopt.CommandLine.Args(), nil
+}
+
+// ParseCommandLine is like Parse but parses args rather than os.Args and
+// returns an error rather than writing it to standard error and exiting the
+// program.  The options must already be registered with the standard
+// command-line option set, for example by Register.  This lets a test exercise
+// the same wiring as main without changing os.Args:
+//
+//	options.Register(&opts)
+//	if err := options.ParseCommandLine([]string{"prog", "--name=bob"}); err != nil {
+//		...
+//	}
+//	args := getopt.Args()
+//
+// As with getopt.Getopt, the first element of args is the program name and is
+// not parsed.  ParseArgs registers a structure with a new option set, while
+// ParseCommandLine parses the options in the standard command-line option set.
+func ParseCommandLine(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	autoDisplayWidth()
+	if err := getoptArgs(getopt.CommandLine, args); err != nil {
+		return err
+	}
+	return finishParse(getopt.CommandLine)
 }
 
 // SubRegisterAndParse is similar to RegisterAndParse except it is provided the
@@ -217,26 +413,48 @@ func SubRegisterAndParse(i interface{}, args []string) ([]string, error) {
 	if err := RegisterSet(args[0], i, set); err != nil {
 		return nil, err
 	}
-	if err := set.Getopt(args, nil); err != nil {
+	forgetOwners(set)
+	autoDisplayWidth()
+	if err := getoptArgs(set, args); err != nil {
+		return nil, err
+	}
+	if err := finishParse(set); err != nil {
 		return nil, err
 	}
 	return set.Args(), nil
 }
 
-// Parse calls getopt.Parse and returns getopt.Args().
+// Parse calls getopt.Parse and returns getopt.Args().  As with getopt.Parse,
+// errors, including an ArgsError, are written to standard error along with the
+// usage and the program exits.  Uses of deprecated aliases (see SetAlias) are
+// written to standard error as warnings.
 func Parse() []string {
-	getopt.Parse()
+	autoDisplayWidth()
+	err := getoptArgs(getopt.CommandLine, os.Args)
+	if err == nil {
+		err = finishParse(getopt.CommandLine)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+	for _, w := range deprecatedUses(getopt.CommandLine) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
 	return getopt.Args()
 }
 
-// Validate validates i as a set of options or returns an error.
+// Validate validates i as a set of options or returns an error.  If more than
+// one problem is found then an Errors is returned describing all of them.
 //
 // Use Validate to assure that a later call to one of the Register functions
 // will not panic.  Validate is typically called by an init function on
 // structures that will be registered later.
 func Validate(i interface{}) error {
 	set := getopt.New()
-	return register("", i, set)
+	defer releaseSet(set)
+	return register("", i, set, &regConfig{validate: true})
 }
 
 // RegisterNew creates a new getopt Set, duplicates i, calls RegisterSet, and
@@ -245,27 +463,291 @@ func Validate(i interface{}) error {
 func RegisterNew(name string, i interface{}) (interface{}, *getopt.Set) {
 	set := getopt.New()
 	i = Dup(i)
-	if err := register(name, i, set); err != nil {
+	if err := register(name, i, set, nil); err != nil {
 		panic(err)
 	}
 	return i, set
 }
 
+// ReRegister is like RegisterNew but i must be a registered structure with a
+// Meta field (see Meta).  i is duplicated and the duplicate is registered with
+// a new set in the same way as i was most recently registered: with the same
+// name, prefix, filters, and parsing modes.  Each Flags in the duplicate reads
+// into the new set in place of the set i was registered with and continues to
+// read into any other sets the Flags in i was linked to, such as those
+// attached by NewSet.  ReRegister panics if i has not been registered or if
+// the duplicate cannot be registered.
+//
+//	opts := &theOptions{}
+//	options.Register(opts)
+//	...
+//	vopts, set := options.ReRegister(opts)
+//	args, err := options.SubParse(set, args)
+func ReRegister(i interface{}) (interface{}, *getopt.Set) {
+	m := metaOf(i)
+	if m == nil {
+		panic(fmt.Errorf("%T: %w", i, errNotRegistered))
+	}
+	d, err := dup(i)
+	if err != nil {
+		panic(err)
+	}
+	set := getopt.New()
+	modesMu.Lock()
+	setModes[set] = setModes[m.set]
+	modesMu.Unlock()
+	if err := register(m.name, d, set, m.c.clone()); err != nil {
+		panic(err)
+	}
+	return d, set
+}
+
 // RegisterSet registers the fields in i, to the getopt Set set.  RegisterSet
 // returns an error if i is not a pointer to struct, has an invalid getopt tag,
 // or contains a field of an unsupported option type.  RegisterSet ignores
-// non-exported fields or fields whose getopt tag is "-".
+// non-exported fields or fields whose getopt tag is "-".  No options are
+// registered if an error is returned.  If more than one field has a problem
+// then an Errors is returned.
+//
+// RegisterSet may be called more than once with the same set.  A
+// DuplicateOptionError naming both fields is returned if i declares an option
+// that is already registered in set.
 //
 // If a Flags field is encountered, name is the name used to identify the set
 // when parsing options.
 //
+// The RegisterOptions in opts, such as WithRename, modify how the fields of i
+// are registered.
+//
 // See the package documentation for a description of the structure to pass to
 // RegisterSet.
-func RegisterSet(name string, i interface{}, set *getopt.Set) error {
-	return register(name, i, set)
+func RegisterSet(name string, i interface{}, set *getopt.Set, opts ...RegisterOption) error {
+	c := &regConfig{}
+	for _, opt := range opts {
+		opt.config(c)
+	}
+	return register(name, i, set, c)
 }
 
-func register(name string, i interface{}, set *getopt.Set) error {
+// RegisterSetPrefixed is like RegisterSet except the long name of each option
+// in i is prefixed by prefix.  For example, with a prefix of "client-" the
+// option --name is registered as --client-name.  Short names are not
+// registered as they cannot be prefixed.  An option that only has a short name,
+// such as -v, is registered as --client-v.  Option names in a flags file must
+// also include the prefix.
+//
+// RegisterSetPrefixed enables multiple instances of the same options structure
+// to be registered with a single set, e.g.:
+//
+//	options.RegisterSetPrefixed("client-", &clientOptions, set)
+//	options.RegisterSetPrefixed("server-", &serverOptions, set)
+func RegisterSetPrefixed(prefix string, i interface{}, set *getopt.Set) error {
+	return register("", i, set, &regConfig{prefix: prefix})
+}
+
+// RegisterFiltered is like RegisterSet except that opts may limit which fields
+// of i are registered.  This allows different commands to expose different
+// parts of a large shared options structure.  For example:
+//
+//	options.RegisterFiltered(&myOptions, set, options.Exclude{"timeout", "v"})
+//
+// registers all the options in myOptions except --timeout and -v.
+func RegisterFiltered(i interface{}, set *getopt.Set, opts ...RegisterOption) error {
+	return RegisterSet("", i, set, opts...)
+}
+
+// A RegisterOption modifies how RegisterSet and RegisterFiltered register the
+// fields of a structure.
+type RegisterOption interface {
+	config(*regConfig)
+}
+
+// Include is a RegisterOption that limits the fields registered to those
+// named.  A field may be named by its field name or by its long or short option
+// name, without leading dashes.  If more than one Include is provided then
+// fields named by any of them are registered.
+type Include []string
+
+func (in Include) config(c *regConfig) {
+	if c.include == nil {
+		c.include = map[string]bool{}
+	}
+	for _, name := range in {
+		c.include[name] = false
+	}
+}
+
+// Exclude is a RegisterOption that prevents the named fields from being
+// registered.  Fields are named as with Include.  Exclude takes precedence over
+// Include.
+type Exclude []string
+
+func (ex Exclude) config(c *regConfig) {
+	if c.exclude == nil {
+		c.exclude = map[string]bool{}
+	}
+	for _, name := range ex {
+		c.exclude[name] = false
+	}
+}
+
+// WithRename is a RegisterOption that renames options.  The keys are the long
+// or short names declared by the options structure and the values are the new
+// names, all without leading dashes.  A long name may only be renamed to a long
+// name and a short name to a single character.  An empty value removes the
+// name, as long as the option still has a name.  For example:
+//
+//	options.RegisterSet("", &libOptions, set, options.WithRename{
+//		"name": "widget-name",
+//		"n":    "",
+//	})
+//
+// Include and Exclude match the names before they are renamed.
+type WithRename map[string]string
+
+func (r WithRename) config(c *regConfig) {
+	if c.rename == nil {
+		c.rename = map[string]string{}
+		c.renamed = map[string]bool{}
+	}
+	for from, to := range r {
+		c.rename[from] = to
+	}
+}
+
+// A regConfig modifies how the fields of a structure are registered.
+type regConfig struct {
+	prefix  string            // prefix for long names
+	include map[string]bool   // names to include, true once matched
+	exclude map[string]bool   // names to exclude, true once matched
+	rename  map[string]string // new names for options
+	renamed map[string]bool   // keys of rename that have been used
+
+	encodings *Encodings // private encodings, if any
+	validate  bool       // only validating, do not fill in a Meta
+	handle    *Meta      // filled in with the registration, if not nil
+	field     string     // only register this field, see AddOption
+}
+
+// clone returns a copy of c, as it was before it was used to register a
+// structure.
+func (c *regConfig) clone() *regConfig {
+	unmatched := func(m map[string]bool) map[string]bool {
+		if m == nil {
+			return nil
+		}
+		n := make(map[string]bool, len(m))
+		for name := range m {
+			n[name] = false
+		}
+		return n
+	}
+	n := &regConfig{
+		prefix:    c.prefix,
+		include:   unmatched(c.include),
+		exclude:   unmatched(c.exclude),
+		rename:    c.rename,
+		renamed:   unmatched(c.renamed),
+		encodings: c.encodings,
+	}
+	if n.rename != nil && n.renamed == nil {
+		n.renamed = map[string]bool{}
+	}
+	return n
+}
+
+// skip returns true if the field named field declaring the option o should
+// not be registered.
+func (c *regConfig) skip(field string, o *tag.Tag) bool {
+	names := []string{field}
+	if o.Long != "" {
+		names = append(names, o.Long)
+	}
+	if o.Short != 0 {
+		names = append(names, string(o.Short))
+	}
+	match := func(m map[string]bool) bool {
+		found := false
+		for _, name := range names {
+			if _, ok := m[name]; ok {
+				m[name] = true
+				found = true
+			}
+		}
+		return found
+	}
+	// Match both so a name in both Include and Exclude is not unmatched.
+	excluded := match(c.exclude)
+	included := match(c.include)
+	return excluded || (c.include != nil && !included)
+}
+
+// unmatched returns an error for each name in an Include, Exclude, or
+// WithRename that did not name a field or option.
+func (c *regConfig) unmatched() Errors {
+	var names []string
+	for _, m := range []map[string]bool{c.include, c.exclude} {
+		for name, matched := range m {
+			if !matched {
+				names = append(names, name)
+			}
+		}
+	}
+	for name := range c.rename {
+		if !c.renamed[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var errs Errors
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("%q does not name a field or option", name))
+	}
+	return errs
+}
+
+// apply returns o modified by c.
+func (c *regConfig) apply(o *tag.Tag) (*tag.Tag, error) {
+	if o.Long != "" {
+		if to, ok := c.rename[o.Long]; ok {
+			c.renamed[o.Long] = true
+			if strings.HasPrefix(to, "-") || strings.ContainsAny(to, " =") || utf8.RuneCountInString(to) == 1 {
+				return nil, fmt.Errorf("cannot rename --%s to %q", o.Long, to)
+			}
+			o.Long = to
+		}
+	}
+	if o.Short != 0 {
+		if to, ok := c.rename[string(o.Short)]; ok {
+			c.renamed[string(o.Short)] = true
+			r, n := utf8.DecodeRuneInString(to)
+			switch {
+			case to == "":
+				o.Short = 0
+			case n != len(to) || r == '-' || r == ' ' || r == '=':
+				return nil, fmt.Errorf("cannot rename -%c to %q", o.Short, to)
+			default:
+				o.Short = r
+			}
+		}
+	}
+	if o.Long == "" && o.Short == 0 {
+		return nil, errors.New("renamed to have no name")
+	}
+	if c.prefix != "" {
+		if o.Long == "" {
+			o.Long = string(o.Short)
+		}
+		o.Long = c.prefix + o.Long
+		o.Short = 0
+	}
+	return o, nil
+}
+
+func register(name string, i interface{}, set *getopt.Set, c *regConfig) error {
+	if c == nil {
+		c = &regConfig{}
+	}
 	v := reflect.ValueOf(i)
 	if v.Kind() != reflect.Ptr {
 		return fmt.Errorf("%T is not a pointer to a struct", i)
@@ -276,62 +758,406 @@ func register(name string, i interface{}, set *getopt.Set) error {
 	}
 	t := v.Type()
 
+	// Check all the fields before registering any of them so all the
+	// problems with i are reported at once.
+	type option struct {
+		fv       reflect.Value
+		o        *tag.Tag
+		decoder  FlagsDecoder
+		owner    string
+		units    string
+		ignore   bool   // ignore unknown options in flags files
+		override bool   // flags files override the command line
+		attach   bool   // attach sets registered later
+		advanced bool   // only displayed by HelpAll
+		example  string // example arguments
+		secret   bool   // mask the value when displayed
+		encoding string // encoding written by a DumpConfig
+	}
+	var opts []option
+	var errs Errors
+	var args argsSpec
+	var metav reflect.Value // the Meta field, if any
+	var aliases []*aliasSpec
+	fields := map[string]string{}
+
+	matched := false // c.field was found
+
 	n := t.NumField()
 	for i := 0; i < n; i++ {
 		field := t.Field(i)
 		fv := v.Field(i)
-		tag := field.Tag.Get("getopt")
-		if tag == "-" || !fv.CanSet() {
+		if c.field != "" {
+			if field.Name != c.field {
+				continue
+			}
+			matched = true
+		}
+		if field.Type == metaType {
+			if field.Tag.Get("getopt") != "-" {
+				errs = append(errs, fmt.Errorf(`%s: Meta field must be tagged getopt:"-"`, field.Name))
+			} else if fv.CanSet() {
+				metav = fv
+			}
+			continue
+		}
+		if _, ok := field.Tag.Lookup("alias-of"); ok {
+			a, err := aliasField(field)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if a.o, err = c.apply(a.o); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+				continue
+			}
+			for _, name := range optionNames(a.o) {
+				if f, ok := fields[name]; ok {
+					errs = append(errs, &DuplicateOptionError{Name: name, Field: field.Name, Other: f})
+				} else if other, ok := lookupOwner(set, name); ok {
+					errs = append(errs, &DuplicateOptionError{Name: name, Field: field.Name, Other: other})
+				}
+				fields[name] = field.Name
+			}
+			aliases = append(aliases, a)
+			continue
+		}
+		if ok, err := args.field(field, fv); ok {
+			if err != nil {
+				errs = append(errs, err)
+			}
 			continue
 		}
-		o, err := parseTag(tag)
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
+			continue
+		}
+		o, err := tag.Lookup(field.Tag)
 		if err != nil {
-			panic(err)
-		}
-		if o == nil {
-			n := strings.ToLower(field.Name)
-			for x, r := range n {
-				if x == 0 {
-					o = &optTag{short: r}
-				} else {
-					o = &optTag{long: n}
-					break
+			errs = append(errs, fieldError(field.Name, err))
+			continue
+		}
+		o = autoName(o, field.Name)
+		if c.skip(field.Name, o) {
+			continue
+		}
+		if o, err = c.apply(o); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+			continue
+		}
+		help, ok, err := lookupHelp(t, field)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			o.Help = help
+		}
+		owner := ownerName(t, field)
+		for _, name := range optionNames(o) {
+			if f, ok := fields[name]; ok {
+				errs = append(errs, &DuplicateOptionError{Name: name, Field: field.Name, Other: f})
+			} else if other, ok := lookupOwner(set, name); ok {
+				errs = append(errs, &DuplicateOptionError{Name: name, Field: owner, Other: other})
+			}
+			fields[name] = field.Name
+		}
+		advanced, err := tag.Advanced(field.Tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+			continue
+		}
+		secret, err := tag.Secret(field.Tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+			continue
+		}
+		opt := option{fv: fv, o: o, owner: owner, advanced: advanced, secret: secret, example: field.Tag.Get("example")}
+		if _, ok := fv.Addr().Interface().(*Flags); ok {
+			encoding := field.Tag.Get("encoding")
+			if encoding == "" {
+				encoding = "simple"
+			}
+			decoder, ok := c.encodings.Lookup(encoding)
+			if !ok {
+				errs = append(errs, &EncodingError{Field: field.Name, Encoding: encoding})
+				continue
+			}
+			opt.decoder = decoder
+			for _, fo := range strings.Split(field.Tag.Get("flags"), ",") {
+				switch strings.TrimSpace(fo) {
+				case "":
+				case "ignore-unknown":
+					opt.ignore = true
+				case "override":
+					opt.override = true
+				case "attach-sets":
+					opt.attach = true
+				default:
+					errs = append(errs, fmt.Errorf("%s: unknown flags option %q", field.Name, fo))
 				}
 			}
+		} else if _, ok := fv.Addr().Interface().(*DumpConfig); ok {
+			opt.encoding = field.Tag.Get("encoding")
+			if opt.encoding == "" {
+				opt.encoding = "simple"
+			}
+			if err := checkDumpEncoding(opt.encoding); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+				continue
+			}
+		} else if g, ok := fv.Addr().Interface().(genericOption); ok {
+			if !supported(g.valuePointer()) {
+				errs = append(errs, &UnsupportedTypeError{Field: field.Name, Type: field.Type})
+				continue
+			}
+		} else if units := field.Tag.Get("units"); units != "" {
+			if err := optvalue.CheckUnits(field.Type, units); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+				continue
+			}
+			opt.units = units
+		} else if !supported(optionValue(fv.Addr().Interface())) {
+			errs = append(errs, &UnsupportedTypeError{Field: field.Name, Type: field.Type})
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	for _, a := range aliases {
+		// The aliased option is renamed the same way as the alias.
+		target, err := c.apply(a.target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.field, err))
+			continue
+		}
+		name := optionNames(target)[0]
+		if _, ok := fields[name]; ok {
+			continue
 		}
-		if o.help == "" {
-			o.help = "unspecified"
+		if _, ok := lookupOwner(set, name); !ok {
+			errs = append(errs, fmt.Errorf("%s: alias of unknown option %s", a.field, name))
 		}
-		hv := []string{o.help, o.param}
-		if o.param == "" {
+	}
+	if c.field != "" && !matched {
+		errs = append(errs, fmt.Errorf("%T has no field %s", i, c.field))
+	}
+	errs = append(errs, c.unmatched()...)
+	if len(errs) > 0 {
+		return errs.Err()
+	}
+	if c.field == "" {
+		addExamples(set, lookupExamples(t)...)
+		setArgsFields(set, args)
+		applyModes(set, t)
+	}
+
+	var registered []getopt.Option
+	for _, opt := range opts {
+		fv, o := opt.fv, opt.o
+		if o.Help == "" {
+			o.Help = "unspecified"
+		}
+		hv := []string{o.Help, o.Param}
+		if o.Param == "" {
 			hv = hv[:1]
 		}
-		opt := fv.Addr().Interface()
-		if f, ok := opt.(*Flags); ok {
+		p := fv.Addr().Interface()
+		if f, ok := p.(*Flags); ok {
+			f.lock()
 			f.Sets = append(f.Sets, Set{Name: name, Set: set})
-			f.opt = set.FlagLong(opt, o.long, o.short, hv...)
-			tag := field.Tag.Get("encoding")
-			if tag == "" {
-				tag = "simple"
+			f.own = append(f.own, set)
+			f.unlock()
+			f.opt = set.FlagLong(p, o.Long, o.Short, hv...)
+			f.setRank()
+			registered = append(registered, f.opt)
+			if opt.advanced {
+				setAdvanced(f.opt)
 			}
-			decoderMu.Lock()
-			decoder, ok := decoders[tag]
-			decoderMu.Unlock()
-			if !ok {
-				return fmt.Errorf("unknown flags decoding type: %q", tag)
+			f.Decoder = opt.decoder
+			if opt.ignore {
+				f.IgnoreUnknown = true
+			}
+			if opt.override {
+				f.Override = true
+			}
+			if opt.attach {
+				f.AttachNewSets()
 			}
-			f.Decoder = decoder
 		} else {
-			op := set.FlagLong(opt, o.long, o.short, hv...)
+			if opt.units != "" {
+				p = &unitsValue{optvalue.Units{V: fv, Units: opt.units}}
+			} else {
+				p = optionValue(p)
+			}
+			optional := o.Optional && fv.Kind() != reflect.Bool
+			if optional {
+				p = &implicitValue{
+					Value:    getopt.New().FlagLong(p, "option", 0).Value(),
+					implicit: o.Implicit,
+				}
+			}
+			op := set.FlagLong(p, o.Long, o.Short, hv...)
 			// Values that are of type bool are flags.
-			if fv.Kind() == reflect.Bool {
+			if g, ok := p.(genericOption); (ok && g.isFlag()) || fv.Kind() == reflect.Bool {
 				op.SetFlag()
+			} else if optional {
+				op.SetOptional()
+			}
+			if opt.advanced {
+				setAdvanced(op)
+			}
+			if opt.secret {
+				setSecret(op)
+			}
+			if opt.encoding != "" {
+				setDumpEncoding(op, opt.encoding)
 			}
+			setFieldType(op, fv, opt.units)
+			setOptionInfo(op, o.Help, op.String())
+			registered = append(registered, op)
+		}
+		setOwner(set, o, opt.owner)
+		if opt.example != "" {
+			addExamples(set, opt.example)
+		}
+	}
+	for _, a := range aliases {
+		var target getopt.Option
+		if a.target.Long != "" {
+			target = lookup(set, a.target.Long)
+		} else {
+			target = lookup(set, a.target.Short)
+		}
+		if target == nil {
+			return fmt.Errorf("%s: alias of unknown option %s", a.field, optionNames(a.target)[0])
 		}
+		if err := addAlias(set, a.o, target); err != nil {
+			return fmt.Errorf("%s: %w", a.field, err)
+		}
+	}
+	if !c.validate && c.field == "" && (metav.IsValid() || c.handle != nil) {
+		m := newMeta(i, name, set, c.clone(), registered)
+		if metav.IsValid() {
+			metav.Set(reflect.ValueOf(Meta{m: m}))
+		}
+		if c.handle != nil {
+			c.handle.m = m
+		}
+	}
+	if name != "" {
+		return attachSet(name, set)
 	}
 	return nil
 }
 
+var (
+	ownersMu sync.Mutex
+	// owners maps each option registered in a set to the field that
+	// declared it.
+	owners = map[*getopt.Set]map[string]string{}
+)
+
+// ownerName returns the name used to identify field of t in errors about
+// options declared by more than one structure.
+func ownerName(t reflect.Type, field reflect.StructField) string {
+	if t.Name() == "" {
+		return field.Name
+	}
+	return t.Name() + "." + field.Name
+}
+
+// lookupOwner returns the owner of the option name (including leading dashes)
+// in set and true if name has already been registered in set.  The owner is
+// "" if the option was not registered by this package.
+func lookupOwner(set *getopt.Set, name string) (string, bool) {
+	ownersMu.Lock()
+	owner, ok := owners[set][name]
+	ownersMu.Unlock()
+	if ok {
+		return owner, true
+	}
+	var key interface{} = name[2:]
+	if !strings.HasPrefix(name, "--") {
+		key = []rune(name)[1]
+	}
+	return "", lookup(set, key) != nil
+}
+
+// lookup returns the option in set named by name (a string or rune), or nil.
+// The Lookup method of a getopt.Set returns a nil *option, which is not a nil
+// getopt.Option, for names it does not know.
+func lookup(set *getopt.Set, name interface{}) getopt.Option {
+	o := set.Lookup(name)
+	if o == nil || reflect.ValueOf(o).IsNil() {
+		return nil
+	}
+	return o
+}
+
+// setOwner records owner as the declarer of the options in o in set.
+func setOwner(set *getopt.Set, o *tag.Tag, owner string) {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+	m := owners[set]
+	if m == nil {
+		m = map[string]string{}
+		owners[set] = m
+	}
+	for _, name := range optionNames(o) {
+		m[name] = owner
+	}
+}
+
+// forgetOwners forgets the declarers of the options in set.  It is called once
+// no more structures will be registered with set, such as a set created by
+// ParseArgs for a single structure.
+func forgetOwners(set *getopt.Set) {
+	ownersMu.Lock()
+	delete(owners, set)
+	ownersMu.Unlock()
+}
+
+// optionNames returns the names of the options declared by o, including the
+// leading dashes.
+func optionNames(o *tag.Tag) []string {
+	var names []string
+	if o.Long != "" {
+		names = append(names, "--"+o.Long)
+	}
+	if o.Short != 0 {
+		names = append(names, "-"+string(o.Short))
+	}
+	return names
+}
+
+// An implicitValue is a getopt.Value whose parameter is optional.  When the
+// parameter is omitted the value is set to implicit, or left unchanged if
+// implicit is empty.
+type implicitValue struct {
+	getopt.Value
+	implicit string
+}
+
+func (v *implicitValue) Set(value string, opt getopt.Option) error {
+	if value == "" {
+		if v.implicit == "" {
+			return nil
+		}
+		value = v.implicit
+	}
+	return v.Value.Set(value, opt)
+}
+
+// supported returns true if p can be registered with getopt.
+func supported(p interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	getopt.New().FlagLong(p, "option", 0)
+	return true
+}
+
 // Lookup returns the value of the field in i for the specified option or nil.
 // Lookup can be used if the structure declaring the options is not available.
 // Lookup returns nil if i is invalid or does not have an option named option.
@@ -346,13 +1172,71 @@ func register(name string, i interface{}, set *getopt.Set) error {
 //	set.Getopt(args, nil)
 //	v := options.Lookup(i, "verbose").(bool)
 func Lookup(i interface{}, option string) interface{} {
+	if fv, ok := lookupField(i, option); ok {
+		return fv.Interface()
+	}
+	return nil
+}
+
+// LookupOption is like Lookup but returns the getopt.Option most recently
+// registered from the field in i for the specified option, or nil if the field
+// has not been registered.  The option reports if it was seen on the command
+// line and how many times.  Use OptionDefault for its default value.
+//
+//	if o := options.LookupOption(&myOptions, "verbose"); o != nil && o.Seen() {
+//		...
+//	}
+func LookupOption(i interface{}, option string) getopt.Option {
+	fv, ok := lookupField(i, option)
+	if !ok {
+		return nil
+	}
+	if f, ok := fv.Addr().Interface().(*Flags); ok {
+		f.lock()
+		defer f.unlock()
+		return f.opt
+	}
+	return fieldOption(fv)
+}
+
+// Count returns the number of times the specified option in i was seen on the
+// command line, or 0 if its field has not been registered.  Count permits a
+// bool field to be repeated for emphasis without declaring a getopt.Counter:
+//
+//	var opts = struct {
+//		Verbose bool `getopt:"-v be verbose, repeat for more"`
+//	}{}
+//
+//	options.Register(&opts)
+//	options.Parse()
+//	level := options.Count(&opts, "v") // 3 for -v -v -v or -vvv
+func Count(i interface{}, option string) int {
+	if o := LookupOption(i, option); o != nil {
+		return o.Count()
+	}
+	return 0
+}
+
+// OptionDefault returns the value, as a string, that o had when it was
+// registered from a field.  It returns false if o was not registered from a
+// field.
+func OptionDefault(o getopt.Option) (string, bool) {
+	optionInfosMu.Lock()
+	defer optionInfosMu.Unlock()
+	info, ok := optionInfos[o]
+	return info.def, ok
+}
+
+// lookupField returns the field in i, a pointer to a struct, that declares
+// option.
+func lookupField(i interface{}, option string) (reflect.Value, bool) {
 	v := reflect.ValueOf(i)
 	if v.Kind() != reflect.Ptr {
-		return nil
+		return reflect.Value{}, false
 	}
 	v = v.Elem()
 	if v.Kind() != reflect.Struct {
-		return nil
+		return reflect.Value{}, false
 	}
 	t := v.Type()
 
@@ -360,136 +1244,142 @@ func Lookup(i interface{}, option string) interface{} {
 	for i := 0; i < n; i++ {
 		field := t.Field(i)
 		fv := v.Field(i)
-		tag := field.Tag.Get("getopt")
-		if tag == "-" || !fv.CanSet() {
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
 			continue
 		}
-		o, err := parseTag(tag)
+		o, err := tag.Lookup(field.Tag)
 		if err != nil {
-			return nil
-		}
-		if o == nil {
-			n := strings.ToLower(field.Name)
-			for x, r := range n {
-				if x == 0 {
-					o = &optTag{short: r}
-				} else {
-					o = &optTag{long: n}
-					break
-				}
-			}
+			return reflect.Value{}, false
 		}
-		if option == o.long || option == string(o.short) {
-			return fv.Interface()
+		o = autoName(o, field.Name)
+		if option == o.Long || option == string(o.Short) {
+			return fv, true
 		}
 	}
-	return nil
+	return reflect.Value{}, false
 }
 
-// An optTag contains all the information extracted from a getopt tag.
-type optTag struct {
-	long  string
-	short rune
-	param string
-	help  string
-}
-
-func (o *optTag) String() string {
-	parts := make([]string, 0, 6)
-	parts = append(parts, "{")
-	if o.long != "" {
-		parts = append(parts, "--"+o.long)
-	}
-	if o.short != 0 {
-		parts = append(parts, "-"+string(o.short))
-	}
-	if o.param != "" {
-		parts = append(parts, "="+o.param)
+// Inspect returns the specification of each option declared by i, a pointer
+// to an options structure, in field order.  The help text includes text set by
+// SetHelp and SetHelpVar.  See tag.Inspect for details.
+func Inspect(i interface{}) ([]tag.Spec, error) {
+	specs, err := tag.Inspect(i)
+	if err != nil {
+		return nil, err
 	}
-	if o.help != "" {
-		parts = append(parts, fmt.Sprintf("%q", o.help))
+	t := reflect.TypeOf(i).Elem()
+	for x := range specs {
+		field, _ := t.FieldByName(specs[x].Field)
+		help, ok, err := lookupHelp(t, field)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			specs[x].Help = help
+		}
 	}
-	parts = append(parts, "}")
-	return strings.Join(parts, " ")
+	return specs, nil
 }
 
-// parseTag parses and returns tag as an optTag or returns an error.  nil, nil
-// is returned if tag is empty or consists only of white space.
-func parseTag(tag string) (*optTag, error) {
-	tag = strings.TrimSpace(tag)
-	if tag == "" {
-		return nil, nil
+// Bind sets the fields of i, which must be a pointer to a structure, from the
+// values of the options in set with the same names.  Bind helps programs that
+// are migrating from calling getopt.FlagLong directly to declaring their
+// options in a structure:
+//
+//	getopt.FlagLong(&name, "name", 'n', "name of the widget")
+//	getopt.Parse()
+//	...
+//	var opts struct {
+//		Name string `getopt:"--name -n=NAME name of the widget"`
+//	}
+//	err := options.Bind(getopt.CommandLine, &opts)
+//
+// Fields without a matching option in set, and Flags fields, are not changed.
+// A field's value is replaced, not appended to, even if it is a list.  If more
+// than one field cannot be set then an Errors is returned describing all of
+// them.
+func Bind(set *getopt.Set, i interface{}) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
 	}
-	next := tag
-	var o optTag
-	var arg, param string
-	for {
-		arg, param, next = nextOption(next)
-		if arg == "" || arg == "-" || arg == "--" {
-			if param != "" {
-				// Only happens with "--=FOO" or "-=FOO"
-				return nil, fmt.Errorf("getopt tag missing option name: %q", tag)
-			}
-			if o.long == "" && o.short == 0 {
-				if next != "" {
-					return nil, fmt.Errorf("getopt tag missing option name: %q", tag)
-				}
-				return nil, nil
-			}
-			o.help = next
-			return &o, nil
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	t := v.Type()
+
+	var errs Errors
+	n := t.NumField()
+	for i := 0; i < n; i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.Tag.Get("getopt") == "-" || !fv.CanSet() {
+			continue
 		}
-		if param != "" {
-			if o.param != "" {
-				return nil, fmt.Errorf("getopt tag has multiple parameter names: %q", tag)
-			}
-			o.param = param
+		o, err := tag.Lookup(field.Tag)
+		if err != nil {
+			errs = append(errs, fieldError(field.Name, err))
+			continue
 		}
-		switch argPrefix(arg) {
-		case "-":
-			if o.short != 0 {
-				return nil, fmt.Errorf("getopt tag has too many short names: %q", tag)
-			}
-			for x, r := range arg[1:] {
-				if x != 0 {
-					return nil, fmt.Errorf("getopt tag has invalid short name: %q", tag)
-				}
-				o.short = r
-			}
-		case "--":
-			if o.long != "" {
-				return nil, fmt.Errorf("getopt tag has too many long names: %q", tag)
+		o = autoName(o, field.Name)
+		var opt getopt.Option
+		if o.Long != "" {
+			opt = lookup(set, o.Long)
+		}
+		if opt == nil && o.Short != 0 {
+			opt = lookup(set, o.Short)
+		}
+		if opt == nil {
+			continue
+		}
+		p := fv.Addr().Interface()
+		if _, ok := p.(*Flags); ok {
+			continue
+		}
+		if units := field.Tag.Get("units"); units != "" {
+			if err := optvalue.CheckUnits(field.Type, units); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
+				continue
 			}
-			o.long = arg[2:]
-		default:
-			return nil, fmt.Errorf("getopt tag must not start with ---: %q", tag)
+			p = &unitsValue{optvalue.Units{V: fv, Units: units}}
+		} else if p = optionValue(p); !supported(p) {
+			errs = append(errs, &UnsupportedTypeError{Field: field.Name, Type: field.Type})
+			continue
+		}
+		fv.Set(reflect.Zero(fv.Type()))
+		s := opt.Value().String()
+		if s == "" && (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map) {
+			// An empty list, not a list of one empty string.
+			continue
+		}
+		value := getopt.New().FlagLong(p, "option", 0).Value()
+		if err := value.Set(s, opt); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.Name, err))
 		}
 	}
+	return errs.Err()
 }
 
-// nextOption returns the next option, optional parameter, and the rest of
-// the string parsed from s.  If the option is "" then s does not start with
-// an option (i.e., does not start with a -).
-func nextOption(s string) (option, param, rest string) {
-	if s == "" || s[0] != '-' {
-		return "", "", s
-	}
-	if x := strings.Index(s, " "); x >= 0 {
-		rest = strings.TrimSpace(s[x:])
-		s = s[:x]
+// autoName returns o after assigning it a name derived from the field name if
+// it does not have either a long or short name.  A single letter field name
+// becomes a short name, all other names become long names.  A new Tag is
+// returned if o is nil.
+func autoName(o *tag.Tag, name string) *tag.Tag {
+	if o == nil {
+		o = &tag.Tag{}
 	}
-	if x := strings.Index(s, "="); x >= 0 {
-		return s[:x], s[x+1:], rest
+	if o.Long != "" || o.Short != 0 {
+		return o
 	}
-	return s, "", rest
-}
-
-// argPrefix returns the leading dashes in a.
-func argPrefix(a string) string {
-	for x, c := range a {
-		if c != '-' {
-			return a[:x]
+	n := strings.ToLower(name)
+	for x, r := range n {
+		if x != 0 {
+			o.Short = 0
+			o.Long = n
+			break
 		}
+		o.Short = r
 	}
-	return a
+	return o
 }
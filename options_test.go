@@ -15,9 +15,13 @@ package options
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -90,6 +94,77 @@ func TestLookup(t *testing.T) {
 	}
 }
 
+func TestLookupOption(t *testing.T) {
+	opts := &struct {
+		Flags   Flags  `getopt:"--flags=PATH flags file"`
+		Name    string `getopt:"--name -n=NAME name"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}{Name: "bob"}
+	if o := LookupOption(opts, "name"); o != nil {
+		t.Errorf("got option %s before registration", o.Name())
+	}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"cmd", "-n", "fred", "--name=jim"}); err != nil {
+		t.Fatal(err)
+	}
+	o := LookupOption(opts, "n")
+	if o == nil {
+		t.Fatal("no option for -n")
+	}
+	if !o.Seen() || o.Count() != 2 || o.String() != "jim" {
+		t.Errorf("got seen %v, count %d, value %q, want true, 2, jim", o.Seen(), o.Count(), o.String())
+	}
+	if def, ok := OptionDefault(o); !ok || def != "bob" {
+		t.Errorf("got default %q, %v, want bob, true", def, ok)
+	}
+	if o := LookupOption(opts, "v"); o == nil || o.Seen() {
+		t.Errorf("-v: got %v, want an option that was not seen", o)
+	}
+	if o := LookupOption(opts, "flags"); o == nil || o.LongName() != "flags" {
+		t.Errorf("--flags: got %v, want the flags option", o)
+	}
+	if o := LookupOption(opts, "missing"); o != nil {
+		t.Errorf("missing returned %v, want nil", o)
+	}
+}
+
+func TestCount(t *testing.T) {
+	opts := &struct {
+		Verbose bool   `getopt:"-v be verbose"`
+		Name    string `getopt:"--name=NAME name"`
+		Debug   bool   `getopt:"--debug"`
+	}{}
+	if n := Count(opts, "v"); n != 0 {
+		t.Errorf("got count %d before registration, want 0", n)
+	}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"cmd", "-v", "-vv", "--name=bob", "--name=jim"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		want int
+	}{
+		{"v", 3},
+		{"name", 2},
+		{"debug", 0},
+		{"missing", 0},
+	} {
+		if got := Count(opts, tt.name); got != tt.want {
+			t.Errorf("%s: got count %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if !opts.Verbose {
+		t.Error("-v did not set Verbose")
+	}
+}
+
 func TestValidate(t *testing.T) {
 	opts := &struct {
 		Name string `getopt:"--the_name"`
@@ -174,17 +249,102 @@ func TestRegister(t *testing.T) {
 				t.Errorf("Registerdid not panic on bad tag")
 			}
 		}()
-		register("test", &struct {
+		Register(&struct {
 			F Flags `getopt:"bad"`
-		}{}, getopt.New())
+		}{})
 	}()
+	if err := register("test", &struct {
+		F Flags `getopt:"bad"`
+	}{}, getopt.New(), nil); err == nil {
+		t.Errorf("Did not get an error on bad tag")
+	}
 	if err := register("test", &struct {
 		F Flags `encoding:"bob"`
-	}{}, getopt.New()); err == nil {
+	}{}, getopt.New(), nil); err == nil {
 		t.Errorf("Did not get an error on bad encoding")
 	}
 }
 
+func TestRegisterErrors(t *testing.T) {
+	opts := &struct {
+		A     int      `getopt:"bad tag"`
+		B     chan int `getopt:"--chan"`
+		Name  string   `getopt:"--name"`
+		Other string   `getopt:"--name"`
+		Good  string
+		F     Flags `encoding:"bob"`
+	}{}
+	err := Validate(opts)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("got error %v of type %T, want %T", err, err, errs)
+	}
+	want := []string{
+		"A: getopt tag missing option name",
+		"B: ",
+		"option --name declared by both Name and Other",
+		`F: unknown flags decoding type: "bob"`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(errs[i].Error(), w) {
+			t.Errorf("error %d got %q, want prefix %q", i, errs[i], w)
+		}
+	}
+
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err == nil {
+		t.Fatalf("RegisterSet did not return an error")
+	}
+	set.VisitAll(func(o getopt.Option) {
+		t.Errorf("option %s registered after an error", o.Name())
+	})
+}
+
+func TestErrorTypes(t *testing.T) {
+	opts := &struct {
+		A     int      `getopt:"bad tag"`
+		B     chan int `getopt:"--chan"`
+		Name  string   `getopt:"--name"`
+		Other string   `getopt:"--name"`
+		F     Flags    `encoding:"bob"`
+	}{}
+	errs, ok := Validate(opts).(Errors)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("got %v, want 4 errors", errs)
+	}
+
+	var te *TagError
+	if !errors.As(errs[0], &te) {
+		t.Errorf("got %T, want %T", errs[0], te)
+	} else if te.Field != "A" || te.Tag != "bad tag" {
+		t.Errorf("got field %q tag %q, want field %q tag %q", te.Field, te.Tag, "A", "bad tag")
+	}
+
+	var ue *UnsupportedTypeError
+	if !errors.As(errs[1], &ue) {
+		t.Errorf("got %T, want %T", errs[1], ue)
+	} else if ue.Field != "B" || ue.Type != reflect.TypeOf(opts.B) {
+		t.Errorf("got field %q type %v, want field %q type %T", ue.Field, ue.Type, "B", opts.B)
+	}
+
+	var de *DuplicateOptionError
+	if !errors.As(errs[2], &de) {
+		t.Errorf("got %T, want %T", errs[2], de)
+	} else if de.Name != "--name" || de.Field != "Other" || de.Other != "Name" {
+		t.Errorf("got %+v", de)
+	}
+
+	var ee *EncodingError
+	if !errors.As(errs[3], &ee) {
+		t.Errorf("got %T, want %T", errs[3], ee)
+	} else if ee.Field != "F" || ee.Encoding != "bob" {
+		t.Errorf("got %+v", ee)
+	}
+}
+
 func TestSubRegisterAndParse(t *testing.T) {
 	opts := struct {
 		Value string `getopt:"--the_name=VALUE help"`
@@ -229,204 +389,34 @@ func TestSubRegisterAndParse(t *testing.T) {
 	}
 }
 
-func TestParseTag(t *testing.T) {
-	for _, tt := range []struct {
-		name string
-		in   string
-		tag  *optTag
-		str  string
-		err  string
-	}{
-		{
-			name: "nothing",
-		},
-		{
-			name: "dash",
-			in:   "-",
-		},
-		{
-			name: "dash-dash",
-			in:   "--",
-		},
-		{
-			name: "long arg",
-			in:   "--option",
-			str:  "{ --option }",
-			tag: &optTag{
-				long: "option",
-			},
-		},
-		{
-			name: "short arg",
-			in:   "-o",
-			str:  "{ -o }",
-			tag: &optTag{
-				short: 'o',
-			},
-		},
-		{
-			name: "long help",
-			in:   "--option this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				long: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help1",
-			in:   "--option -- this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				long: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help2",
-			in:   "--option - this is an option",
-			str:  `{ --option "this is an option" }`,
-			tag: &optTag{
-				long: "option",
-				help: "this is an option",
-			},
-		},
-		{
-			name: "long help3",
-			in:   "--option -- -this is an option",
-			str:  `{ --option "-this is an option" }`,
-			tag: &optTag{
-				long: "option",
-				help: "-this is an option",
-			},
-		},
-		{
-			name: "long and short arg",
-			in:   "--option -o",
-			str:  "{ --option -o }",
-			tag: &optTag{
-				long:  "option",
-				short: 'o',
-			},
-		},
-		{
-			name: "short and long arg",
-			in:   "-o --option",
-			str:  "{ --option -o }",
-			tag: &optTag{
-				long:  "option",
-				short: 'o',
-			},
-		},
-		{
-			name: "long arg with param",
-			in:   "--option=PARAM",
-			str:  "{ --option =PARAM }",
-			tag: &optTag{
-				long:  "option",
-				param: "PARAM",
-			},
-		},
-		{
-			name: "short arg with param",
-			in:   "-o=PARAM",
-			str:  "{ -o =PARAM }",
-			tag: &optTag{
-				short: 'o',
-				param: "PARAM",
-			},
-		},
-		{
-			name: "everything",
-			in:   "--option=PARAM -o -- - this is help",
-			str:  `{ --option -o =PARAM "- this is help" }`,
-			tag: &optTag{
-				long:  "option",
-				short: 'o',
-				param: "PARAM",
-				help:  "- this is help",
-			},
-		},
-		{
-			name: "two longs",
-			in:   "--option1 --option2",
-			err:  "tag has too many long names",
-		},
-		{
-			name: "two shorts",
-			in:   "-a -b",
-			err:  "tag has too many short names",
-		},
-		{
-			name: "two parms",
-			in:   "--option=PARAM1 -o=PARAM2",
-			err:  "tag has multiple parameter names",
-		},
-		{
-			name: "missing option",
-			in:   "no option",
-			err:  "tag missing option name",
-		},
-		{
-			name: "long param only",
-			in:   "--=PARAM",
-			err:  "tag missing option name",
-		},
-		{
-			name: "short param only",
-			in:   "-=PARAM",
-			err:  "tag missing option name",
-		},
-		{
-			name: "two many dashes",
-			in:   "---option",
-			err:  "tag must not start with ---",
-		},
-		{
-			name: "invalid short name",
-			in:   "-short",
-			err:  `getopt tag has invalid short name: "-short"`,
-		},
-	} {
-		t.Run(tt.name, func(t *testing.T) {
-			tag, err := parseTag(tt.in)
-			switch {
-			case err == nil && tt.err != "":
-				t.Fatalf("did not get expected error %v", tt.err)
-			case err != nil && tt.err == "":
-				t.Fatalf("unexpected error %v", err)
-			case err == nil:
-			case !strings.Contains(err.Error(), tt.err):
-				t.Fatalf("got error %v, want %v", err, tt.err)
-			}
-			if !reflect.DeepEqual(tag, tt.tag) {
-				t.Errorf("got %v, want %v", tag, tt.tag)
-			}
-			if tag != nil {
-				str := tag.String()
-				if str != tt.str {
-					t.Errorf("%s: got string %q, want %q", tt.name, str, tt.str)
-				}
-			}
-		})
+func TestSeparateTags(t *testing.T) {
+	opts := &struct {
+		Name  string `long:"name" short:"n" param:"NAME" help:"name of the widget"`
+		Count int    `getopt:"--count" help:"number of widgets"`
+		V     bool   `help:"be verbose"`
+	}{}
+	want := `
+Usage: program [-v] [--count value] [-n NAME] [parameters ...]
+     --count=value  number of widgets
+ -n, --name=NAME    name of the widget
+ -v                 be verbose
+`[1:]
+	getopt.HelpColumn = 20
+	s := getopt.New()
+	if err := RegisterSet("", opts, s); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestArgPrefix(t *testing.T) {
-	for _, tt := range []struct {
-		in  string
-		out string
-	}{
-		{"a", ""},
-		{"-a", "-"},
-		{"--a", "--"},
-		{"", ""},
-		{"-", "-"},
-		{"--", "--"},
-	} {
-		if out := argPrefix(tt.in); out != tt.out {
-			t.Errorf("argPrefix(%q) got %q want %q", tt.in, out, tt.out)
-		}
+	var buf bytes.Buffer
+	s.SetProgram("program")
+	s.PrintUsage(&buf)
+	if got := buf.String(); got != want {
+		t.Errorf("Got help:\n%s\nWant:\n%s", got, want)
+	}
+	if err := s.Getopt([]string{"program", "-n", "bob", "--count=2", "-v"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 2 || !opts.V {
+		t.Errorf("got %+v", opts)
 	}
 }
 
@@ -463,6 +453,69 @@ func TestDup(t *testing.T) {
 	}()
 }
 
+func TestRegisterNewFlags(t *testing.T) {
+	type cmdOptions struct {
+		Flags Flags  `getopt:"--flags=PATH flags file"`
+		Name  string `getopt:"--name=NAME name"`
+	}
+	template := &cmdOptions{}
+	defaults := getopt.New()
+	var level string
+	defaults.FlagLong(&level, "level", 0)
+	template.Flags.Sets = []Set{{Name: "defaults", Set: defaults}}
+	template.Flags.IgnoreUnknown = true
+	tset := getopt.New()
+	if err := RegisterSet("", template, tset); err != nil {
+		t.Fatal(err)
+	}
+	if err := template.Flags.Set("data:name=template", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	got := make([]*cmdOptions, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vopts, set := RegisterNew("", template)
+			opts := vopts.(*cmdOptions)
+			got[i] = opts
+			if n := len(opts.Flags.Sets); n != 2 || opts.Flags.Sets[0].Set != defaults || opts.Flags.Sets[1].Set != set {
+				t.Errorf("%d: got sets %v", i, opts.Flags.Sets)
+			}
+			if !opts.Flags.IgnoreUnknown {
+				t.Errorf("%d: IgnoreUnknown was not copied", i)
+			}
+			if _, err := SubParse(set, []string{"cmd", "--flags", fmt.Sprintf("data:name=%d", i)}); err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, opts := range got {
+		if want := fmt.Sprint(i); opts.Name != want {
+			t.Errorf("%d: got name %q, want %q", i, opts.Name, want)
+		}
+	}
+	if template.Name != "template" {
+		t.Errorf("template name changed to %q", template.Name)
+	}
+	if n := len(template.Flags.Sets); n != 2 {
+		t.Errorf("template has %d sets, want 2", n)
+	}
+
+	// The duplicate keeps the values read by the template.
+	vopts, _ := RegisterNew("", template)
+	opts := vopts.(*cmdOptions)
+	if err := opts.Flags.RescanAll(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "template" {
+		t.Errorf("after RescanAll got name %q, want template", opts.Name)
+	}
+}
+
 func TestParse(t *testing.T) {
 	args, cl := os.Args, getopt.CommandLine
 	defer func() {
@@ -482,3 +535,443 @@ func TestParse(t *testing.T) {
 		t.Errorf("Got args %q, want %q", pargs, []string{"arg"})
 	}
 }
+
+func TestParseCommandLine(t *testing.T) {
+	cl := getopt.CommandLine
+	defer func() {
+		getopt.CommandLine = cl
+	}()
+	getopt.CommandLine = getopt.New()
+	opts := &struct {
+		Name string `getopt:"--name a name"`
+	}{}
+	Register(opts)
+	if err := ParseCommandLine([]string{"test", "--name", "bob", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" {
+		t.Errorf("Got name %q, want %q", opts.Name, "bob")
+	}
+	if args := getopt.Args(); len(args) != 1 || args[0] != "arg" {
+		t.Errorf("Got args %q, want %q", args, []string{"arg"})
+	}
+	if err := ParseCommandLine([]string{"test", "--bogus"}); err == nil {
+		t.Error("--bogus did not return an error")
+	}
+}
+
+func TestRegisterAndParseArgs(t *testing.T) {
+	cl := getopt.CommandLine
+	defer func() {
+		getopt.CommandLine = cl
+	}()
+	getopt.CommandLine = getopt.New()
+
+	tmpfile, err := mkFile("count=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+
+	NewFlags("flags")
+	opts := &struct {
+		Name  string `getopt:"--name a name"`
+		Count int    `getopt:"--count a count"`
+	}{}
+	args, err := RegisterAndParseArgs(opts, []string{"test", "--name", "bob", "--flags", tmpfile, "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 3 {
+		t.Errorf("got %+v", opts)
+	}
+	if len(args) != 1 || args[0] != "arg" {
+		t.Errorf("Got args %q, want %q", args, []string{"arg"})
+	}
+
+	getopt.CommandLine = getopt.New()
+	if _, err := RegisterAndParseArgs(opts, []string{"test", "--bogus"}); err == nil {
+		t.Errorf("did not get an error for --bogus")
+	}
+}
+
+type registerA struct {
+	Name string `getopt:"--name -n a name"`
+}
+
+type registerB struct {
+	Other string `getopt:"-n another name"`
+}
+
+type registerC struct {
+	Count int `getopt:"--count -c a count"`
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	set := getopt.New()
+	if err := RegisterSet("", &registerA{}, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSet("", &registerC{}, set); err != nil {
+		t.Fatal(err)
+	}
+	err := RegisterSet("", &registerB{}, set)
+	var de *DuplicateOptionError
+	if !errors.As(err, &de) {
+		t.Fatalf("got error %v of type %T, want %T", err, err, de)
+	}
+	want := "option -n declared by both registerA.Name and registerB.Other"
+	if got := err.Error(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	set = getopt.New()
+	set.FlagLong(new(string), "name", 0)
+	err = RegisterSet("", &registerA{}, set)
+	want = "registerA.Name: option --name already defined"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestRegisterSetPrefixed(t *testing.T) {
+	type endpoint struct {
+		Addr    string `getopt:"--addr -a=ADDR address to use"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}
+	client := &endpoint{}
+	server := &endpoint{}
+	set := getopt.New()
+	if err := RegisterSetPrefixed("client-", client, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSetPrefixed("server-", server, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--client-addr=here", "--server-addr", "there", "--server-v"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := (endpoint{Addr: "here"}); *client != want {
+		t.Errorf("got client %+v, want %+v", *client, want)
+	}
+	if want := (endpoint{Addr: "there", Verbose: true}); *server != want {
+		t.Errorf("got server %+v, want %+v", *server, want)
+	}
+	if lookup(set, 'a') != nil || lookup(set, 'v') != nil {
+		t.Errorf("short names were registered")
+	}
+}
+
+func TestRegisterFiltered(t *testing.T) {
+	type shared struct {
+		Name    string        `getopt:"--name a name"`
+		Count   int           `getopt:"--count -c a count"`
+		Verbose bool          `getopt:"-v be verbose"`
+		Timeout time.Duration `getopt:"--timeout a timeout"`
+	}
+	for _, tt := range []struct {
+		name string
+		opts []RegisterOption
+		want []string
+		err  string
+	}{{
+		name: "none",
+		want: []string{"name", "count", "v", "timeout"},
+	}, {
+		name: "include",
+		opts: []RegisterOption{Include{"Name", "c"}},
+		want: []string{"name", "count"},
+	}, {
+		name: "exclude",
+		opts: []RegisterOption{Exclude{"timeout", "Verbose"}},
+		want: []string{"name", "count"},
+	}, {
+		name: "both",
+		opts: []RegisterOption{Include{"name", "count"}, Exclude{"count"}},
+		want: []string{"name"},
+	}, {
+		name: "unknown",
+		opts: []RegisterOption{Include{"name"}, Exclude{"bogus"}},
+		err:  `"bogus" does not name a field or option`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			set := getopt.New()
+			err := RegisterFiltered(&shared{}, set, tt.opts...)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			set.VisitAll(func(o getopt.Option) {
+				name := o.LongName()
+				if name == "" {
+					name = o.ShortName()
+				}
+				got = append(got, name)
+			})
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got options %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRename(t *testing.T) {
+	type library struct {
+		Name  string `getopt:"--name -n a name"`
+		Count int    `getopt:"-c a count"`
+	}
+	opts := &library{}
+	set := getopt.New()
+	err := RegisterSet("", opts, set, WithRename{"name": "widget-name", "n": "", "c": "C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--widget-name=bob", "-C", "3"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := (library{Name: "bob", Count: 3}); *opts != want {
+		t.Errorf("got %+v, want %+v", *opts, want)
+	}
+
+	for _, tt := range []struct {
+		rename WithRename
+		err    string
+	}{
+		{WithRename{"bogus": "x"}, `"bogus" does not name a field or option`},
+		{WithRename{"name": "x"}, `Name: cannot rename --name to "x"`},
+		{WithRename{"c": "cc"}, `Count: cannot rename -c to "cc"`},
+		{WithRename{"c": ""}, `Count: renamed to have no name`},
+	} {
+		err := RegisterSet("", &library{}, getopt.New(), tt.rename)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%v: got error %v, want %q", tt.rename, err, tt.err)
+		}
+	}
+}
+
+func TestOptional(t *testing.T) {
+	type options struct {
+		Color string `getopt:"--color -c?=WHEN colorize output" implicit:"auto"`
+		Level int    `getopt:"--level?=N set the level"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+	}{
+		{nil, options{Color: "never", Level: 1}},
+		{[]string{"--color"}, options{Color: "auto", Level: 1}},
+		{[]string{"-c"}, options{Color: "auto", Level: 1}},
+		{[]string{"--color=always"}, options{Color: "always", Level: 1}},
+		{[]string{"-calways"}, options{Color: "always", Level: 1}},
+		{[]string{"--level"}, options{Color: "never", Level: 1}},
+		{[]string{"--level=3", "--color"}, options{Color: "auto", Level: 3}},
+	} {
+		opts := &options{Color: "never", Level: 1}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Getopt(append([]string{"test"}, tt.args...), nil); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if *opts != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	type options struct {
+		Env      map[string]string `getopt:"--env=KEY=VALUE set an environment variable"`
+		Limit    map[string]int    `getopt:"--limit=KEY=N set a limit"`
+		Features map[string]bool   `getopt:"--feature=NAME enable a feature"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+		err  string
+	}{{
+		want: options{Limit: map[string]int{"cpu": 1}},
+	}, {
+		args: []string{"--env", "a=b=c", "--env=d=", "--limit", "cpu=4", "--limit=mem=0x800", "--feature=fast", "--feature", "slow=false"},
+		want: options{
+			Env:      map[string]string{"a": "b=c", "d": ""},
+			Limit:    map[string]int{"cpu": 4, "mem": 2048},
+			Features: map[string]bool{"fast": true, "slow": false},
+		},
+	}, {
+		args: []string{"--limit", "mem=lots"},
+		err:  `key "mem": invalid integer "lots"`,
+	}, {
+		args: []string{"--env", "novalue"},
+		err:  `"novalue" is not of the form key=value`,
+	}, {
+		args: []string{"--feature", "fast=maybe"},
+		err:  `key "fast": invalid boolean "maybe"`,
+	}} {
+		defaults := &options{Limit: map[string]int{"cpu": 1}}
+		opts, set := RegisterNew("", defaults)
+		err := set.Getopt(append([]string{"test"}, tt.args...), nil)
+		switch {
+		case err == nil && tt.err != "":
+			t.Errorf("%q: did not get error %q", tt.args, tt.err)
+			continue
+		case err != nil && (tt.err == "" || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%q: got error %v, want %q", tt.args, err, tt.err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got := *opts.(*options); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.args, got, tt.want)
+		}
+		if defaults.Limit["cpu"] != 1 || len(defaults.Limit) != 1 {
+			t.Errorf("%q: defaults modified: %v", tt.args, defaults.Limit)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	type options struct {
+		Events int    `getopt:"--events=N number of events" units:"si"`
+		Buffer uint16 `getopt:"--buffer=N buffer size" units:"iec"`
+	}
+	for _, tt := range []struct {
+		args []string
+		want options
+		err  string
+	}{
+		{[]string{"--events=10k"}, options{Events: 10000}, ""},
+		{[]string{"--events=2M"}, options{Events: 2000000}, ""},
+		{[]string{"--events=-1G"}, options{Events: -1000000000}, ""},
+		{[]string{"--events=2Mi"}, options{Events: 2 << 20}, ""},
+		{[]string{"--events=42"}, options{Events: 42}, ""},
+		{[]string{"--buffer=4k"}, options{Buffer: 4096}, ""},
+		{[]string{"--buffer=64k"}, options{}, "value out of range"},
+		{[]string{"--events=10x"}, options{}, "not a valid number"},
+	} {
+		opts := &options{}
+		set := getopt.New()
+		if err := RegisterSet("", opts, set); err != nil {
+			t.Fatal(err)
+		}
+		err := set.Getopt(append([]string{"test"}, tt.args...), nil)
+		switch {
+		case err == nil && tt.err != "":
+			t.Errorf("%q: did not get error %q", tt.args, tt.err)
+		case err != nil && (tt.err == "" || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%q: got error %v, want %q", tt.args, err, tt.err)
+		case err == nil && *opts != tt.want:
+			t.Errorf("%q: got %+v, want %+v", tt.args, *opts, tt.want)
+		}
+	}
+}
+
+func TestUnitsErrors(t *testing.T) {
+	err := Validate(&struct {
+		A string        `units:"si"`
+		B time.Duration `units:"si"`
+		C int           `units:"metric"`
+	}{})
+	want := []string{
+		"A: units do not apply to string",
+		"B: units do not apply to time.Duration",
+		`C: unknown units "metric"`,
+	}
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != len(want) {
+		t.Fatalf("got error %v, want %d errors", err, len(want))
+	}
+	for i, w := range want {
+		if got := errs[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	set := getopt.New()
+	name := "bob"
+	count := 0
+	verbose := false
+	var list []string
+	set.FlagLong(&name, "name", 'n')
+	set.FlagLong(&count, "count", 'c')
+	set.Flag(&verbose, 'v')
+	set.FlagLong(&list, "list", 0)
+	if err := set.Getopt([]string{"test", "-c", "3", "-v", "--list=a,b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &struct {
+		Name    string   `getopt:"--name"`
+		Count   int      `getopt:"-c"`
+		Verbose bool     `getopt:"-v"`
+		List    []string `getopt:"--list"`
+		Other   string   `getopt:"--other"`
+	}{
+		List:  []string{"old"},
+		Other: "unchanged",
+	}
+	if err := Bind(set, opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 3 || !opts.Verbose || opts.Other != "unchanged" {
+		t.Errorf("got %+v", opts)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(opts.List, want) {
+		t.Errorf("got list %q, want %q", opts.List, want)
+	}
+
+	if err := Bind(set, opts.Name); err == nil {
+		t.Errorf("Bind of a non-pointer did not return an error")
+	}
+}
+
+func TestDupWith(t *testing.T) {
+	type dupOptions struct {
+		Name    string        `getopt:"--name=NAME name of the widget"`
+		Timeout time.Duration `getopt:"--timeout how long to wait"`
+		List    []string      `getopt:"--list=ITEM items"`
+		Verbose bool          `getopt:"-v be verbose"`
+	}
+	defaults := &dupOptions{Name: "default", Timeout: time.Second}
+
+	d, err := DupWith(defaults, map[string]interface{}{
+		"name":    "tenant",
+		"timeout": "5s",
+		"list":    []interface{}{"a", "b"},
+		"v":       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := d.(*dupOptions)
+	if got.Name != "tenant" || got.Timeout != 5*time.Second || !reflect.DeepEqual(got.List, []string{"a", "b"}) || !got.Verbose {
+		t.Errorf("got %+v", got)
+	}
+	if defaults.Name != "default" || defaults.Timeout != time.Second || defaults.List != nil || defaults.Verbose {
+		t.Errorf("DupWith changed the original: %+v", defaults)
+	}
+
+	for _, overrides := range []map[string]interface{}{
+		{"timeout": "forever"},
+		{"missing": "x"},
+	} {
+		if _, err := DupWith(defaults, overrides); err == nil {
+			t.Errorf("%v: did not get an error", overrides)
+		}
+	}
+	if _, err := DupWith(42, nil); err == nil {
+		t.Error("did not get an error for an int")
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A Source describes where the value of an option came from.
+type Source int
+
+const (
+	SourceDefault     Source = iota // The option was not set
+	SourceCommandLine               // The option was set on the command line
+	SourceFile                      // The option was set by a flags file
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceCommandLine:
+		return "command line"
+	case SourceFile:
+		return "file"
+	}
+	return "unknown"
+}
+
+var (
+	precedenceMu sync.Mutex
+	filesFirst   bool // flags files take precedence over the command line
+)
+
+var (
+	parseCtxMu sync.Mutex
+	parseCtxs  = map[getopt.Option]context.Context{} // Flags options being parsed by SubParseContext
+)
+
+// parseContext returns the context opt is being parsed with, or
+// context.Background() if it is not being parsed by SubParseContext.
+func parseContext(opt getopt.Option) context.Context {
+	parseCtxMu.Lock()
+	defer parseCtxMu.Unlock()
+	if ctx, ok := parseCtxs[opt]; ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// Precedence sets the precedence of the sources of option values for the
+// program, highest first.  The default precedence is:
+//
+//	options.Precedence([]options.Source{
+//		options.SourceCommandLine,
+//		options.SourceFile,
+//		options.SourceDefault,
+//	})
+//
+// Placing SourceFile before SourceCommandLine causes every Flags to behave as
+// if its Override field is set.  SourceDefault is always the lowest precedence
+// and may be omitted.  An empty order restores the default.  An error is
+// returned, and the precedence is not changed, if order contains an unknown
+// source or contains a source more than once, or if SourceDefault is not last.
+// There is no separate source for the environment, values read from the
+// environment by a Flags (e.g., --flags=env:NAME) are from SourceFile.
+func Precedence(order []Source) error {
+	seen := map[Source]bool{}
+	for i, s := range order {
+		switch {
+		case s != SourceDefault && s != SourceCommandLine && s != SourceFile:
+			return fmt.Errorf("unknown source %d", s)
+		case seen[s]:
+			return fmt.Errorf("source %s listed more than once", s)
+		case s == SourceDefault && i != len(order)-1:
+			return fmt.Errorf("source %s is not last", s)
+		}
+		seen[s] = true
+	}
+	first := false
+	for _, s := range order {
+		if s == SourceCommandLine {
+			break
+		}
+		if s == SourceFile {
+			first = true
+			break
+		}
+	}
+	precedenceMu.Lock()
+	filesFirst = first
+	precedenceMu.Unlock()
+	return nil
+}
+
+// override reports if the values in f take precedence over the command line.
+func (f *Flags) override() bool {
+	if f.Override {
+		return true
+	}
+	precedenceMu.Lock()
+	defer precedenceMu.Unlock()
+	return filesFirst
+}
+
+// A ParseResult describes the result of parsing a set of options.  Options are
+// identified by their long name, or their short name if they have no long
+// name, without leading dashes.  This is the same name used in a flags file.
+//
+// Args holds all the positional arguments.  DashArgs holds the arguments that
+// follow the first "--" and is nil if there is no "--".  Wrapper commands use
+// DashArgs to separate their own arguments from those of the command they run:
+//
+//	wrapper --timeout=5s input -- ls -l
+//
+// has the Args input, ls, and -l and the DashArgs ls and -l.  If "--" follows
+// a positional argument in a set that is not interspersed, Args also includes
+// the "--".
+type ParseResult struct {
+	Args     []string          // The remaining arguments
+	DashArgs []string          // The arguments following "--", if any
+	Seen     map[string]bool   // Options set on the command line
+	Sources  map[string]Source // The source of every option
+	Files    map[string]string // The flags file that set each SourceFile option
+	Warnings []error           // Non-fatal problems, such as ignored unknown options or deprecated aliases
+}
+
+// ParseArgs is like SubRegisterAndParse but returns a ParseResult rather than
+// just the remaining arguments.  A new getopt set is created, i is registered
+// with that set, and then the set is parsed with args by SubParse.  The first
+// element of args is equivalent to a command name and is not parsed.  As with
+// SubRegisterAndParse, the set is named by the command name, so a Flags in i
+// reads the options from the command's section of a flags file.
+func ParseArgs(i interface{}, args []string) (*ParseResult, error) {
+	return ParseContext(context.Background(), i, args)
+}
+
+// ParseFromMap is like ParseArgs but the command line is built from m rather
+// than passed as arguments.  The keys of m are the long or short names of
+// options, without leading dashes.  Each value is applied exactly as if it was
+// given on the command line, so the option is seen and its value is parsed and
+// validated by the option.  The options are applied in the sorted order of the
+// keys.  The value of a flag, such as a bool option, may be empty.  The program
+// name, as returned by getopt.Set.Program, is used as the command name, so, as
+// with ParseArgs, a Flags in i reads the options from the command's section of
+// a flags file.  ParseFromMap is intended for tests:
+//
+//	r, err := options.ParseFromMap(&opts, map[string]string{
+//		"name":  "bob",
+//		"count": "3",
+//		"v":     "",
+//	})
+//
+// An error is returned if a key does not name an option of i.
+func ParseFromMap(i interface{}, m map[string]string) (*ParseResult, error) {
+	set := getopt.New()
+	if err := RegisterSet(set.Program(), i, set); err != nil {
+		return nil, err
+	}
+	forgetOwners(set)
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{set.Program()}
+	flags := map[getopt.Option]string{} // values to set flags to once seen
+	for _, name := range names {
+		o := lookup(set, name)
+		if o == nil && utf8.RuneCountInString(name) == 1 {
+			r, _ := utf8.DecodeRuneInString(name)
+			o = lookup(set, r)
+		}
+		if o == nil {
+			return nil, fmt.Errorf("%T has no option named %q", i, name)
+		}
+		arg := "--" + name
+		if o.LongName() != name {
+			arg = "-" + name
+		}
+		switch value := m[name]; {
+		case o.IsFlag():
+			if value != "" {
+				flags[o] = value
+			}
+			args = append(args, arg)
+		case arg[1] == '-':
+			args = append(args, arg+"="+value)
+		case takesParam(o):
+			// An empty value attached to a short option would
+			// cause the next argument to be taken as its value.
+			args = append(args, arg, value)
+		default:
+			args = append(args, arg+value)
+		}
+	}
+	r, err := SubParse(set, args)
+	if err != nil {
+		return r, err
+	}
+	for o, value := range flags {
+		if err := o.Value().Set(value, o); err != nil {
+			return r, fmt.Errorf("%s: %w", o.Name(), err)
+		}
+	}
+	return r, nil
+}
+
+// ParseContext is like ParseArgs but flags files are read with ctx, see
+// Flags.SetContext.
+func ParseContext(ctx context.Context, i interface{}, args []string) (*ParseResult, error) {
+	if len(args) == 0 {
+		return &ParseResult{}, nil
+	}
+	set := getopt.New()
+	if err := RegisterSet(args[0], i, set); err != nil {
+		return nil, err
+	}
+	forgetOwners(set)
+	return SubParseContext(ctx, set, args)
+}
+
+// SubParse parses args with set, which must already have its options
+// registered, and returns the ParseResult.  The first element of args is
+// equivalent to a command name and is not parsed.  On error both the partial
+// result and the error are returned.
+func SubParse(set *getopt.Set, args []string) (*ParseResult, error) {
+	return SubParseContext(context.Background(), set, args)
+}
+
+// SubParseContext is like SubParse but flags files are read with ctx, see
+// Flags.SetContext.
+func SubParseContext(ctx context.Context, set *getopt.Set, args []string) (*ParseResult, error) {
+	// getopt calls Flags.Set, which does not take a context, so record ctx
+	// for each Flags option of set while it is parsed.
+	var opts []getopt.Option
+	set.VisitAll(func(o getopt.Option) {
+		if _, ok := o.Value().(*Flags); ok {
+			opts = append(opts, o)
+		}
+	})
+	parseCtxMu.Lock()
+	for _, o := range opts {
+		parseCtxs[o] = ctx
+	}
+	parseCtxMu.Unlock()
+	defer func() {
+		parseCtxMu.Lock()
+		for _, o := range opts {
+			delete(parseCtxs, o)
+		}
+		parseCtxMu.Unlock()
+	}()
+	autoDisplayWidth()
+	err := getoptArgs(set, args)
+	if err == nil {
+		err = finishParse(set)
+	}
+	return parseResult(set), err
+}
+
+// parseResult returns the ParseResult of set, which has been parsed.
+func parseResult(set *getopt.Set) *ParseResult {
+	r := &ParseResult{
+		Args:    set.Args(),
+		Seen:    map[string]bool{},
+		Sources: map[string]Source{},
+		Files:   map[string]string{},
+	}
+	_, r.DashArgs = splitDash(set)
+	r.Warnings = append(r.Warnings, deprecatedUses(set)...)
+
+	// Collect the options that were set by flags files.
+	files := map[getopt.Option]string{}
+	forced := map[getopt.Option]bool{} // set by an Override file
+	set.VisitAll(func(o getopt.Option) {
+		f, ok := o.Value().(*Flags)
+		if !ok {
+			return
+		}
+		f.lock()
+		defer f.unlock()
+		for fo, path := range f.values {
+			files[fo] = path
+			if f.override() {
+				forced[fo] = true
+			}
+		}
+		if f.unknown != nil {
+			r.Warnings = append(r.Warnings, f.unknown)
+		}
+	})
+
+	set.VisitAll(func(o getopt.Option) {
+		name := o.LongName()
+		if name == "" {
+			name = o.ShortName()
+		}
+		if o.Seen() {
+			r.Seen[name] = true
+		}
+		switch path, ok := files[o]; {
+		case forced[o]:
+			r.Sources[name] = SourceFile
+			r.Files[name] = path
+		case o.Seen():
+			r.Sources[name] = SourceCommandLine
+		case ok:
+			r.Sources[name] = SourceFile
+			r.Files[name] = path
+		default:
+			r.Sources[name] = SourceDefault
+		}
+	})
+	return r
+}
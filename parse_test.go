@@ -0,0 +1,205 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tmpfile, err := mkFile("test.count = 3\ntest.bogus = 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+
+	opts := &struct {
+		Name    string `getopt:"--name=NAME name of the widget"`
+		Count   int    `getopt:"--count -c=COUNT number of widgets"`
+		Verbose bool   `getopt:"-v be verbose"`
+		Flags   Flags  `getopt:"--flags=PATH read defaults from path"`
+	}{
+		Flags: Flags{IgnoreUnknown: true},
+	}
+	r, err := ParseArgs(opts, []string{"test", "--name=bob", "--flags", tmpfile, "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "bob" || opts.Count != 3 || opts.Verbose {
+		t.Errorf("got %+v", opts)
+	}
+	if want := []string{"arg"}; !reflect.DeepEqual(r.Args, want) {
+		t.Errorf("got args %q, want %q", r.Args, want)
+	}
+	if want := map[string]bool{"name": true, "flags": true}; !reflect.DeepEqual(r.Seen, want) {
+		t.Errorf("got seen %v, want %v", r.Seen, want)
+	}
+	wantSources := map[string]Source{
+		"name":  SourceCommandLine,
+		"count": SourceFile,
+		"v":     SourceDefault,
+		"flags": SourceCommandLine,
+	}
+	if !reflect.DeepEqual(r.Sources, wantSources) {
+		t.Errorf("got sources %v, want %v", r.Sources, wantSources)
+	}
+	if want := map[string]string{"count": tmpfile}; !reflect.DeepEqual(r.Files, want) {
+		t.Errorf("got files %v, want %v", r.Files, want)
+	}
+	if len(r.Warnings) != 1 {
+		t.Fatalf("got warnings %v, want 1 warning", r.Warnings)
+	}
+	var ue *UnknownOptionError
+	if !errors.As(r.Warnings[0], &ue) || !reflect.DeepEqual(ue.Names, []string{"--test.bogus"}) {
+		t.Errorf("got warning %v, want unknown option --test.bogus", r.Warnings[0])
+	}
+}
+
+func TestParseArgsOverride(t *testing.T) {
+	tmpfile, err := mkFile("[test]\nname = policy\ncount = 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+
+	opts := &struct {
+		Name   string `getopt:"--name=NAME name of the widget"`
+		Count  int    `getopt:"--count=COUNT number of widgets"`
+		Size   int    `getopt:"--size=SIZE size of the widgets"`
+		Policy Flags  `getopt:"--policy=PATH mandatory settings" flags:"override"`
+	}{}
+	args := []string{"test", "--name=early", "--policy", tmpfile, "--count=5", "--size=2"}
+	r, err := ParseArgs(opts, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "policy" || opts.Count != 3 || opts.Size != 2 {
+		t.Errorf("got %+v", opts)
+	}
+	wantSources := map[string]Source{
+		"name":   SourceFile,
+		"count":  SourceFile,
+		"size":   SourceCommandLine,
+		"policy": SourceCommandLine,
+	}
+	if !reflect.DeepEqual(r.Sources, wantSources) {
+		t.Errorf("got sources %v, want %v", r.Sources, wantSources)
+	}
+	if !r.Seen["count"] {
+		t.Errorf("count not reported as seen")
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	defer Precedence(nil)
+	tmpfile, err := mkFile("[test]\nname = file\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+
+	type precOptions struct {
+		Name  string `getopt:"--name=NAME name of the widget"`
+		Flags Flags  `getopt:"--flags=PATH flags file"`
+	}
+	args := []string{"test", "--flags", tmpfile, "--name=cli"}
+	for _, tt := range []struct {
+		order  []Source
+		want   string
+		source Source
+	}{
+		{nil, "cli", SourceCommandLine},
+		{[]Source{SourceFile, SourceCommandLine}, "file", SourceFile},
+		{[]Source{SourceFile, SourceCommandLine, SourceDefault}, "file", SourceFile},
+		{[]Source{SourceCommandLine, SourceFile}, "cli", SourceCommandLine},
+	} {
+		if err := Precedence(tt.order); err != nil {
+			t.Fatalf("%v: %v", tt.order, err)
+		}
+		opts := &precOptions{}
+		r, err := ParseArgs(opts, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.Name != tt.want || r.Sources["name"] != tt.source {
+			t.Errorf("%v: got %q from %v, want %q from %v", tt.order, opts.Name, r.Sources["name"], tt.want, tt.source)
+		}
+	}
+
+	for _, order := range [][]Source{
+		{SourceFile, SourceFile},
+		{SourceDefault, SourceFile},
+		{Source(42)},
+	} {
+		if err := Precedence(order); err == nil {
+			t.Errorf("%v: did not get an error", order)
+		}
+	}
+}
+
+func TestParseFromMap(t *testing.T) {
+	type mapOptions struct {
+		Name    string   `getopt:"--name=NAME name of the widget"`
+		Count   int      `getopt:"--count -c=COUNT number of widgets"`
+		N       int      `getopt:"-n=N a number"`
+		List    []string `getopt:"--list=ITEM items"`
+		Verbose bool     `getopt:"-v be verbose"`
+		Quiet   bool     `getopt:"--quiet be quiet"`
+		Debug   bool     `getopt:"--debug debug"`
+	}
+	opts := &mapOptions{Quiet: true}
+	r, err := ParseFromMap(opts, map[string]string{
+		"name":  "bob",
+		"c":     "3",
+		"n":     "7",
+		"list":  "a,b",
+		"v":     "",
+		"quiet": "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mapOptions{Name: "bob", Count: 3, N: 7, List: []string{"a", "b"}, Verbose: true}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("got %+v, want %+v", opts, want)
+	}
+	wantSeen := map[string]bool{"name": true, "count": true, "n": true, "list": true, "v": true, "quiet": true}
+	if !reflect.DeepEqual(r.Seen, wantSeen) {
+		t.Errorf("got seen %v, want %v", r.Seen, wantSeen)
+	}
+
+	short := &struct {
+		S       string `getopt:"-s=S a string"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}{S: "default"}
+	if _, err := ParseFromMap(short, map[string]string{"s": "", "v": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if short.S != "" || !short.Verbose {
+		t.Errorf("got %+v, want an empty S and Verbose", short)
+	}
+
+	for _, m := range []map[string]string{
+		{"bogus": "1"},
+		{"count": "many"},
+		{"debug": "maybe"},
+	} {
+		if _, err := ParseFromMap(&mapOptions{}, m); err == nil {
+			t.Errorf("%v: did not get an error", m)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A Pool is a pool of options structures, each registered with its own
+// getopt.Set, that are reused rather than duplicated and registered for each
+// parse.  It is intended for servers that parse many command lines
+// concurrently, one per request:
+//
+//	var pool = options.MustNewPool(&theOptions{Count: 42})
+//
+//	func handle(args []string) {
+//		i, set := pool.Get()
+//		defer pool.Put(i, set)
+//		opts := i.(*theOptions)
+//		if _, err := options.SubParse(set, args); err != nil {
+//			...
+//		}
+//		...
+//	}
+//
+// A Pool is safe to use from multiple goroutines.
+type Pool struct {
+	template interface{}
+	state    *State
+	pool     sync.Pool
+}
+
+// A poolEntry is an options structure and the set it is registered with.
+type poolEntry struct {
+	i   interface{}
+	set *getopt.Set
+}
+
+// NewPool returns a Pool of options structures initialized from template,
+// which must be a pointer to an options structure.  The values of template
+// when NewPool is called are the default values of the structures returned
+// by Get.  An error is returned if template is not a valid options structure.
+func NewPool(template interface{}) (*Pool, error) {
+	// Registering a structure adds its set to the Sets of its Flags, so
+	// validate a duplicate rather than template.
+	d, err := dup(template)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(d); err != nil {
+		return nil, err
+	}
+	if template, err = dup(template); err != nil {
+		return nil, err
+	}
+	state, err := Snapshot(template)
+	if err != nil {
+		return nil, err
+	}
+	return &Pool{template: template, state: state}, nil
+}
+
+// MustNewPool is like NewPool but panics on error.  It is intended for
+// initializing package level variables.
+func MustNewPool(template interface{}) *Pool {
+	p, err := NewPool(template)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Get returns an options structure, of the same type as the template passed
+// to NewPool, and the getopt.Set its options are registered with.  The values
+// of the options are the defaults and none of the options have been seen.  The
+// structure and set should be returned to p with Put once they are no longer
+// used.
+func (p *Pool) Get() (interface{}, *getopt.Set) {
+	if e, ok := p.pool.Get().(*poolEntry); ok {
+		runtime.SetFinalizer(e, nil)
+		return e.i, e.set
+	}
+	i, set := RegisterNew("", p.template)
+	resetFlags(set)
+	return i, set
+}
+
+// Put resets the options structure i and set, which were returned by Get, and
+// returns them to p.  The options are set back to their default values and
+// marked as not seen, and any Flags in i forget the values they have read.
+// Neither i nor set may be used once Put has been called.  Put panics if i is
+// not the type of structure managed by p.
+func (p *Pool) Put(i interface{}, set *getopt.Set) {
+	set.VisitAll(func(o getopt.Option) {
+		if o.Seen() {
+			o.Reset()
+		}
+	})
+	resetFlags(set)
+	clearParsed(set)
+	if err := Restore(i, p.state); err != nil {
+		panic(fmt.Errorf("options.Pool.Put: %v", err))
+	}
+	// The sync.Pool drops entries it no longer wants without telling us,
+	// so release the state of the set of an entry once it is collected.
+	e := &poolEntry{i: i, set: set}
+	runtime.SetFinalizer(e, func(e *poolEntry) { releaseSet(e.set) })
+	p.pool.Put(e)
+}
+
+// resetFlags resets each Flags option in set to forget the values it has
+// read.
+func resetFlags(set *getopt.Set) {
+	set.VisitAll(func(o getopt.Option) {
+		if f, ok := o.Value().(*Flags); ok {
+			f.reset()
+		}
+	})
+}
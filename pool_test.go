@@ -0,0 +1,75 @@
+package options
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	type poolOptions struct {
+		Flags Flags             `getopt:"--flags=PATH flags file"`
+		Name  string            `getopt:"--name=NAME name"`
+		Count int               `getopt:"--count=N count"`
+		List  []string          `getopt:"--list=ITEM items"`
+		Label map[string]string `getopt:"--label=KEY=VALUE labels"`
+	}
+	p, err := NewPool(&poolOptions{Count: 42, List: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile, err := mkFile("name = file\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile)
+
+	for n := 0; n < 3; n++ {
+		i, set := p.Get()
+		opts := i.(*poolOptions)
+		if opts.Name != "" || opts.Count != 42 || !reflect.DeepEqual(opts.List, []string{"a"}) || len(opts.Label) != 0 {
+			t.Fatalf("%d: got %+v, want the defaults", n, opts)
+		}
+		if set.IsSet("count") || set.IsSet("flags") {
+			t.Fatalf("%d: options are still seen", n)
+		}
+		if opts.Flags.String() != "" {
+			t.Fatalf("%d: flags still has %q", n, opts.Flags.String())
+		}
+		if _, err := SubParse(set, []string{"test", "--flags", tmpfile, "--count=7", "--list=b", "--label=k=v"}); err != nil {
+			t.Fatal(err)
+		}
+		if opts.Name != "file" || opts.Count != 7 || !reflect.DeepEqual(opts.List, []string{"b"}) || opts.Label["k"] != "v" {
+			t.Fatalf("%d: got %+v", n, opts)
+		}
+		p.Put(i, set)
+		changeMu.Lock()
+		parsed := parsedSets[set]
+		changeMu.Unlock()
+		if parsed {
+			t.Fatalf("%d: set is still parsed after Put", n)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i, set := p.Get()
+			defer p.Put(i, set)
+			if _, err := SubParse(set, []string{"test", "--name=bob"}); err != nil {
+				t.Error(err)
+			}
+			if opts := i.(*poolOptions); opts.Name != "bob" || opts.Count != 42 {
+				t.Errorf("got %+v", opts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := NewPool(poolOptions{}); err == nil {
+		t.Errorf("NewPool of a non-pointer did not return an error")
+	}
+}
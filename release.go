@@ -0,0 +1,96 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"github.com/pborman/getopt/v2"
+)
+
+// releaseSet forgets everything recorded about set and its options.  It is
+// called once set will no longer be used, such as the scratch sets of
+// Validate and DupWith and the sets of a Pool dropped by its sync.Pool, so
+// the package level state keyed by sets and options does not grow without
+// bound.
+func releaseSet(set *getopt.Set) {
+	var opts []getopt.Option
+	set.VisitAll(func(o getopt.Option) {
+		opts = append(opts, o)
+	})
+
+	forgetOwners(set)
+
+	argsMu.Lock()
+	delete(setArgsN, set)
+	delete(setDashed, set)
+	argsMu.Unlock()
+
+	modesMu.Lock()
+	delete(setModes, set)
+	modesMu.Unlock()
+
+	deprecatedMu.Lock()
+	delete(deprecated, set)
+	deprecatedMu.Unlock()
+
+	changeMu.Lock()
+	delete(parsedSets, set)
+	for _, o := range opts {
+		delete(changeFuncs, o)
+	}
+	changeMu.Unlock()
+
+	helpMu.Lock()
+	delete(setExamples, set)
+	for _, o := range opts {
+		delete(advanced, o)
+	}
+	helpMu.Unlock()
+
+	fieldTypesMu.Lock()
+	for _, o := range opts {
+		if ft, ok := fieldTypes[o]; ok {
+			key := fieldKey{addr: ft.addr, t: ft.t}
+			if fieldOptions[key] == o {
+				delete(fieldOptions, key)
+			}
+			delete(fieldTypes, o)
+		}
+	}
+	fieldTypesMu.Unlock()
+
+	disabledMu.Lock()
+	for _, o := range opts {
+		delete(disabled, o)
+	}
+	disabledMu.Unlock()
+
+	dumpMu.Lock()
+	for _, o := range opts {
+		delete(secrets, o)
+		delete(dumpEncodings, o)
+	}
+	dumpMu.Unlock()
+
+	optionInfosMu.Lock()
+	for _, o := range opts {
+		delete(optionInfos, o)
+	}
+	optionInfosMu.Unlock()
+
+	ranksMu.Lock()
+	for _, o := range opts {
+		delete(setters, o)
+	}
+	ranksMu.Unlock()
+}
@@ -0,0 +1,86 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// stateSize returns the number of entries in the package level state keyed
+// by sets and options.
+func stateSize() int {
+	ownersMu.Lock()
+	n := len(owners)
+	ownersMu.Unlock()
+	fieldTypesMu.Lock()
+	n += len(fieldTypes) + len(fieldOptions)
+	fieldTypesMu.Unlock()
+	dumpMu.Lock()
+	n += len(secrets)
+	dumpMu.Unlock()
+	optionInfosMu.Lock()
+	n += len(optionInfos)
+	optionInfosMu.Unlock()
+	return n
+}
+
+func TestReleaseSet(t *testing.T) {
+	type releaseOptions struct {
+		Name     string `getopt:"--name=NAME name"`
+		Password string `getopt:"--password=PASSWORD password" secret:""`
+		Count    int    `getopt:"--count=N count"`
+	}
+	opts := &releaseOptions{}
+
+	before := stateSize()
+	for n := 0; n < 3; n++ {
+		if err := Validate(opts); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DupWith(opts, map[string]interface{}{"name": "bob"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := stateSize(); after != before {
+		t.Errorf("Validate and DupWith grew the state from %d to %d entries", before, after)
+	}
+
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"test", "--name=bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if stateSize() == before {
+		t.Fatal("RegisterSet did not record any state")
+	}
+	releaseSet(set)
+	if after := stateSize(); after != before {
+		t.Errorf("releaseSet left %d entries, want %d", after, before)
+	}
+	changeMu.Lock()
+	parsed := parsedSets[set]
+	changeMu.Unlock()
+	if parsed {
+		t.Error("set is still parsed after releaseSet")
+	}
+
+	ownersMu.Lock()
+	nOwners := len(owners)
+	ownersMu.Unlock()
+	if _, err := ParseArgs(&releaseOptions{}, []string{"test", "--name=bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubRegisterAndParse(&releaseOptions{}, []string{"test", "--name=bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFromMap(&releaseOptions{}, map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	ownersMu.Lock()
+	if len(owners) != nOwners {
+		t.Errorf("parsing with new sets grew the owners from %d to %d", nOwners, len(owners))
+	}
+	ownersMu.Unlock()
+}
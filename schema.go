@@ -0,0 +1,151 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// A Schema describes how a configuration source, such as a flags file
+// encoding, names options.  It is used by CheckSchema.
+type Schema struct {
+	// NestedNames is true if the source turns dotted names, such as
+	// server.port, into nested maps, as SimpleDecoder does.
+	NestedNames bool
+
+	// LowerCase is true if the source converts all names to lower case, as
+	// viper does.
+	LowerCase bool
+
+	// LongOnly is true if the source only names options by their long
+	// names, as is typical of environment variable mappings.
+	LongOnly bool
+}
+
+// SimpleSchema is the Schema of SimpleDecoder.
+var SimpleSchema = Schema{NestedNames: true}
+
+// An UnsettableOptionError is returned by CheckSchema for an option that a
+// configuration source can never set.  Reasons explains why each of the names
+// of the option cannot be used.
+type UnsettableOptionError struct {
+	Set     string // The name of the set, if any
+	Name    string // The option, including leading dashes
+	Reasons []string
+}
+
+func (e *UnsettableOptionError) Error() string {
+	name := e.Name
+	if e.Set != "" {
+		name = e.Set + ": " + name
+	}
+	return fmt.Sprintf("%s: cannot be set from a flags file: %s", name, strings.Join(e.Reasons, "; "))
+}
+
+// CheckSchema returns an error for each option in f.Sets, and in the sets
+// registered by NewSet, that can never be set by a configuration source
+// described by s.  This finds options that can only be set on the command
+// line, such as a short only option when s.LongOnly is set, an option whose
+// name is also the name of a set, or an option whose only name is shadowed by
+// another option.  Flags, Help, HelpAll, and DumpConfig options are not
+// checked.  If more than one option cannot be set then an Errors is returned.
+//
+//	if err := opts.Flags.CheckSchema(options.SimpleSchema); err != nil {
+//		...
+//	}
+func (f *Flags) CheckSchema(s Schema) error {
+	f.lock()
+	sets := f.allSets()
+	f.unlock()
+
+	fold := func(name string) string {
+		if s.LowerCase {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+	// sections are the names that select a set rather than an option,
+	// indexed by the name of the enclosing set.
+	sections := map[string]map[string]string{}
+	for _, set := range sets {
+		if set.Name == "" {
+			continue
+		}
+		prefix := ""
+		for _, n := range strings.Split(set.Name, ".") {
+			if sections[prefix] == nil {
+				sections[prefix] = map[string]string{}
+			}
+			sections[prefix][fold(n)] = set.Name
+			if prefix != "" {
+				prefix += "."
+			}
+			prefix += n
+		}
+	}
+
+	var errs Errors
+	for _, set := range sets {
+		owners := map[string]getopt.Option{} // option first using each name
+		set.VisitAll(func(o getopt.Option) {
+			switch o.Value().(type) {
+			case *Flags, *Help, *HelpAll, *DumpConfig:
+				return
+			}
+			var names []string
+			if o.LongName() != "" {
+				names = append(names, o.LongName())
+			}
+			if o.ShortName() != "" && !s.LongOnly {
+				names = append(names, o.ShortName())
+			}
+			var reasons []string
+			if len(names) == 0 {
+				reasons = append(reasons, "it has no long name")
+			}
+			settable := false
+			for _, name := range names {
+				key := fold(name)
+				switch {
+				case s.NestedNames && strings.Contains(name, "."):
+					reasons = append(reasons, fmt.Sprintf("%s contains a period", name))
+				case s.LowerCase && key != name:
+					reasons = append(reasons, fmt.Sprintf("%s is not lower case", name))
+				case sections[set.Name][key] != "":
+					reasons = append(reasons, fmt.Sprintf("%s is also the name of the set %s", name, sections[set.Name][key]))
+				case owners[key] != nil:
+					reasons = append(reasons, fmt.Sprintf("%s is also a name of %s", name, owners[key].Name()))
+				default:
+					settable = true
+				}
+			}
+			for _, name := range names {
+				if key := fold(name); owners[key] == nil {
+					owners[key] = o
+				}
+			}
+			if !settable {
+				errs = append(errs, &UnsettableOptionError{
+					Set:     set.Name,
+					Name:    o.Name(),
+					Reasons: reasons,
+				})
+			}
+		})
+	}
+	return errs.Err()
+}
@@ -0,0 +1,96 @@
+package options
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestCheckSchema(t *testing.T) {
+	opts := &struct {
+		Flags   Flags  `getopt:"--flags=PATH flags file"`
+		Name    string `getopt:"--name=NAME name of the widget"`
+		Verbose bool   `getopt:"-v be verbose"`
+		Level   string `getopt:"--log.level=LEVEL log level"`
+		Debug   bool   `getopt:"-D debug"`
+		Server  string `getopt:"--server=ADDR server address"`
+	}{}
+	if err := RegisterSet("", opts, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+	sub := &struct {
+		Port int `getopt:"--port=PORT port to listen on"`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", sub, set); err != nil {
+		t.Fatal(err)
+	}
+	opts.Flags.Sets = append(opts.Flags.Sets, Set{Name: "server.tls", Set: set})
+
+	for _, tt := range []struct {
+		name   string
+		schema Schema
+		want   map[string][]string
+	}{{
+		name: "json",
+		want: map[string][]string{
+			"--server": {"server is also the name of the set server.tls"},
+		},
+	}, {
+		name:   "simple",
+		schema: SimpleSchema,
+		want: map[string][]string{
+			"--log.level": {"log.level contains a period"},
+			"--server":    {"server is also the name of the set server.tls"},
+		},
+	}, {
+		name:   "viper",
+		schema: Schema{NestedNames: true, LowerCase: true},
+		want: map[string][]string{
+			"-D":          {"D is not lower case"},
+			"--log.level": {"log.level contains a period"},
+			"--server":    {"server is also the name of the set server.tls"},
+		},
+	}, {
+		name:   "long only",
+		schema: Schema{LongOnly: true},
+		want: map[string][]string{
+			"-D":       {"it has no long name"},
+			"-v":       {"it has no long name"},
+			"--server": {"server is also the name of the set server.tls"},
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := opts.Flags.CheckSchema(tt.schema)
+			got := map[string][]string{}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				errs = Errors{err}
+			}
+			for _, err := range errs {
+				var ue *UnsettableOptionError
+				if !errors.As(err, &ue) {
+					t.Fatalf("got error %v, want an UnsettableOptionError", err)
+				}
+				got[ue.Name] = ue.Reasons
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// An option whose only name is used by another option.
+	set = getopt.New()
+	var long, short bool
+	set.FlagLong(&long, "x", 0)
+	set.FlagLong(&short, "", 'x')
+	f := &Flags{Sets: []Set{{Set: set}}}
+	err := f.CheckSchema(Schema{})
+	var ue *UnsettableOptionError
+	if !errors.As(err, &ue) || (ue.Name != "-x" && ue.Name != "--x") {
+		t.Errorf("got error %v, want one x option to be shadowed", err)
+	}
+}
@@ -1,45 +1,170 @@
 package options
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 )
 
-// unescape returns line with leading/trailing spaces and comments stripped as
-// well as backslash processing have been done.
-func unescape(line []byte) string {
+// stripComment returns line with leading and trailing white space and any
+// comment removed.  A # only starts a comment when it is neither escaped by a
+// backslash nor within quotes.  Backslashes and quotes are left in place to be
+// processed by unquote.  A quote that is not closed does not quote the rest of
+// the line.
+func stripComment(line []byte) string {
 	line = bytes.TrimLeft(line, " \t")
 	if len(line) == 0 || line[0] == '#' {
 		return ""
 	}
-	escape := false
-	p := 0
-Loop:
-	for _, c := range line {
-		switch {
-		case escape:
-			escape = false
-		case c == '\\':
-			escape = true
+	literal := map[int]bool{} // positions of unclosed quotes
+	for {
+		var quote byte
+		start := 0 // position of the opening quote
+		end := 0   // end of the line up to the last non-space
+		escape := false
+	Loop:
+		for i, c := range line {
+			switch {
+			case escape:
+				escape = false
+			case quote == '\'':
+				if c == '\'' {
+					quote = 0
+				}
+			case c == '\\' && quote != 0:
+				escape = true
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\\':
+				escape = true
+			case (c == '"' || c == '\'') && !literal[i]:
+				quote, start = c, i
+			case c == '#':
+				break Loop
+			case c == ' ' || c == '\t' || c == '\r':
+				continue
+			}
+			end = i + 1
+		}
+		if quote == 0 {
+			return string(line[:end])
+		}
+		literal[start] = true
+	}
+}
+
+// unquote returns s with its quotes removed and backslash escapes processed,
+// similar to a single word in the shell.  Outside of quotes \X is replaced with
+// X.  Within single quotes all characters are taken literally.  Within double
+// quotes only \" and \\ are escapes, so "C:\dir" is C:\dir.  White space is
+// preserved.
+//
+// If strict is false then a quote that is not closed and a trailing backslash
+// are taken literally.  If strict is true they are errors.
+func unquote(s string, strict bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 == len(s) {
+				if strict {
+					return "", fmt.Errorf("trailing backslash")
+				}
+				b.WriteByte(c)
+				continue
+			}
+			i++
+			b.WriteByte(s[i])
+			continue
+		case '\'', '"':
+		default:
+			b.WriteByte(c)
 			continue
-		case c == '#':
-			break Loop
 		}
-		line[p] = c
-		p++
+		j := i + 1
+		for ; j < len(s) && s[j] != c; j++ {
+			if c == '"' && s[j] == '\\' && j+1 < len(s) && (s[j+1] == '"' || s[j+1] == '\\') {
+				j++
+			}
+		}
+		if j == len(s) {
+			if strict {
+				return "", fmt.Errorf("unterminated %c quote", c)
+			}
+			b.WriteByte(c)
+			continue
+		}
+		q := s[i+1 : j]
+		if c == '"' {
+			q = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(q)
+		}
+		b.WriteString(q)
+		i = j
 	}
-	return string(bytes.TrimSpace(line[:p]))
+	return b.String(), nil
+}
+
+// A lineReader returns the lines read from r, without their newlines, as
+// bytes.Split(data, []byte{'\n'}) would return the lines of data.
+type lineReader struct {
+	r    *bufio.Reader
+	done bool  // no lines remain
+	err  error // the error, other than io.EOF, that ended reading
+}
+
+// next returns the next line and true, or false if there are no more lines.
+func (lr *lineReader) next() ([]byte, bool) {
+	if lr.done {
+		return nil, false
+	}
+	line, err := lr.r.ReadBytes('\n')
+	switch err {
+	case nil:
+		return line[:len(line)-1], true
+	case io.EOF:
+		lr.done = true
+		return line, true
+	}
+	lr.done = true
+	lr.err = err
+	return nil, false
+}
+
+// continued returns line without its trailing backslash and true if line ends
+// with a backslash that is not itself escaped.  Comment lines are never
+// continued.
+func continued(line []byte) ([]byte, bool) {
+	line = bytes.TrimRight(line, " \t\r")
+	if t := bytes.TrimLeft(line, " \t"); len(t) > 0 && t[0] == '#' {
+		return line, false
+	}
+	n := 0
+	for n < len(line) && line[len(line)-1-n] == '\\' {
+		n++
+	}
+	if n%2 == 0 {
+		return line, false
+	}
+	return line[:len(line)-1], true
 }
 
 // SimpleDecoder decodes data as a set of name=value pairs, one pair per line.
 // Keys and values are separated by an equals sign (=), with optional white
 // space on either side of the equal sign.  Comments are introduced by the pound
-// (#) character, unless prefaced by a backslash (\).  \X is replaced with X.  A
-// backslash at the end of the line is ignored (no line concatination).  If the
-// value begins and ends with double quote ("), the double duotes are trimmed
-// (but no futher processing is done).  A non-backslashed # within quotes still
-// introduces a comment.
+// (#) character, unless prefaced by a backslash (\) or within quotes.  A
+// backslash at the end of a line joins the next line to it, with the leading
+// white space of the next line removed.
+//
+// Values are unquoted much as the shell unquotes a word, except that white
+// space is preserved.  Outside of quotes \X is replaced with X.  Within single
+// quotes (') all characters are taken literally.  Within double quotes (") \"
+// is replaced with " and \\ with \, any other backslash is taken literally.  A
+// quote that is not closed, as in don't, is taken literally.
 //
 // Examples lines:
 //
@@ -50,28 +175,155 @@ Loop:
 //	name= "a value"
 //	name = \# is the value # this is the comment
 //	name = " a value with spaces "
+//	url = "http://host/path#fragment"
+//	password = 'p#ss"word'            # password is p#ss"word
+//	path = 'C:\dir\'                  # path is C:\dir\
 //	set.name = value # set name in Options set "name"
+//	command = run --verbose \
+//	          --count=3      # command is "run --verbose --count=3"
+//
+// A name may be assigned more than once.  The values of a repeated name are
+// returned as a []string, in the order they appear, and each value is passed in
+// turn to the option's Set method.  This is useful for list options:
+//
+//	include = /usr/include
+//	include = /usr/local/include
+//
+// NewSimpleDecoder returns a decoder that instead treats a repeated name as an
+// error or uses only its first or last value.
+//
+// A line of the form [section] causes the names on the following lines to be
+// prefixed by section and a period, as with INI files.  The line [] returns to
+// unprefixed names.  The following two examples are equivalent:
+//
+//	sub.name = value
+//	sub.count = 3
+//
+//	[sub]
+//	name = value
+//	count = 3
+//
+// SimpleDecoder is the decoder returned by NewSimpleDecoder(SimpleConfig{}).
 func SimpleDecoder(data []byte) (map[string]interface{}, error) {
+	return SimpleConfig{}.decode(data)
+}
+
+// SimpleReaderDecoder is the ReaderDecoder of SimpleDecoder.  It decodes r one
+// line at a time, as it is read.
+func SimpleReaderDecoder(r io.Reader) (map[string]interface{}, error) {
+	return SimpleConfig{}.decodeReader(r)
+}
+
+// StrictSimpleDecoder is like SimpleDecoder but returns an error if a value has
+// a quote that is not closed or ends in a backslash, rather than taking them
+// literally.  It is registered as the encoding "simple-strict".
+func StrictSimpleDecoder(data []byte) (map[string]interface{}, error) {
+	return SimpleConfig{Strict: true}.decode(data)
+}
+
+// A Duplicates determines how a simple decoder handles a name that is assigned
+// more than once.
+type Duplicates int
+
+const (
+	DuplicatesAppend Duplicates = iota // All values are returned as a []string
+	DuplicatesError                    // A repeated name is an error
+	DuplicatesFirst                    // The first value is used
+	DuplicatesLast                     // The last value is used
+)
+
+// A SimpleConfig configures a decoder returned by NewSimpleDecoder.  The zero
+// value is the configuration of SimpleDecoder.
+type SimpleConfig struct {
+	Strict     bool       // Unclosed quotes and trailing backslashes are errors
+	Duplicates Duplicates // How repeated names are handled
+}
+
+// NewSimpleDecoder returns a decoder of the format described by SimpleDecoder
+// that is configured by c.  For example, to make a repeated name an error:
+//
+//	flags.SetEncoding(options.NewSimpleDecoder(options.SimpleConfig{
+//		Duplicates: options.DuplicatesError,
+//	}))
+//
+// or to register it as an encoding to be used with the encoding struct tag:
+//
+//	options.RegisterEncoding("simple-unique", options.NewSimpleDecoder(
+//		options.SimpleConfig{Duplicates: options.DuplicatesError}))
+func NewSimpleDecoder(c SimpleConfig) FlagsDecoder {
+	return c.decode
+}
+
+// NewSimpleReaderDecoder is like NewSimpleDecoder but returns a ReaderDecoder.
+func NewSimpleReaderDecoder(c SimpleConfig) ReaderDecoder {
+	return c.decodeReader
+}
+
+func (c SimpleConfig) decode(data []byte) (map[string]interface{}, error) {
+	return c.decodeReader(bytes.NewReader(data))
+}
+
+// decodeReader decodes the lines read from r, one line at a time.
+func (c SimpleConfig) decodeReader(r io.Reader) (map[string]interface{}, error) {
 	m := map[string]interface{}{}
-	for n, d := range bytes.Split(data, []byte{'\n'}) {
-		line := unescape(d)
+	section := ""
+	lr := &lineReader{r: bufio.NewReader(r)}
+	n := 0
+	for {
+		d, ok := lr.next()
+		if !ok {
+			break
+		}
+		n++
+		// Errors are reported on the first line of a continued line.
+		first := n
+		for !lr.done {
+			cd, ok := continued(d)
+			if !ok {
+				break
+			}
+			next, ok := lr.next()
+			if !ok {
+				break
+			}
+			n++
+			d = append(append([]byte{}, cd...), bytes.TrimLeft(next, " \t")...)
+		}
+		// A backslash at the end of the file has nothing to join.
+		if cd, ok := continued(d); ok {
+			d = cd
+		}
+		line := stripComment(d)
 		if line == "" {
 			continue
 		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: invalid section: %q", first, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if strings.ContainsAny(section, " \t=") {
+				return nil, fmt.Errorf("line %d: invalid section: %q", first, line)
+			}
+			continue
+		}
 		x := strings.Index(line, "=")
 		if x < 0 {
-			return nil, fmt.Errorf("line %d: missing value: %q", n+1, line)
+			return nil, fmt.Errorf("line %d: missing value: %q", first, line)
 		}
 		if x == 0 {
-			return nil, fmt.Errorf("line %d: missing name: %q", n+1, line)
+			return nil, fmt.Errorf("line %d: missing name: %q", first, line)
 		}
 		name := strings.TrimSpace(line[:x])
 		if strings.Index(name, " ") >= 0 {
-			return nil, fmt.Errorf("line %d: space in name: %q", n+1, line)
+			return nil, fmt.Errorf("line %d: space in name: %q", first, line)
+		}
+		value, err := unquote(strings.TrimLeft(line[x+1:], " \t"), c.Strict)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v: %q", first, err, line)
 		}
-		value := strings.TrimSpace(line[x+1:])
-		if e := len(value); e > 1 && value[0] == '"' && value[e-1] == '"' {
-			value = value[1 : e-1]
+		if section != "" {
+			name = section + "." + name
 		}
 		fields := strings.Split(name, ".")
 		m := m
@@ -88,12 +340,27 @@ func SimpleDecoder(data []byte) (map[string]interface{}, error) {
 			}
 			fields = fields[1:]
 		}
-		switch m[fields[0]].(type) {
-		case nil, string:
+		switch v := m[fields[0]].(type) {
+		case nil:
 			m[fields[0]] = value
-		default:
+		case map[string]interface{}:
 			return nil, fmt.Errorf("%s: conflict on field %s", name, fields[0])
+		case string:
+			switch c.Duplicates {
+			case DuplicatesError:
+				return nil, fmt.Errorf("line %d: %s already set", first, name)
+			case DuplicatesFirst:
+			case DuplicatesLast:
+				m[fields[0]] = value
+			default:
+				m[fields[0]] = []string{v, value}
+			}
+		case []string:
+			m[fields[0]] = append(v, value)
 		}
 	}
+	if lr.err != nil {
+		return nil, lr.err
+	}
 	return m, nil
 }
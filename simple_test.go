@@ -4,9 +4,10 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
-func TestUnescape(t *testing.T) {
+func TestStripComment(t *testing.T) {
 	for _, tt := range []struct {
 		in, out string
 	}{
@@ -16,21 +17,66 @@ func TestUnescape(t *testing.T) {
 		{` leading space`, `leading space`},
 		{`trailing space `, `trailing space`},
 		{`  space  `, `space`},
-		{`a \# pound # and comment`, `a # pound`},
+		{`a \# pound # and comment`, `a \# pound`},
 		{`name = "value " `, `name = "value "`},
-		{`\\`, `\`},
-		{`\#`, `#`},
-		{`\\\#`, `\#`},
-		{`\\\#\x`, `\#x`},
-		{`foo\`, `foo`},
+		{`name = "a # b" # comment`, `name = "a # b"`},
+		{`name = 'a # b' # comment`, `name = 'a # b'`},
+		{`name = "a \" # b" # comment`, `name = "a \" # b"`},
+		{`name = 'a \' # b`, `name = 'a \'`},
+		{`name = don't # comment`, `name = don't`},
+		{`name = "a" "b # comment`, `name = "a" "b`},
+		{`escaped\ `, `escaped\ `},
+		{`\\`, `\\`},
+		{`foo\`, `foo\`},
 	} {
-		out := unescape([]byte(tt.in))
+		out := stripComment([]byte(tt.in))
 		if out != tt.out {
 			t.Errorf("`%s`: got `%s`, want `%s`", tt.in, out, tt.out)
 		}
 	}
 }
 
+func TestUnquote(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+		err     string // error in strict mode
+	}{
+		{in: ``, out: ``},
+		{in: `a value`, out: `a value`},
+		{in: `\#`, out: `#`},
+		{in: `\\\#\x`, out: `\#x`},
+		{in: `" a value "`, out: ` a value `},
+		{in: `"a # b"`, out: `a # b`},
+		{in: `"say \"hi\""`, out: `say "hi"`},
+		{in: `"C:\dir"`, out: `C:\dir`},
+		{in: `"a\\b"`, out: `a\b`},
+		{in: `'a \" b'`, out: `a \" b`},
+		{in: `'p#ss"word'`, out: `p#ss"word`},
+		{in: `a "b" 'c'`, out: `a b c`},
+		{in: `"a"'b'`, out: `ab`},
+		{in: `don't`, out: `don't`, err: `unterminated ' quote`},
+		{in: `"open`, out: `"open`, err: `unterminated " quote`},
+		{in: `"a\"`, out: `"a"`, err: `unterminated " quote`},
+		{in: `foo\`, out: `foo\`, err: `trailing backslash`},
+	} {
+		out, err := unquote(tt.in, false)
+		if err != nil {
+			t.Errorf("`%s`: unexpected error %v", tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("`%s`: got `%s`, want `%s`", tt.in, out, tt.out)
+		}
+		out, err = unquote(tt.in, true)
+		switch {
+		case err == nil && tt.err != "":
+			t.Errorf("`%s`: strict did not get error %q", tt.in, tt.err)
+		case err != nil && err.Error() != tt.err:
+			t.Errorf("`%s`: strict got error %v, want %q", tt.in, err, tt.err)
+		case err == nil && out != tt.out:
+			t.Errorf("`%s`: strict got `%s`, want `%s`", tt.in, out, tt.out)
+		}
+	}
+}
+
 func TestSimpleDecoder(t *testing.T) {
 	for _, tt := range []struct {
 		name string
@@ -101,20 +147,120 @@ sub = other
 `,
 			err: "conflict on field sub",
 		},
+		{
+			name: "repeated",
+			in: `
+key = value1
+key = value2
+sub.key = subvalue1
+key = value3
+sub.key = subvalue2
+`,
+			m: map[string]interface{}{
+				"key": []string{"value1", "value2", "value3"},
+				"sub": map[string]interface{}{
+					"key": []string{"subvalue1", "subvalue2"},
+				},
+			},
+		},
+		{
+			name: "repeated conflict",
+			in: `
+sub = value1
+sub = value2
+sub.key = value
+`,
+			err: "conflict on field sub",
+		},
+		{
+			name: "continuation",
+			in: `
+command = run --verbose \
+          --count=3 \
+	--name=bob
+next = value \\
+escaped = a\\\
+b
+# comment \
+last = value
+`,
+			m: map[string]interface{}{
+				"command": `run --verbose --count=3 --name=bob`,
+				"next":    `value \`,
+				"escaped": `a\b`,
+				"last":    `value`,
+			},
+		},
+		{
+			name: "continuation at end",
+			in:   "name = value \\",
+			m:    map[string]interface{}{"name": "value"},
+		},
+		{
+			name: "continuation error line",
+			in:   "a = b\n\nname \\\n value",
+			err:  `line 3: missing value: "name value"`,
+		},
+		{
+			name: "sections",
+			in: `
+key = value
+[sub]
+key1 = subvalue1
+[ sub ] # comment
+key2 = subvalue2
+[sub.child]
+key = childvalue
+[]
+other = value2
+`,
+			m: map[string]interface{}{
+				"key":   "value",
+				"other": "value2",
+				"sub": map[string]interface{}{
+					"key1": "subvalue1",
+					"key2": "subvalue2",
+					"child": map[string]interface{}{
+						"key": "childvalue",
+					},
+				},
+			},
+		},
+		{
+			name: "bad section",
+			in:   "[sub",
+			err:  `line 1: invalid section: "[sub"`,
+		},
+		{
+			name: "section with space",
+			in:   "[a b]",
+			err:  `line 1: invalid section: "[a b]"`,
+		},
+		{
+			name: "quoted comment",
+			in: `
+url = "http://host/path#frag" # comment
+password = 'p#ss"word'
+`,
+			m: map[string]interface{}{
+				"url":      "http://host/path#frag",
+				"password": `p#ss"word`,
+			},
+		},
 		{
 			name: "complex",
 			in: `
 # This is a multiple line test
 key1=value1
   key2 = "value 2" # comment
-key3 = "value #" # the comment wasn't escaped
+key3 = "value #" # the quoted # is not a comment
 sub.key1 = subvalue1
 sub.key2 = subvalue2
 `,
 			m: map[string]interface{}{
 				"key1": `value1`,
 				"key2": `value 2`,
-				"key3": `"value`,
+				"key3": `value #`,
 				"sub": map[string]interface{}{
 					"key1": "subvalue1",
 					"key2": "subvalue2",
@@ -142,3 +288,72 @@ sub.key2 = subvalue2
 		})
 	}
 }
+
+func TestStrictSimpleDecoder(t *testing.T) {
+	m, err := StrictSimpleDecoder([]byte("name = 'a value'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"name": "a value"}; !reflect.DeepEqual(m, want) {
+		t.Errorf("got %v, want %v", m, want)
+	}
+	_, err = StrictSimpleDecoder([]byte("\nname = don't\n"))
+	if want := `line 2: unterminated ' quote: "name = don't"`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestSimpleDuplicates(t *testing.T) {
+	in := []byte("name = a\nother = x\nname = b\nname = c\n")
+	for _, tt := range []struct {
+		name string
+		dups Duplicates
+		want interface{}
+		err  string
+	}{
+		{"append", DuplicatesAppend, []string{"a", "b", "c"}, ""},
+		{"error", DuplicatesError, nil, "line 3: name already set"},
+		{"first", DuplicatesFirst, "a", ""},
+		{"last", DuplicatesLast, "c", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewSimpleDecoder(SimpleConfig{Duplicates: tt.dups})(in)
+			switch {
+			case err == nil && tt.err == "":
+			case err == nil:
+				t.Fatalf("did not get expected error %v", tt.err)
+			case err.Error() != tt.err:
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			default:
+				return
+			}
+			if !reflect.DeepEqual(m["name"], tt.want) {
+				t.Errorf("got %#v, want %#v", m["name"], tt.want)
+			}
+			if m["other"] != "x" {
+				t.Errorf("got other %#v, want x", m["other"])
+			}
+		})
+	}
+}
+
+func TestSimpleReaderDecoder(t *testing.T) {
+	in := "name = bob\ncommand = run \\\n  --verbose # comment\n[sub]\ncount = 3\n"
+	want := map[string]interface{}{
+		"name":    "bob",
+		"command": "run --verbose",
+		"sub":     map[string]interface{}{"count": "3"},
+	}
+	m, err := SimpleReaderDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got map %#v, want %#v", m, want)
+	}
+
+	_, err = SimpleReaderDecoder(iotest.TimeoutReader(strings.NewReader(in)))
+	if err != iotest.ErrTimeout {
+		t.Errorf("got error %v, want %v", err, iotest.ErrTimeout)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// An optionInfo is the help text and default value of an option registered
+// from a structure.
+type optionInfo struct {
+	help string
+	def  string
+}
+
+var (
+	optionInfosMu sync.Mutex
+	optionInfos   = map[getopt.Option]optionInfo{}
+)
+
+// setOptionInfo records the help text and default value of opt.
+func setOptionInfo(opt getopt.Option, help, def string) {
+	optionInfosMu.Lock()
+	optionInfos[opt] = optionInfo{help: help, def: def}
+	optionInfosMu.Unlock()
+}
+
+// skeletonWriters are the encodings supported by WriteSkeleton.
+var skeletonWriters = map[string]func(io.Writer, *skeletonSection) error{
+	"simple": writeSimpleSkeleton,
+	"json":   writeJSONSkeleton,
+	"yaml":   writeYAMLSkeleton,
+}
+
+// A skeletonSection is the options of a named set, and the sets whose names
+// are nested in it.
+type skeletonSection struct {
+	name     string // the last element of the set name
+	path     string // the full set name
+	entries  []skeletonEntry
+	children []*skeletonSection
+}
+
+// A skeletonEntry is a single option written by WriteSkeleton.
+type skeletonEntry struct {
+	name  string
+	value string
+	help  string
+}
+
+// section returns the section for the dotted set name, creating it and its
+// parents as needed.
+func (s *skeletonSection) section(name string) *skeletonSection {
+	if name == "" {
+		return s
+	}
+	for _, n := range strings.Split(name, ".") {
+		var child *skeletonSection
+		for _, c := range s.children {
+			if c.name == n {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			path := n
+			if s.path != "" {
+				path = s.path + "." + n
+			}
+			child = &skeletonSection{name: n, path: path}
+			s.children = append(s.children, child)
+		}
+		s = child
+	}
+	return s
+}
+
+// WriteSkeleton writes to w an example flags file, in encoding, that lists
+// every option that f reads, each commented out and showing its default value
+// and help text.  It is intended as a complete template to start a flags file
+// from.  The options of each named set are written in their own section.  The
+// values of options with a secret tag are left empty.
+//
+// The simple encoding, which is the default, is read by SimpleDecoder:
+//
+//	# name = ""  # name of the widget
+//	# count = 42  # number of widgets
+//
+//	[server]
+//	# port = 8080  # port to listen on
+//
+// The json encoding uses comments, so it must be read with the jsonc encoding
+// (see the json package).  The yaml encoding uses nested maps for sections.
+// This package does not provide a YAML decoder; one must be registered with
+// RegisterEncoding to read it.
+func (f *Flags) WriteSkeleton(w io.Writer, encoding string) error {
+	if encoding == "" {
+		encoding = "simple"
+	}
+	write, ok := skeletonWriters[encoding]
+	if !ok {
+		var names []string
+		for name := range skeletonWriters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("cannot write skeleton as %q, must be one of %s", encoding, strings.Join(names, ", "))
+	}
+	f.lock()
+	sets := f.allSets()
+	f.unlock()
+
+	root := &skeletonSection{}
+	done := map[*getopt.Set]bool{}
+	optionInfosMu.Lock()
+	for _, set := range sets {
+		if done[set.Set] {
+			continue
+		}
+		done[set.Set] = true
+		s := root.section(set.Name)
+		set.VisitAll(func(o getopt.Option) {
+			switch o.Value().(type) {
+			case *Flags, *Help, *HelpAll, *DumpConfig:
+				return
+			}
+			name := o.LongName()
+			if name == "" {
+				name = o.ShortName()
+			}
+			info, ok := optionInfos[o]
+			if !ok {
+				info.def = o.String()
+			}
+			if isSecret(o) {
+				info.def = ""
+			}
+			s.entries = append(s.entries, skeletonEntry{
+				name:  name,
+				value: info.def,
+				help:  strings.Join(strings.Fields(info.help), " "),
+			})
+		})
+	}
+	optionInfosMu.Unlock()
+	return write(w, root)
+}
+
+// comment returns help as a trailing comment that starts with marker.
+func (e skeletonEntry) comment(marker string) string {
+	if e.help == "" {
+		return ""
+	}
+	return "  " + marker + " " + e.help
+}
+
+func writeSimpleSkeleton(w io.Writer, root *skeletonSection) error {
+	var err error
+	first := true
+	var write func(s *skeletonSection)
+	write = func(s *skeletonSection) {
+		if s.path != "" && len(s.entries) > 0 {
+			if !first {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "[%s]\n", s.path)
+		}
+		for _, e := range s.entries {
+			if _, err = fmt.Fprintf(w, "# %s = %s%s\n", e.name, simpleQuote(e.value), e.comment("#")); err != nil {
+				return
+			}
+			first = false
+		}
+		for _, c := range s.children {
+			write(c)
+		}
+	}
+	write(root)
+	return err
+}
+
+func writeJSONSkeleton(w io.Writer, root *skeletonSection) error {
+	var err error
+	var write func(s *skeletonSection, indent string)
+	write = func(s *skeletonSection, indent string) {
+		for _, e := range s.entries {
+			if _, err = fmt.Fprintf(w, "%s// %s: %s,%s\n", indent, jsonString(e.name), jsonString(e.value), e.comment("//")); err != nil {
+				return
+			}
+		}
+		for _, c := range s.children {
+			fmt.Fprintf(w, "%s%s: {\n", indent, jsonString(c.name))
+			write(c, indent+"\t")
+			fmt.Fprintf(w, "%s},\n", indent)
+		}
+	}
+	fmt.Fprintln(w, "{")
+	write(root, "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+func writeYAMLSkeleton(w io.Writer, root *skeletonSection) error {
+	var err error
+	var write func(s *skeletonSection, indent string)
+	write = func(s *skeletonSection, indent string) {
+		for _, e := range s.entries {
+			if _, err = fmt.Fprintf(w, "%s# %s: %s%s\n", indent, e.name, jsonString(e.value), e.comment("#")); err != nil {
+				return
+			}
+		}
+		for _, c := range s.children {
+			fmt.Fprintf(w, "%s%s:\n", indent, c.name)
+			write(c, indent+"  ")
+		}
+	}
+	write(root, "")
+	return err
+}
+
+// jsonString returns s as a JSON string, which is also a YAML double quoted
+// string.
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
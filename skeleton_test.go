@@ -0,0 +1,83 @@
+package options
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestWriteSkeleton(t *testing.T) {
+	opts := &struct {
+		Flags    Flags  `getopt:"--flags=PATH flags file"`
+		Name     string `getopt:"--name=NAME name of the widget"`
+		Count    int    `getopt:"--count -c=N number of widgets"`
+		Verbose  bool   `getopt:"-v be verbose"`
+		Password string `getopt:"--password=PASSWORD password" secret:""`
+	}{Count: 42, Password: "hunter2"}
+	server := &struct {
+		Port int    `getopt:"--port=PORT port to listen on"`
+		Host string `getopt:"--host=HOST"`
+	}{Port: 8080}
+	if err := RegisterSet("", opts, getopt.New()); err != nil {
+		t.Fatal(err)
+	}
+	set := getopt.New()
+	if err := RegisterSet("", server, set); err != nil {
+		t.Fatal(err)
+	}
+	opts.Flags.Sets = append(opts.Flags.Sets, Set{Name: "skeleton.server", Set: set})
+
+	for _, tt := range []struct {
+		encoding string
+		want     string
+	}{{
+		encoding: "",
+		want: `# count = 42  # number of widgets
+# name = ""  # name of the widget
+# password = ""  # password
+# v = false  # be verbose
+
+[skeleton.server]
+# host = ""  # unspecified
+# port = 8080  # port to listen on
+`,
+	}, {
+		encoding: "json",
+		want: `{
+	// "count": "42",  // number of widgets
+	// "name": "",  // name of the widget
+	// "password": "",  // password
+	// "v": "false",  // be verbose
+	"skeleton": {
+		"server": {
+			// "host": "",  // unspecified
+			// "port": "8080",  // port to listen on
+		},
+	},
+}
+`,
+	}, {
+		encoding: "yaml",
+		want: `# count: "42"  # number of widgets
+# name: ""  # name of the widget
+# password: ""  # password
+# v: "false"  # be verbose
+skeleton:
+  server:
+    # host: ""  # unspecified
+    # port: "8080"  # port to listen on
+`,
+	}} {
+		var buf bytes.Buffer
+		if err := opts.Flags.WriteSkeleton(&buf, tt.encoding); err != nil {
+			t.Fatalf("%q: %v", tt.encoding, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%q: got:\n%s\nwant:\n%s", tt.encoding, got, tt.want)
+		}
+	}
+	if err := opts.Flags.WriteSkeleton(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("did not get an error for an unknown encoding")
+	}
+}
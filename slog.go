@@ -0,0 +1,62 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+//go:build go1.21
+// +build go1.21
+
+package options
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// LogValue returns the options declared by i, a pointer to an options
+// structure, as a slog group value with an attribute for each option.  The
+// attributes are named by the option's name, as used in flags files.  The
+// values of options with a secret tag are masked.  Flags, Help, HelpAll, and
+// DumpConfig options are not included.  A service can log its configuration
+// at startup with:
+//
+//	slog.Info("starting", options.LogAttr("options", &myOptions))
+func LogValue(i interface{}) slog.Value {
+	specs, err := Inspect(i)
+	if err != nil {
+		return slog.StringValue(err.Error())
+	}
+	v := reflect.ValueOf(i).Elem()
+	attrs := make([]slog.Attr, 0, len(specs))
+	for _, s := range specs {
+		fv := v.FieldByName(s.Field)
+		var value slog.Value
+		switch p := fv.Addr().Interface().(type) {
+		case *Flags, *Help, *HelpAll, *DumpConfig:
+			continue
+		case fmt.Stringer:
+			value = slog.StringValue(p.String())
+		default:
+			value = slog.AnyValue(fv.Interface())
+		}
+		if s.Secret && !fv.IsZero() {
+			value = slog.StringValue(maskedValue)
+		}
+		attrs = append(attrs, slog.Attr{Key: s.Name(), Value: value})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogAttr returns a slog attribute named key whose value is LogValue(i).
+func LogAttr(key string, i interface{}) slog.Attr {
+	return slog.Attr{Key: key, Value: LogValue(i)}
+}
@@ -0,0 +1,44 @@
+//go:build go1.21
+// +build go1.21
+
+package options
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLogValue(t *testing.T) {
+	opts := &struct {
+		Help     Help          `getopt:"--help display help"`
+		Name     string        `getopt:"--name=NAME name of the widget"`
+		Count    int           `getopt:"--count=N number of widgets"`
+		Timeout  time.Duration `getopt:"--timeout how long to wait"`
+		Password string        `getopt:"--password=PASSWORD password" secret:""`
+	}{
+		Name:     "bob",
+		Count:    2,
+		Timeout:  time.Second,
+		Password: "hunter2",
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("starting", LogAttr("options", opts))
+	want := "level=INFO msg=starting options.name=bob options.count=2 options.timeout=1s options.password=********\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	if v := LogValue(42); v.Kind() != slog.KindString {
+		t.Errorf("got %v for an int, want an error string", v)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A State is the saved values of the options declared by a structure, see
+// Snapshot.
+type State struct {
+	t      reflect.Type
+	fields map[int]reflect.Value // field index to a copy of its value
+}
+
+// Snapshot returns the current values of the options declared by i, a pointer
+// to an options structure, so they can later be restored by Restore.  Values
+// are copied deeply: maps, slices, and pointers are duplicated so changes made
+// to i after the snapshot do not change the snapshot.  Flags fields,
+// non-exported fields, and fields whose getopt tag is "-" are not saved.
+//
+// Snapshot and Restore let a program, such as a REPL, apply a flags file, run,
+// and then roll the options back:
+//
+//	state, err := options.Snapshot(&myOptions)
+//	...
+//	myOptions.Flags.Set(path, nil)
+//	run()
+//	options.Restore(&myOptions, state)
+func Snapshot(i interface{}) (*State, error) {
+	v, err := structValue(i)
+	if err != nil {
+		return nil, err
+	}
+	s := &State{t: v.Type(), fields: map[int]reflect.Value{}}
+	for x := 0; x < v.NumField(); x++ {
+		if saved(v, x) {
+			s.fields[x] = deepCopy(v.Field(x))
+		}
+	}
+	return s, nil
+}
+
+// Restore sets the options declared by i to the values saved by Snapshot.  i
+// must be of the same type as the structure passed to Snapshot.  A State may
+// be restored any number of times.  Restore only changes the values of the
+// fields; it does not change which options getopt reports as seen.
+func Restore(i interface{}, s *State) error {
+	v, err := structValue(i)
+	if err != nil {
+		return err
+	}
+	if v.Type() != s.t {
+		return fmt.Errorf("cannot restore a snapshot of %v to %T", s.t, i)
+	}
+	for x, fv := range s.fields {
+		v.Field(x).Set(deepCopy(fv))
+	}
+	return nil
+}
+
+// Merge copies the values of the options declared by src into dst, which must
+// both be pointers to structures of the same type.  If onlySet is true then
+// only the options of src that were seen on the command line are copied,
+// which requires src to have been registered and parsed.  As with Snapshot,
+// values are copied deeply, and Flags fields, non-exported fields, and fields
+// whose getopt tag is "-" are not copied.  Merge lets a program combine a base
+// configuration with the options given for a single request:
+//
+//	opts := options.Dup(&base)
+//	options.Merge(opts, request, true)
+func Merge(dst, src interface{}, onlySet bool) error {
+	dv, err := structValue(dst)
+	if err != nil {
+		return err
+	}
+	sv, err := structValue(src)
+	if err != nil {
+		return err
+	}
+	if dv.Type() != sv.Type() {
+		return fmt.Errorf("cannot merge %T into %T", src, dst)
+	}
+	for x := 0; x < sv.NumField(); x++ {
+		if !saved(sv, x) {
+			continue
+		}
+		if onlySet {
+			if o := fieldOption(sv.Field(x)); o == nil || !o.Seen() {
+				continue
+			}
+		}
+		dv.Field(x).Set(deepCopy(sv.Field(x)))
+	}
+	return nil
+}
+
+// structValue returns the structure i points to.
+func structValue(i interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	return v.Elem(), nil
+}
+
+// saved reports if field x of the structure v is saved by Snapshot.
+func saved(v reflect.Value, x int) bool {
+	fv := v.Field(x)
+	if v.Type().Field(x).Tag.Get("getopt") == "-" || !fv.CanSet() {
+		return false
+	}
+	_, isFlags := fv.Addr().Interface().(*Flags)
+	return !isFlags
+}
+
+// deepCopy returns a copy of v that does not share any maps, slices, or
+// pointers with v.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for x := 0; x < v.Len(); x++ {
+			s.Index(x).Set(deepCopy(v.Index(x)))
+		}
+		return s
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(deepCopy(v.Elem()))
+		return p
+	case reflect.Struct, reflect.Array:
+		// Assigning the whole value copies non-exported fields, which
+		// cannot be set individually.
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		if v.Kind() == reflect.Array {
+			for x := 0; x < v.Len(); x++ {
+				c.Index(x).Set(deepCopy(v.Index(x)))
+			}
+			return c
+		}
+		for x := 0; x < v.NumField(); x++ {
+			if c.Field(x).CanSet() {
+				c.Field(x).Set(deepCopy(v.Field(x)))
+			}
+		}
+		return c
+	}
+	// v may be a field of a structure, so copy it.
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
@@ -0,0 +1,99 @@
+package options
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestSnapshot(t *testing.T) {
+	type snapOptions struct {
+		Name    string            `getopt:"--name=NAME name"`
+		List    []string          `getopt:"--list=ITEM items"`
+		Labels  map[string]string `getopt:"--label=KEY=VALUE labels"`
+		Count   *int              `getopt:"--count=N count"`
+		private int
+	}
+	opts := &snapOptions{
+		Name:    "bob",
+		List:    []string{"a"},
+		Labels:  map[string]string{"k": "v"},
+		private: 1,
+	}
+	want := &snapOptions{
+		Name:    "bob",
+		List:    []string{"a"},
+		Labels:  map[string]string{"k": "v"},
+		private: 2,
+	}
+	state, err := Snapshot(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		opts.Name = "fred"
+		opts.List[0] = "changed"
+		opts.List = append(opts.List, "b")
+		opts.Labels["k"] = "changed"
+		opts.Labels["new"] = "x"
+		opts.private = 2
+		opts.Count = new(int)
+		if err := Restore(opts, state); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(opts, want) {
+			t.Errorf("restore %d: got %+v, want %+v", n, opts, want)
+		}
+	}
+
+	if err := Restore(&struct{ Name string }{}, state); err == nil {
+		t.Error("Restore to a different type did not return an error")
+	}
+	if _, err := Snapshot(*opts); err == nil {
+		t.Error("Snapshot of a struct did not return an error")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	type mergeOptions struct {
+		Name  string   `getopt:"--name=NAME name"`
+		Count int      `getopt:"--count=N count"`
+		List  []string `getopt:"--list=ITEM items"`
+	}
+	base := &mergeOptions{Name: "base", Count: 1}
+	request := &mergeOptions{Name: "request", Count: 2}
+	set := getopt.New()
+	if err := RegisterSet("", request, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Getopt([]string{"test", "--list=a", "--count=3"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := &mergeOptions{}
+	*dst = *base
+	if err := Merge(dst, request, true); err != nil {
+		t.Fatal(err)
+	}
+	want := &mergeOptions{Name: "base", Count: 3, List: []string{"a"}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+	request.List[0] = "changed"
+	if dst.List[0] != "a" {
+		t.Errorf("Merge did not copy the list")
+	}
+
+	*dst = *base
+	if err := Merge(dst, request, false); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, request) {
+		t.Errorf("got %+v, want %+v", dst, request)
+	}
+
+	if err := Merge(dst, &struct{ Name string }{}, false); err == nil {
+		t.Error("Merge of different types did not return an error")
+	}
+}
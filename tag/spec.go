@@ -0,0 +1,233 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package tag
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Spec describes an option declared by a field of an options structure.  It
+// is the information used by the options packages to register the option, and
+// can be used by documentation generators, shell completion, and other tools
+// without parsing struct tags themselves.
+type Spec struct {
+	Tag                   // The names, parameter, and help of the option
+	Field    string       // Name of the struct field
+	Type     reflect.Type // Type of the struct field
+	Default  string       // Value of the field when inspected, or ""
+	Order    int          // Weight from the field's order tag, or 0
+	Advanced bool         // Only displayed in full help, see Advanced
+	Example  string       // Example arguments from the field's example tag
+	Secret   bool         // The value is masked when displayed, see Secret
+	Negate   bool         // A bool option also has a --no- form, see Negate
+}
+
+// Name returns the name of the option, its long name if it has one, otherwise
+// its short name.  The name does not include leading dashes.
+func (s *Spec) Name() string {
+	if s.Long != "" {
+		return s.Long
+	}
+	return string(s.Short)
+}
+
+// FieldSpec returns the Spec of the option declared by field, as used by the
+// github.com/pborman/options package.  If the field's tags do not name the
+// option then a field with a single letter name has that letter as its short
+// name, otherwise the lower case field name is the long name.  FieldSpec
+// returns nil, nil if the field does not declare an option, because it is not
+// exported or its getopt tag is "-".  The Default of the returned Spec is
+// empty.
+func FieldSpec(field reflect.StructField) (*Spec, error) {
+	return fieldSpec(field, Lookup)
+}
+
+// FlagFieldSpec is like FieldSpec but uses LookupFlag, as the
+// github.com/pborman/options/flags package does.  The option only has a long
+// or a short name.
+func FlagFieldSpec(field reflect.StructField) (*Spec, error) {
+	return fieldSpec(field, LookupFlag)
+}
+
+func fieldSpec(field reflect.StructField, lookup func(reflect.StructTag) (*Tag, error)) (*Spec, error) {
+	if field.PkgPath != "" || field.Tag.Get("getopt") == "-" {
+		return nil, nil
+	}
+	t, err := lookup(field.Tag)
+	if err != nil {
+		if te, ok := err.(*Error); ok {
+			te.Field = field.Name
+		}
+		return nil, err
+	}
+	s := &Spec{Field: field.Name, Type: field.Type}
+	if t != nil {
+		s.Tag = *t
+	}
+	if o, ok := field.Tag.Lookup("order"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(o))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid order tag: %q", field.Name, o)
+		}
+		s.Order = n
+	}
+	if s.Advanced, err = Advanced(field.Tag); err != nil {
+		return nil, fmt.Errorf("%s: %w", field.Name, err)
+	}
+	if s.Secret, err = Secret(field.Tag); err != nil {
+		return nil, fmt.Errorf("%s: %w", field.Name, err)
+	}
+	if s.Negate, err = Negate(field.Tag); err != nil {
+		return nil, fmt.Errorf("%s: %w", field.Name, err)
+	}
+	s.Example = field.Tag.Get("example")
+	if s.Long == "" && s.Short == 0 {
+		n := strings.ToLower(field.Name)
+		if len([]rune(n)) == 1 {
+			s.Short = []rune(n)[0]
+		} else {
+			s.Long = n
+		}
+	}
+	return s, nil
+}
+
+// Advanced reports if st marks its option as advanced with an advanced tag.
+// Advanced options are of interest to few users and are only displayed in the
+// full help of a program.  An advanced tag with an empty value is true,
+// otherwise its value must be a boolean as accepted by strconv.ParseBool.
+//
+//	Trace bool `getopt:"--trace trace all requests" advanced:""`
+func Advanced(st reflect.StructTag) (bool, error) {
+	return boolTag(st, "advanced")
+}
+
+// Secret reports if st marks its option as holding a secret, such as a
+// password, with a secret tag.  The values of secret options are masked when
+// they are displayed.  The secret tag has the same syntax as the advanced tag.
+//
+//	Password string `getopt:"--password=PASSWORD database password" secret:""`
+func Secret(st reflect.StructTag) (bool, error) {
+	return boolTag(st, "secret")
+}
+
+// Negate reports if st asks for a bool option to also be declared in a negated
+// form with a negate tag.  The negated form of --verbose is --no-verbose, which
+// sets the option to false.  The negate tag has the same syntax as the advanced
+// tag.
+//
+//	Color bool `getopt:"--color colorize output" negate:""`
+func Negate(st reflect.StructTag) (bool, error) {
+	return boolTag(st, "negate")
+}
+
+// boolTag returns the boolean value of the key tag in st.  It is false if st
+// does not have the tag and true if the tag is empty.
+func boolTag(st reflect.StructTag, key string) (bool, error) {
+	v, ok := st.Lookup(key)
+	if !ok {
+		return false, nil
+	}
+	if v == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s tag: %q", key, v)
+	}
+	return b, nil
+}
+
+// Inspect returns the Specs of the options declared by i, a pointer to an
+// options structure, sorted by their Order and otherwise in field order.
+// Fields without an order tag have an Order of 0, so a negative order moves an
+// option before them and a positive order moves it after them.  The Default of
+// each Spec is the current value of its field, unless the field holds its zero
+// value.
+func Inspect(i interface{}) ([]Spec, error) {
+	return inspect(i, FieldSpec)
+}
+
+// InspectFlags is like Inspect but uses FlagFieldSpec.
+func InspectFlags(i interface{}) ([]Spec, error) {
+	return inspect(i, FlagFieldSpec)
+}
+
+func inspect(i interface{}, fieldSpec func(reflect.StructField) (*Spec, error)) ([]Spec, error) {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a pointer to a struct", i)
+	}
+	v = v.Elem()
+	t := v.Type()
+	var specs []Spec
+	for i := 0; i < t.NumField(); i++ {
+		s, err := fieldSpec(t.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			continue
+		}
+		if fv := v.Field(i); !fv.IsZero() {
+			if sv, ok := fv.Addr().Interface().(fmt.Stringer); ok {
+				s.Default = sv.String()
+			} else {
+				s.Default = fmt.Sprint(fv.Interface())
+			}
+		}
+		specs = append(specs, *s)
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Order < specs[j].Order
+	})
+	return specs, nil
+}
+
+// Args returns the minimum and maximum number of positional arguments declared
+// by the args tag in st.  The args tag is either a single count, a range such
+// as "1-3", or a minimum followed by a dash, such as "1-", meaning there is no
+// maximum.  An empty args tag accepts any number of arguments.  A max of -1
+// means there is no maximum.  ok is false if st does not
+// have an args tag.
+//
+//	Files []string `getopt:"-" args:"1-2"`
+func Args(st reflect.StructTag) (min, max int, ok bool, err error) {
+	v, ok := st.Lookup("args")
+	if !ok {
+		return 0, -1, false, nil
+	}
+	if strings.TrimSpace(v) == "" {
+		return 0, -1, true, nil
+	}
+	bad := fmt.Errorf("invalid args tag: %q", v)
+	lo, hi := v, v
+	if x := strings.Index(v, "-"); x >= 0 {
+		lo, hi = v[:x], v[x+1:]
+	}
+	if min, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil || min < 0 {
+		return 0, -1, true, bad
+	}
+	if hi = strings.TrimSpace(hi); hi == "" {
+		return min, -1, true, nil
+	}
+	if max, err = strconv.Atoi(hi); err != nil || max < min {
+		return 0, -1, true, bad
+	}
+	return min, max, true, nil
+}
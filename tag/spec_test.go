@@ -0,0 +1,184 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package tag
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	opts := &struct {
+		Name    string        `getopt:"--name -n=NAME name of the widget"`
+		Count   int           `getopt:"--count=N"`
+		Timeout time.Duration `getopt:"--timeout how long to wait"`
+		V       bool
+		Verbose bool
+		Ignored int `getopt:"-"`
+		private int
+	}{
+		Name:    "bob",
+		Timeout: time.Second,
+	}
+	want := []Spec{
+		{Tag: Tag{Long: "name", Short: 'n', Param: "NAME", Help: "name of the widget"}, Field: "Name", Type: reflect.TypeOf(""), Default: "bob"},
+		{Tag: Tag{Long: "count", Param: "N"}, Field: "Count", Type: reflect.TypeOf(0)},
+		{Tag: Tag{Long: "timeout", Help: "how long to wait"}, Field: "Timeout", Type: reflect.TypeOf(time.Second), Default: "1s"},
+		{Tag: Tag{Short: 'v'}, Field: "V", Type: reflect.TypeOf(false)},
+		{Tag: Tag{Long: "verbose"}, Field: "Verbose", Type: reflect.TypeOf(false)},
+	}
+	got, err := Inspect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+	if name := got[3].Name(); name != "v" {
+		t.Errorf("got name %q, want v", name)
+	}
+
+	// The flags package only uses one name.
+	if _, err := InspectFlags(opts); err == nil {
+		t.Error("InspectFlags accepted two names")
+	}
+	got, err = InspectFlags(&struct {
+		Name string `getopt:"-n=NAME name of the widget"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Name() != "n" || got[0].Param != "NAME" {
+		t.Errorf("got %+v, want -n=NAME", got[0])
+	}
+
+	if _, err := Inspect(*opts); err == nil {
+		t.Error("Inspect of a struct did not return an error")
+	}
+	bad := &struct {
+		Bad int `getopt:"--bad=X" param:"Y"`
+	}{}
+	_, err = Inspect(bad)
+	if te, ok := err.(*Error); !ok || te.Field != "Bad" {
+		t.Errorf("got error %v, want a tag error for field Bad", err)
+	}
+
+	if _, err := Inspect(&struct {
+		Bad int `order:"first"`
+	}{}); err == nil {
+		t.Error("Inspect accepted an invalid order tag")
+	}
+}
+
+func TestInspectOrder(t *testing.T) {
+	got, err := Inspect(&struct {
+		C bool `order:"10"`
+		D bool
+		B bool `order:"-1"`
+		A bool
+		E bool `order:"10"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names string
+	for _, s := range got {
+		names += s.Name()
+	}
+	if names != "bdace" {
+		t.Errorf("got order %q, want %q", names, "bdace")
+	}
+}
+
+func TestAdvanced(t *testing.T) {
+	for _, tt := range []struct {
+		tag  reflect.StructTag
+		want bool
+		err  bool
+	}{
+		{tag: `getopt:"--trace"`},
+		{tag: `advanced:""`, want: true},
+		{tag: `advanced:"true"`, want: true},
+		{tag: `advanced:"false"`},
+		{tag: `advanced:"maybe"`, err: true},
+	} {
+		got, err := Advanced(tt.tag)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("%s: did not get an error", tt.tag)
+		case !tt.err && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.tag, err)
+		case got != tt.want:
+			t.Errorf("%s: got %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	got, err := Inspect(&struct {
+		Password string `getopt:"--password" secret:""`
+		Name     string `getopt:"--name"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[0].Secret || got[1].Secret {
+		t.Errorf("got secrets %v, %v, want true, false", got[0].Secret, got[1].Secret)
+	}
+	if _, err := Secret(`secret:"shh"`); err == nil {
+		t.Error("Secret accepted an invalid tag")
+	}
+}
+
+func TestNegate(t *testing.T) {
+	got, err := Inspect(&struct {
+		Color   bool `getopt:"--color" negate:""`
+		Verbose bool `getopt:"--verbose" negate:"false"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[0].Negate || got[1].Negate {
+		t.Errorf("got negate %v, %v, want true, false", got[0].Negate, got[1].Negate)
+	}
+	if _, err := Negate(`negate:"maybe"`); err == nil {
+		t.Error("Negate accepted an invalid tag")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	for _, tt := range []struct {
+		tag      reflect.StructTag
+		min, max int
+		ok, err  bool
+	}{
+		{tag: ``, min: 0, max: -1},
+		{tag: `args:""`, min: 0, max: -1, ok: true},
+		{tag: `args:"2"`, min: 2, max: 2, ok: true},
+		{tag: `args:"1-3"`, min: 1, max: 3, ok: true},
+		{tag: `args:"1-"`, min: 1, max: -1, ok: true},
+		{tag: `args:"3-1"`, max: -1, ok: true, err: true},
+		{tag: `args:"-1"`, max: -1, ok: true, err: true},
+		{tag: `args:"two"`, max: -1, ok: true, err: true},
+	} {
+		min, max, ok, err := Args(tt.tag)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v, want error %v", tt.tag, err, tt.err)
+		}
+		if min != tt.min || max != tt.max || ok != tt.ok {
+			t.Errorf("%s: got %d, %d, %v, want %d, %d, %v", tt.tag, min, max, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
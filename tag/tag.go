@@ -0,0 +1,343 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package tag parses the getopt struct tags used by the
+// github.com/pborman/options and github.com/pborman/options/flags packages.
+// It is exported so other tools, such as documentation generators and linters,
+// can parse tags exactly as the options packages do.
+//
+// The syntax of a tag is:
+//
+//	[--option[=PARAM]] [-o] [--] description
+//
+// See the documentation of github.com/pborman/options for a full description.
+//
+// The pieces of a getopt tag may also be declared by separate struct tags (see
+// Lookup).
+package tag
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Tag contains all the information extracted from a getopt tag.
+type Tag struct {
+	Long     string // Long name of the option without the leading --
+	Short    rune   // Short name of the option, or 0
+	Param    string // Parameter name (e.g., NAME)
+	Help     string // Description of the option
+	Optional bool   // The parameter is optional
+	Implicit string // Value used when an optional parameter is omitted
+}
+
+// An Error describes an invalid tag.  The options packages set Field to the
+// name of the struct field with the invalid tag.
+type Error struct {
+	Field  string // Name of the field, if known
+	Tag    string // The invalid tag
+	Reason string // Why Tag is invalid
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: getopt tag %s: %q", e.Field, e.Reason, e.Tag)
+	}
+	return fmt.Sprintf("getopt tag %s: %q", e.Reason, e.Tag)
+}
+
+// tagError returns an *Error for tag with the specified reason.
+func tagError(tag, reason string) error {
+	return &Error{Tag: tag, Reason: reason}
+}
+
+// String returns a printable representation of t (not a getopt tag).
+func (t *Tag) String() string {
+	parts := make([]string, 0, 6)
+	parts = append(parts, "{")
+	if t.Long != "" {
+		parts = append(parts, "--"+t.Long)
+	}
+	if t.Short != 0 {
+		parts = append(parts, "-"+string(t.Short))
+	}
+	if t.Optional {
+		parts = append(parts, "?")
+	}
+	if t.Param != "" {
+		parts = append(parts, "="+t.Param)
+	}
+	if t.Implicit != "" {
+		parts = append(parts, fmt.Sprintf("implicit:%q", t.Implicit))
+	}
+	if t.Help != "" {
+		parts = append(parts, fmt.Sprintf("%q", t.Help))
+	}
+	parts = append(parts, "}")
+	return strings.Join(parts, " ")
+}
+
+// ParseTag parses and returns tag as a Tag or returns an error.  nil, nil is
+// returned if tag is empty or consists only of white space.  A tag may declare
+// at most one long name and one single character short name.  A name followed
+// by a ? (e.g., --color?=WHEN) declares the parameter to be optional.
+func ParseTag(tag string) (*Tag, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, nil
+	}
+	next := tag
+	var t Tag
+	var arg, param string
+	var optional bool
+	for {
+		arg, param, next = NextOption(next)
+		if arg == "" || arg == "-" || arg == "--" {
+			if param != "" {
+				// Only happens with "--=FOO" or "-=FOO"
+				return nil, tagError(tag, "missing option name")
+			}
+			if t.Long == "" && t.Short == 0 {
+				if next != "" {
+					return nil, tagError(tag, "missing option name")
+				}
+				return nil, nil
+			}
+			t.Help = next
+			return &t, nil
+		}
+		if param != "" {
+			if t.Param != "" {
+				return nil, tagError(tag, "has multiple parameter names")
+			}
+			t.Param = param
+		}
+		if arg, optional = trimOptional(arg); optional {
+			if arg == "-" || arg == "--" {
+				return nil, tagError(tag, "missing option name")
+			}
+			t.Optional = true
+		}
+		switch ArgPrefix(arg) {
+		case "-":
+			if t.Short != 0 {
+				return nil, tagError(tag, "has too many short names")
+			}
+			for x, r := range arg[1:] {
+				if x != 0 {
+					return nil, tagError(tag, "has invalid short name")
+				}
+				t.Short = r
+			}
+		case "--":
+			if t.Long != "" {
+				return nil, tagError(tag, "has too many long names")
+			}
+			t.Long = arg[2:]
+		default:
+			return nil, tagError(tag, "must not start with ---")
+		}
+	}
+}
+
+// ParseFlagTag is like ParseTag but uses the syntax of the flags package.  A
+// flags tag declares a single name that may be prefixed by either - or --.  A
+// single character name is returned in Short, all other names are returned in
+// Long.
+func ParseFlagTag(tag string) (*Tag, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, nil
+	}
+	next := tag
+	var t Tag
+	var name, arg, param string
+	var optional bool
+	for {
+		arg, param, next = NextOption(next)
+		if arg == "" || arg == "-" || arg == "--" {
+			if param != "" {
+				// Only happens with "--=FOO" or "-=FOO"
+				return nil, tagError(tag, "missing option name")
+			}
+			if name == "" {
+				if next != "" {
+					return nil, tagError(tag, "missing option name")
+				}
+				return nil, nil
+			}
+			if len(name) == 1 {
+				t.Short = rune(name[0])
+			} else {
+				t.Long = name
+			}
+			t.Help = next
+			return &t, nil
+		}
+		if param != "" {
+			if t.Param != "" {
+				return nil, tagError(tag, "has multiple parameter names")
+			}
+			t.Param = param
+		}
+		if name != "" {
+			return nil, tagError(tag, "has too many names")
+		}
+		if arg, optional = trimOptional(arg); optional {
+			if arg == "-" || arg == "--" {
+				return nil, tagError(tag, "missing option name")
+			}
+			t.Optional = true
+		}
+		// Strip off the leading -- or -.
+		name = strings.TrimPrefix(arg[1:], "-")
+	}
+}
+
+// Lookup returns the Tag declared by the struct tag st.  The getopt key of st is
+// parsed by ParseTag.  The long, short, param, and help keys may be used in
+// place of, or in addition to, the getopt key:
+//
+//	Name string `long:"name" short:"n" param:"NAME" help:"name of the widget"`
+//
+// is the same as
+//
+//	Name string `getopt:"--name -n=NAME name of the widget"`
+//
+// Names are declared without leading dashes.  It is an error to declare a
+// piece of information in both the getopt key and its own key.  Lookup returns
+// nil, nil if st does not declare an option.  The returned Tag may not have a
+// long or short name if only the param and help keys were used.
+//
+// The implicit key declares the value used when an optional parameter is
+// omitted and implies the parameter is optional:
+//
+//	Color string `getopt:"--color=WHEN colorize output" implicit:"auto"`
+//
+// Lookup does not treat a getopt key of "-" specially.  It is up to the caller
+// to ignore the field.
+func Lookup(st reflect.StructTag) (*Tag, error) {
+	t, err := ParseTag(st.Get("getopt"))
+	if err != nil {
+		return nil, err
+	}
+	return merge(t, st)
+}
+
+// LookupFlag is like Lookup but uses ParseFlagTag to parse the getopt key.  As
+// with ParseFlagTag, only a single name may be declared.
+func LookupFlag(st reflect.StructTag) (*Tag, error) {
+	t, err := ParseFlagTag(st.Get("getopt"))
+	if err != nil {
+		return nil, err
+	}
+	if t, err = merge(t, st); err != nil {
+		return nil, err
+	}
+	if t != nil && t.Long != "" && t.Short != 0 {
+		return nil, tagError(string(st), "has too many names")
+	}
+	return t, nil
+}
+
+// merge adds the information in the long, short, param, help, and implicit
+// keys of st to t.  If t is nil and st has any of these keys then a new Tag is
+// returned.
+func merge(t *Tag, st reflect.StructTag) (*Tag, error) {
+	long, hasLong := st.Lookup("long")
+	short, hasShort := st.Lookup("short")
+	param, hasParam := st.Lookup("param")
+	help, hasHelp := st.Lookup("help")
+	implicit, hasImplicit := st.Lookup("implicit")
+	if !hasLong && !hasShort && !hasParam && !hasHelp && !hasImplicit {
+		return t, nil
+	}
+	if t == nil {
+		t = &Tag{}
+	}
+	if hasLong {
+		switch {
+		case t.Long != "":
+			return nil, tagError(string(st), "declares the long name twice")
+		case long == "", long[0] == '-', strings.ContainsAny(long, " ="):
+			return nil, tagError(string(st), fmt.Sprintf("has invalid long name %q", long))
+		}
+		t.Long = long
+	}
+	if hasShort {
+		if t.Short != 0 {
+			return nil, tagError(string(st), "declares the short name twice")
+		}
+		r, n := utf8.DecodeRuneInString(short)
+		if n == 0 || n != len(short) || r == '-' || r == ' ' || r == '=' {
+			return nil, tagError(string(st), fmt.Sprintf("has invalid short name %q", short))
+		}
+		t.Short = r
+	}
+	if hasParam {
+		if t.Param != "" {
+			return nil, tagError(string(st), "declares the parameter name twice")
+		}
+		t.Param = param
+	}
+	if hasHelp {
+		if t.Help != "" {
+			return nil, tagError(string(st), "declares the help twice")
+		}
+		t.Help = help
+	}
+	if hasImplicit {
+		t.Optional = true
+		t.Implicit = implicit
+	}
+	return t, nil
+}
+
+// trimOptional returns arg without a trailing ? and true if arg ended with a
+// ?.
+func trimOptional(arg string) (string, bool) {
+	// -? is the short name ?, as commonly used for help.
+	if arg != "-?" && strings.HasSuffix(arg, "?") {
+		return arg[:len(arg)-1], true
+	}
+	return arg, false
+}
+
+// NextOption returns the next option, optional parameter, and the rest of
+// the string parsed from s.  If the option is "" then s does not start with
+// an option (i.e., does not start with a -).
+func NextOption(s string) (option, param, rest string) {
+	if s == "" || s[0] != '-' {
+		return "", "", s
+	}
+	if x := strings.Index(s, " "); x >= 0 {
+		rest = strings.TrimSpace(s[x:])
+		s = s[:x]
+	}
+	if x := strings.Index(s, "="); x >= 0 {
+		return s[:x], s[x+1:], rest
+	}
+	return s, "", rest
+}
+
+// ArgPrefix returns the leading dashes in a.
+func ArgPrefix(a string) string {
+	for x, c := range a {
+		if c != '-' {
+			return a[:x]
+		}
+	}
+	return a
+}
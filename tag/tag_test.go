@@ -0,0 +1,515 @@
+// Copyright 2018 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package tag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		tag  *Tag
+		str  string
+		err  string
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name: "dash",
+			in:   "-",
+		},
+		{
+			name: "dash-dash",
+			in:   "--",
+		},
+		{
+			name: "long arg",
+			in:   "--option",
+			str:  "{ --option }",
+			tag: &Tag{
+				Long: "option",
+			},
+		},
+		{
+			name: "short arg",
+			in:   "-o",
+			str:  "{ -o }",
+			tag: &Tag{
+				Short: 'o',
+			},
+		},
+		{
+			name: "long help",
+			in:   "--option this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help1",
+			in:   "--option -- this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help2",
+			in:   "--option - this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help3",
+			in:   "--option -- -this is an option",
+			str:  `{ --option "-this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "-this is an option",
+			},
+		},
+		{
+			name: "long and short arg",
+			in:   "--option -o",
+			str:  "{ --option -o }",
+			tag: &Tag{
+				Long:  "option",
+				Short: 'o',
+			},
+		},
+		{
+			name: "short and long arg",
+			in:   "-o --option",
+			str:  "{ --option -o }",
+			tag: &Tag{
+				Long:  "option",
+				Short: 'o',
+			},
+		},
+		{
+			name: "long arg with param",
+			in:   "--option=PARAM",
+			str:  "{ --option =PARAM }",
+			tag: &Tag{
+				Long:  "option",
+				Param: "PARAM",
+			},
+		},
+		{
+			name: "short arg with param",
+			in:   "-o=PARAM",
+			str:  "{ -o =PARAM }",
+			tag: &Tag{
+				Short: 'o',
+				Param: "PARAM",
+			},
+		},
+		{
+			name: "everything",
+			in:   "--option=PARAM -o -- - this is help",
+			str:  `{ --option -o =PARAM "- this is help" }`,
+			tag: &Tag{
+				Long:  "option",
+				Short: 'o',
+				Param: "PARAM",
+				Help:  "- this is help",
+			},
+		},
+		{
+			name: "two longs",
+			in:   "--option1 --option2",
+			err:  "tag has too many long names",
+		},
+		{
+			name: "two shorts",
+			in:   "-a -b",
+			err:  "tag has too many short names",
+		},
+		{
+			name: "two parms",
+			in:   "--option=PARAM1 -o=PARAM2",
+			err:  "tag has multiple parameter names",
+		},
+		{
+			name: "missing option",
+			in:   "no option",
+			err:  "tag missing option name",
+		},
+		{
+			name: "long param only",
+			in:   "--=PARAM",
+			err:  "tag missing option name",
+		},
+		{
+			name: "short param only",
+			in:   "-=PARAM",
+			err:  "tag missing option name",
+		},
+		{
+			name: "two many dashes",
+			in:   "---option",
+			err:  "tag must not start with ---",
+		},
+		{
+			name: "optional long",
+			in:   "--color?=WHEN colorize",
+			str:  `{ --color ? =WHEN "colorize" }`,
+			tag: &Tag{
+				Long:     "color",
+				Param:    "WHEN",
+				Help:     "colorize",
+				Optional: true,
+			},
+		},
+		{
+			name: "optional short",
+			in:   "--color -c? colorize",
+			str:  `{ --color -c ? "colorize" }`,
+			tag: &Tag{
+				Long:     "color",
+				Short:    'c',
+				Help:     "colorize",
+				Optional: true,
+			},
+		},
+		{
+			name: "question mark short name",
+			in:   "--help -? display help",
+			str:  `{ --help -? "display help" }`,
+			tag: &Tag{
+				Long:  "help",
+				Short: '?',
+				Help:  "display help",
+			},
+		},
+		{
+			name: "optional only",
+			in:   "--?=WHEN",
+			err:  "tag missing option name",
+		},
+		{
+			name: "invalid short name",
+			in:   "-short",
+			err:  `getopt tag has invalid short name: "-short"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := ParseTag(tt.in)
+			switch {
+			case err == nil && tt.err != "":
+				t.Fatalf("did not get expected error %v", tt.err)
+			case err != nil && tt.err == "":
+				t.Fatalf("unexpected error %v", err)
+			case err == nil:
+			case !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(tag, tt.tag) {
+				t.Errorf("got %v, want %v", tag, tt.tag)
+			}
+			if tag != nil {
+				str := tag.String()
+				if str != tt.str {
+					t.Errorf("%s: got string %q, want %q", tt.name, str, tt.str)
+				}
+			}
+		})
+	}
+}
+
+func TestArgPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		in  string
+		out string
+	}{
+		{"a", ""},
+		{"-a", "-"},
+		{"--a", "--"},
+		{"", ""},
+		{"-", "-"},
+		{"--", "--"},
+	} {
+		if out := ArgPrefix(tt.in); out != tt.out {
+			t.Errorf("ArgPrefix(%q) got %q want %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestParseFlagTag(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		tag  *Tag
+		str  string
+		err  string
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name: "dash",
+			in:   "-",
+		},
+		{
+			name: "dash-dash",
+			in:   "--",
+		},
+		{
+			name: "long arg",
+			in:   "--option",
+			str:  "{ --option }",
+			tag: &Tag{
+				Long: "option",
+			},
+		},
+		{
+			name: "short arg",
+			in:   "-o",
+			str:  "{ -o }",
+			tag: &Tag{
+				Short: 'o',
+			},
+		},
+		{
+			name: "long help",
+			in:   "--option this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help1",
+			in:   "--option -- this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help2",
+			in:   "--option - this is an option",
+			str:  `{ --option "this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "this is an option",
+			},
+		},
+		{
+			name: "long help3",
+			in:   "--option -- -this is an option",
+			str:  `{ --option "-this is an option" }`,
+			tag: &Tag{
+				Long: "option",
+				Help: "-this is an option",
+			},
+		},
+		{
+			name: "long arg with param",
+			in:   "--option=PARAM",
+			str:  "{ --option =PARAM }",
+			tag: &Tag{
+				Long:  "option",
+				Param: "PARAM",
+			},
+		},
+		{
+			name: "everything",
+			in:   "--option=PARAM -- - this is help",
+			str:  `{ --option =PARAM "- this is help" }`,
+			tag: &Tag{
+				Long:  "option",
+				Param: "PARAM",
+				Help:  "- this is help",
+			},
+		},
+		{
+			name: "two longs",
+			in:   "--option1 --option2",
+			err:  "tag has too many names",
+		},
+		{
+			name: "two shorts",
+			in:   "-a -b",
+			err:  "tag has too many names",
+		},
+		{
+			name: "two parms",
+			in:   "--option=PARAM1 -o=PARAM2",
+			err:  "tag has multiple parameter names",
+		},
+		{
+			name: "missing option",
+			in:   "no option",
+			err:  "tag missing option name",
+		},
+		{
+			name: "optional",
+			in:   "--color?=WHEN colorize",
+			str:  `{ --color ? =WHEN "colorize" }`,
+			tag: &Tag{
+				Long:     "color",
+				Param:    "WHEN",
+				Help:     "colorize",
+				Optional: true,
+			},
+		},
+		{
+			name: "long param only",
+			in:   "--=PARAM",
+			err:  "tag missing option name",
+		},
+		{
+			name: "short param only",
+			in:   "-=PARAM",
+			err:  "tag missing option name",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := ParseFlagTag(tt.in)
+			switch {
+			case err == nil && tt.err != "":
+				t.Fatalf("did not get expected error %v", tt.err)
+			case err != nil && tt.err == "":
+				t.Fatalf("unexpected error %v", err)
+			case err == nil:
+			case !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(tag, tt.tag) {
+				t.Errorf("got %v, want %v", tag, tt.tag)
+			}
+			if tag != nil {
+				str := tag.String()
+				if str != tt.str {
+					t.Errorf("%s: got string %q, want %q", tt.name, str, tt.str)
+				}
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   reflect.StructTag
+		tag  *Tag
+		flag *Tag
+		err  string
+	}{
+		{
+			name: "nothing",
+			in:   `json:"name"`,
+		},
+		{
+			name: "getopt",
+			in:   `getopt:"--name=NAME the name"`,
+			tag:  &Tag{Long: "name", Param: "NAME", Help: "the name"},
+			flag: &Tag{Long: "name", Param: "NAME", Help: "the name"},
+		},
+		{
+			name: "separate",
+			in:   `long:"name" param:"NAME" help:"the name"`,
+			tag:  &Tag{Long: "name", Param: "NAME", Help: "the name"},
+			flag: &Tag{Long: "name", Param: "NAME", Help: "the name"},
+		},
+		{
+			name: "long and short",
+			in:   `long:"name" short:"n" help:"the name"`,
+			tag:  &Tag{Long: "name", Short: 'n', Help: "the name"},
+			err:  "too many names",
+		},
+		{
+			name: "combined",
+			in:   `getopt:"--name -n=NAME" help:"the name"`,
+			tag:  &Tag{Long: "name", Short: 'n', Param: "NAME", Help: "the name"},
+			err:  "too many names",
+		},
+		{
+			name: "help only",
+			in:   `help:"the name"`,
+			tag:  &Tag{Help: "the name"},
+			flag: &Tag{Help: "the name"},
+		},
+		{
+			name: "two helps",
+			in:   `getopt:"--name the name" help:"the name"`,
+			err:  "declares the help twice",
+		},
+		{
+			name: "two longs",
+			in:   `getopt:"--name" long:"name"`,
+			err:  "declares the long name twice",
+		},
+		{
+			name: "two params",
+			in:   `getopt:"--name=NAME" param:"NAME"`,
+			err:  "declares the parameter name twice",
+		},
+		{
+			name: "dashed long",
+			in:   `long:"--name"`,
+			err:  "invalid long name",
+		},
+		{
+			name: "long short",
+			in:   `short:"no"`,
+			err:  "invalid short name",
+		},
+		{
+			name: "implicit",
+			in:   `getopt:"--color=WHEN colorize" implicit:"auto"`,
+			tag:  &Tag{Long: "color", Param: "WHEN", Help: "colorize", Optional: true, Implicit: "auto"},
+			flag: &Tag{Long: "color", Param: "WHEN", Help: "colorize", Optional: true, Implicit: "auto"},
+		},
+		{
+			name: "bad getopt",
+			in:   `getopt:"name" help:"the name"`,
+			err:  "tag missing option name",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(fn string, tag *Tag, err error, want *Tag) {
+				t.Helper()
+				switch {
+				case err == nil && want == nil && tt.err != "":
+					t.Errorf("%s did not get expected error %v", fn, tt.err)
+				case err != nil && (want != nil || tt.err == ""):
+					t.Errorf("%s unexpected error %v", fn, err)
+				case err != nil && !strings.Contains(err.Error(), tt.err):
+					t.Errorf("%s got error %v, want %v", fn, err, tt.err)
+				case !reflect.DeepEqual(tag, want):
+					t.Errorf("%s got %v, want %v", fn, tag, want)
+				}
+			}
+			tag, err := Lookup(tt.in)
+			check("Lookup", tag, err, tt.tag)
+			tag, err = LookupFlag(tt.in)
+			check("LookupFlag", tag, err, tt.flag)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package testutil provides helpers for testing programs that declare their
+// options with the github.com/pborman/options package.  Each helper uses a new
+// getopt.Set so tests do not need to save and restore getopt.CommandLine:
+//
+//	func TestCount(t *testing.T) {
+//		opts := &struct {
+//			Count int `getopt:"--count -c=COUNT number of widgets"`
+//		}{}
+//		args := testutil.MustParse(t, opts, "-c", "3", "file")
+//		...
+//	}
+package testutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options"
+)
+
+// Program is the program name used by MustParse and AssertUsage.
+const Program = "test"
+
+// MustParse registers i, a pointer to an options structure, with a new
+// getopt.Set and parses args, which do not include the program name, with
+// that set.  The remaining arguments are returned.  t.Fatal is called if i
+// cannot be registered or args cannot be parsed.
+func MustParse(t testing.TB, i interface{}, args ...string) []string {
+	t.Helper()
+	set := newSet(t, i)
+	if err := set.Getopt(append([]string{Program}, args...), nil); err != nil {
+		t.Fatalf("parsing %q: %v", args, err)
+	}
+	return set.Args()
+}
+
+// AssertUsage registers i, a pointer to an options structure, with a new
+// getopt.Set and reports an error with t if the usage message of the set is
+// not golden.  The program name in the usage message is Program.
+func AssertUsage(t testing.TB, i interface{}, golden string) {
+	t.Helper()
+	var buf bytes.Buffer
+	newSet(t, i).PrintUsage(&buf)
+	if got := buf.String(); got != golden {
+		t.Errorf("got usage:\n%s\nwant:\n%s", got, golden)
+	}
+}
+
+// newSet returns a new getopt.Set with i registered.
+func newSet(t testing.TB, i interface{}) *getopt.Set {
+	t.Helper()
+	set := getopt.New()
+	set.SetProgram(Program)
+	if err := options.RegisterSet("", i, set); err != nil {
+		t.Fatalf("registering %T: %v", i, err)
+	}
+	return set
+}
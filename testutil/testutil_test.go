@@ -0,0 +1,77 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options"
+)
+
+// recorder is a testing.TB that records failures rather than failing.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+type testOptions struct {
+	Name  string `getopt:"--name=NAME name of the widget"`
+	Count int    `getopt:"--count -c=COUNT number of widgets"`
+}
+
+func TestMustParse(t *testing.T) {
+	opts := &testOptions{}
+	args := MustParse(t, opts, "--name=bob", "-c", "3", "file")
+	if opts.Name != "bob" || opts.Count != 3 {
+		t.Errorf("got %+v", opts)
+	}
+	if want := []string{"file"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("got args %q, want %q", args, want)
+	}
+}
+
+func TestAssertUsage(t *testing.T) {
+	set := getopt.New()
+	set.SetProgram(Program)
+	if err := options.RegisterSet("", &testOptions{}, set); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	set.PrintUsage(&buf)
+	golden := buf.String()
+
+	AssertUsage(t, &testOptions{}, golden)
+
+	r := &recorder{}
+	AssertUsage(r, &testOptions{}, golden+"extra\n")
+	if !r.failed {
+		t.Errorf("AssertUsage did not report a mismatch")
+	}
+}
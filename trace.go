@@ -0,0 +1,67 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	traceMu   sync.Mutex
+	traceOnce sync.Once
+	traceW    io.Writer // where to write traces, or nil
+)
+
+// SetTrace causes a trace of how flags files are applied to be written to w:
+// the files that are read, the options that are set from them, the options
+// that are not set because they were set on the command line, and unknown
+// options.  Tracing is disabled if w is nil.  If SetTrace is not called then
+// tracing is written to standard error when the OPTIONS_DEBUG environment
+// variable is set to a non-empty value.
+func SetTrace(w io.Writer) {
+	traceOnce.Do(func() {})
+	traceMu.Lock()
+	traceW = w
+	traceMu.Unlock()
+}
+
+// initTrace enables tracing to standard error if OPTIONS_DEBUG is set.
+func initTrace() {
+	if os.Getenv("OPTIONS_DEBUG") != "" {
+		traceW = os.Stderr
+	}
+}
+
+// tracing reports if there is a trace writer.  It is used to avoid building the
+// arguments to tracef, which allocates, when tracing is disabled.
+func tracing() bool {
+	traceOnce.Do(initTrace)
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return traceW != nil
+}
+
+// tracef writes a line formatted with format and args to the trace writer, if
+// there is one.
+func tracef(format string, args ...interface{}) {
+	traceOnce.Do(initTrace)
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceW != nil {
+		fmt.Fprintf(traceW, "options: "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,54 @@
+package options
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pborman/getopt/v2"
+)
+
+func TestSetTrace(t *testing.T) {
+	var buf bytes.Buffer
+	SetTrace(&buf)
+	defer SetTrace(nil)
+
+	path := filepath.Join(t.TempDir(), "flags")
+	if err := ioutil.WriteFile(path, []byte("name = bob\ncount = 2\nkey = secret\nextra = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &struct {
+		Flags Flags  `getopt:"--flags=PATH flags file" flags:"ignore-unknown"`
+		Name  string `getopt:"--name=NAME name of the widget"`
+		Count int    `getopt:"--count=N number of widgets"`
+		Key   string `getopt:"--key=KEY api key" secret:""`
+	}{}
+	set := getopt.New()
+	if err := RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SubParse(set, []string{"test", "--count=3", "--flags", path}); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"options: " + path + ": read\n",
+		`options: ` + path + `: --name set to ["bob"]` + "\n",
+		"options: " + path + ": --count not set, it was set on the command line\n",
+		"options: " + path + ": --key set to a secret\n",
+		"options: " + path + ": unknown option --extra\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, got)
+		}
+	}
+
+	buf.Reset()
+	SetTrace(nil)
+	tracef("not traced")
+	if buf.Len() != 0 {
+		t.Errorf("traced after SetTrace(nil): %q", buf.String())
+	}
+}
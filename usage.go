@@ -0,0 +1,153 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/tag"
+)
+
+// ANSI escape sequences used by WriteUsage.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiParam = "\x1b[36m" // cyan
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// ColorUsage returns a usage function, for use with SetUsage, that writes the
+// usage of program, with the options declared by i, to standard error.  The
+// usage is in color (option names in bold, parameters in cyan, and defaults
+// dimmed) unless standard error is not a terminal, the NO_COLOR environment
+// variable is set, or TERM is dumb.
+//
+//	options.SetUsage(options.ColorUsage("widget", "[FILE ...]", &opts))
+func ColorUsage(program, parameters string, i interface{}) func() {
+	return func() {
+		if err := WriteUsage(os.Stderr, program, parameters, i, useColor(os.Stderr)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// useColor reports if output to f should be in color.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// WriteUsage writes the usage of program, with the options declared by i and
+// the positional parameters, to w.  The options are displayed much as
+// getopt.PrintUsage displays them, followed by their default values, but in
+// the order they are declared in rather than sorted by name.  A field's order
+// tag, an integer weight, moves its option: options are sorted by weight and
+// fields without an order tag have a weight of 0.  If color is true then ANSI
+// escape sequences are used to highlight the option names, parameters, and
+// defaults.  The defaults of options with a secret tag are not displayed.
+// Examples set by SetExamples, and then those from example tags, are written
+// in an Examples section.
+//
+//	type options struct {
+//		Verbose bool   `getopt:"-v be verbose" order:"10"`
+//		Name    string `getopt:"--name=NAME name of the widget"`
+//	}
+func WriteUsage(w io.Writer, program, parameters string, i interface{}, color bool) error {
+	specs, err := Inspect(i)
+	if err != nil {
+		return err
+	}
+	paint := func(code, s string) string {
+		if !color || s == "" {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Usage: %s", program)
+	if len(specs) > 0 {
+		buf.WriteString(" [options]")
+	}
+	if parameters != "" {
+		buf.WriteString(" " + parameters)
+	}
+	buf.WriteString("\n")
+	for _, s := range specs {
+		if s.Secret {
+			s.Default = ""
+		}
+		names, param := usageNames(&s)
+		width := len(names) + len(param)
+		line := " " + paint(ansiBold, names) + paint(ansiParam, param)
+		if s.Help != "" || s.Default != "" {
+			pad := getopt.HelpColumn - width - 1
+			if pad < 2 {
+				line += "\n" + strings.Repeat(" ", getopt.HelpColumn)
+			} else {
+				line += strings.Repeat(" ", pad)
+			}
+			line += s.Help
+			if s.Default != "" {
+				if s.Help != "" {
+					line += " "
+				}
+				line += paint(ansiDim, "["+s.Default+"]")
+			}
+		}
+		buf.WriteString(line + "\n")
+	}
+	examples := lookupExamples(reflect.TypeOf(i).Elem())
+	for _, s := range specs {
+		if s.Example != "" {
+			examples = append(examples, s.Example)
+		}
+	}
+	writeExamples(&buf, program, examples)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// usageNames returns the names of the option described by s, as displayed in
+// a usage message, and its parameter, if it has one.
+func usageNames(s *tag.Spec) (names, param string) {
+	switch {
+	case s.Short != 0 && s.Long != "":
+		names = "-" + string(s.Short) + ", --" + s.Long
+	case s.Short != 0:
+		names = "-" + string(s.Short)
+	default:
+		names = "    --" + s.Long
+	}
+	if s.Type.Kind() == reflect.Bool {
+		return names, ""
+	}
+	p := s.Param
+	if p == "" {
+		p = "value"
+	}
+	if s.Long != "" {
+		return names, "=" + p
+	}
+	return names, " " + p
+}
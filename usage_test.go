@@ -0,0 +1,122 @@
+package options
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteUsage(t *testing.T) {
+	opts := &struct {
+		Name    string `getopt:"--name -n=NAME name of the widget"`
+		Verbose bool   `getopt:"-v be verbose"`
+		Count   int    `getopt:"--count number of widgets"`
+	}{Name: "bob"}
+	var buf bytes.Buffer
+	if err := WriteUsage(&buf, "prog", "[FILE ...]", opts, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `Usage: prog [options] [FILE ...]
+ -n, --name=NAME    name of the widget [bob]
+ -v                 be verbose
+     --count=value  number of widgets
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteUsage(&buf, "prog", "", opts, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		ansiBold + "-n, --name" + ansiReset,
+		ansiParam + "=NAME" + ansiReset,
+		ansiDim + "[bob]" + ansiReset,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("missing %q in:\n%s", s, buf.String())
+		}
+	}
+
+	if err := WriteUsage(&buf, "prog", "", 42, false); err == nil {
+		t.Errorf("did not get an error for a non-structure")
+	}
+}
+
+func TestWriteUsageOrder(t *testing.T) {
+	opts := &struct {
+		Zebra bool `getopt:"--zebra"`
+		Apple bool `getopt:"--apple" order:"1"`
+		Mango bool `getopt:"--mango"`
+	}{}
+	var buf bytes.Buffer
+	if err := WriteUsage(&buf, "prog", "", opts, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `Usage: prog [options]
+     --zebra
+     --mango
+     --apple
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteUsageExamples(t *testing.T) {
+	type exampleOptions struct {
+		Name    string `getopt:"--name=NAME name of the widget" example:"--name=bob"`
+		Verbose bool   `getopt:"-v be verbose"`
+	}
+	opts := &exampleOptions{}
+	if err := SetExamples(opts, "-v FILE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetExamples(42); err == nil {
+		t.Errorf("SetExamples did not return an error for an int")
+	}
+	var buf bytes.Buffer
+	if err := WriteUsage(&buf, "prog", "", opts, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `Usage: prog [options]
+     --name=NAME    name of the widget
+ -v                 be verbose
+
+Examples:
+  prog -v FILE
+  prog --name=bob
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	nc, nok := os.LookupEnv("NO_COLOR")
+	defer func() {
+		if nok {
+			os.Setenv("NO_COLOR", nc)
+		} else {
+			os.Unsetenv("NO_COLOR")
+		}
+	}()
+	f, err := ioutil.TempFile("", "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	os.Unsetenv("NO_COLOR")
+	if useColor(f) {
+		t.Errorf("useColor returned true for a regular file")
+	}
+	os.Setenv("NO_COLOR", "1")
+	if useColor(os.Stderr) {
+		t.Errorf("useColor returned true with NO_COLOR set")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+	"github.com/pborman/options/internal/optvalue"
+)
+
+// getopt does not support maps or all the spellings of a boolean value that
+// are accepted by this package.  The types below adapt bool, map[string]string,
+// map[string]int, and map[string]bool to the getopt.Value interface.  Each
+// use of the option adds a single key=value pair to the map, e.g.:
+//
+//	--limit cpu=4 --limit mem=2048
+
+// A genericOption is an Option, which is only available when built with Go
+// 1.18 or later.
+type genericOption interface {
+	getopt.Value
+	valuePointer() interface{} // value that sets the Value field
+	isFlag() bool              // the Value field is a bool
+	setSource(Source)
+}
+
+// optionValue returns p as a getopt.Value if p is a pointer to a type that is
+// supported by this package but not by getopt.  Otherwise p is returned.
+func optionValue(p interface{}) interface{} {
+	switch p := p.(type) {
+	case *[]string:
+		return (*listValue)(p)
+	case *map[string]string:
+		return (*stringMap)(p)
+	case *map[string]int:
+		return (*intMap)(p)
+	case *map[string]bool:
+		return (*boolMap)(p)
+	case *bool:
+		return (*boolValue)(p)
+	case getopt.Value:
+		return p
+	case encoding.TextUnmarshaler:
+		return textValue{optvalue.Text{P: p}}
+	}
+	return p
+}
+
+// A listValue is a []string option.  As with getopt's own []string option,
+// each value is a comma separated list that is appended to the list, and the
+// default value is discarded the first time the option is seen.  A listValue
+// can also be given several values at once, as when a key is repeated in a
+// flags file.
+type listValue []string
+
+func (l *listValue) Set(value string, opt getopt.Option) error {
+	return l.set(opt, []string{value})
+}
+
+// set appends the comma separated lists in values to l, first discarding the
+// default value if opt has not been seen more than once.
+func (l *listValue) set(opt getopt.Option, values []string) error {
+	if opt.Count() <= 1 {
+		*l = nil
+	}
+	for _, v := range values {
+		*l = append(*l, strings.Split(v, ",")...)
+	}
+	return nil
+}
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+type stringMap optvalue.StringMap
+
+func (m *stringMap) Set(s string, _ getopt.Option) error {
+	return (*optvalue.StringMap)(m).Set(s)
+}
+
+func (m *stringMap) String() string { return (*optvalue.StringMap)(m).String() }
+
+type intMap optvalue.IntMap
+
+func (m *intMap) Set(s string, _ getopt.Option) error {
+	return (*optvalue.IntMap)(m).Set(s)
+}
+
+func (m *intMap) String() string { return (*optvalue.IntMap)(m).String() }
+
+// A boolMap sets a key to true if no value is provided (e.g., --feature=fast).
+type boolMap optvalue.BoolMap
+
+func (m *boolMap) Set(s string, _ getopt.Option) error {
+	return (*optvalue.BoolMap)(m).Set(s)
+}
+
+func (m *boolMap) String() string { return (*optvalue.BoolMap)(m).String() }
+
+// A FileMode is an os.FileMode that is set and displayed in octal, as with
+// chmod(1).  The value may be written with or without a leading 0 or 0o, e.g.,
+// 755, 0755, or 0o755.  Only the permission bits and the setuid, setgid, and
+// sticky bits may be set.
+//
+//	Mode options.FileMode `getopt:"--mode=MODE permissions of new files"`
+type FileMode os.FileMode
+
+// Unix mode bits for the special os.FileMode bits.
+const (
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+)
+
+// Set implements getopt.Value.
+func (m *FileMode) Set(value string, _ getopt.Option) error {
+	s := value
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'o' || s[1] == 'O') {
+		s = s[2:]
+	}
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v&^07777 != 0 {
+		return fmt.Errorf("invalid file mode: %s", value)
+	}
+	mode := os.FileMode(v) & os.ModePerm
+	if v&modeSetuid != 0 {
+		mode |= os.ModeSetuid
+	}
+	if v&modeSetgid != 0 {
+		mode |= os.ModeSetgid
+	}
+	if v&modeSticky != 0 {
+		mode |= os.ModeSticky
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// String returns m in octal with a leading 0 (e.g., 0755).
+func (m *FileMode) String() string {
+	mode := os.FileMode(*m)
+	v := uint32(mode & os.ModePerm)
+	if mode&os.ModeSetuid != 0 {
+		v |= modeSetuid
+	}
+	if mode&os.ModeSetgid != 0 {
+		v |= modeSetgid
+	}
+	if mode&os.ModeSticky != 0 {
+		v |= modeSticky
+	}
+	return fmt.Sprintf("%#o", v)
+}
+
+// A boolValue is a bool that accepts the values accepted by optvalue.ParseBool.  An
+// empty value, as used when a flag is seen on the command line, sets the value
+// to true.
+type boolValue bool
+
+func (b *boolValue) Set(value string, _ getopt.Option) error {
+	if value == "" {
+		*b = true
+		return nil
+	}
+	v, err := optvalue.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*b = boolValue(v)
+	return nil
+}
+
+func (b *boolValue) String() string { return strconv.FormatBool(bool(*b)) }
+
+// A unitsValue is an integer field that accepts the SI or IEC suffixes
+// declared by the units struct tag, e.g., 10k or 2M.
+type unitsValue struct {
+	optvalue.Units
+}
+
+func (u *unitsValue) Set(value string, _ getopt.Option) error {
+	if err := u.Units.Set(value); err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return fmt.Errorf("value out of range: %s", value)
+		}
+		return fmt.Errorf("not a valid number: %s", value)
+	}
+	return nil
+}
+
+// A textValue adapts an encoding.TextUnmarshaler, such as a uuid.UUID or a
+// net.IP, to the getopt.Value interface.  The value is displayed using
+// MarshalText if it is also an encoding.TextMarshaler.
+type textValue struct {
+	optvalue.Text
+}
+
+func (t textValue) Set(value string, _ getopt.Option) error { return t.Text.Set(value) }
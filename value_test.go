@@ -18,10 +18,12 @@ package options_test
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/pborman/getopt/v2"
 	"github.com/pborman/options"
 )
@@ -150,3 +152,63 @@ func TestUserType(t *testing.T) {
 		})
 	}
 }
+
+func TestFileMode(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		mode os.FileMode
+		out  string
+		err  bool
+	}{
+		{in: "755", mode: 0755, out: "0755"},
+		{in: "0644", mode: 0644, out: "0644"},
+		{in: "0o600", mode: 0600, out: "0600"},
+		{in: "0", mode: 0, out: "0"},
+		{in: "4755", mode: os.ModeSetuid | 0755, out: "04755"},
+		{in: "1777", mode: os.ModeSticky | 0777, out: "01777"},
+		{in: "0x1f", err: true},
+		{in: "789", err: true},
+		{in: "17777", err: true},
+		{in: "", err: true},
+	} {
+		var m options.FileMode
+		err := m.Set(tt.in, nil)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: did not get an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if os.FileMode(m) != tt.mode {
+			t.Errorf("%q: got mode %v, want %v", tt.in, os.FileMode(m), tt.mode)
+		}
+		if s := m.String(); s != tt.out {
+			t.Errorf("%q: got string %q, want %q", tt.in, s, tt.out)
+		}
+	}
+}
+
+func TestUUIDOption(t *testing.T) {
+	opts := &struct {
+		ID uuid.UUID `getopt:"--id=UUID instance id"`
+	}{}
+	set := getopt.New()
+	if err := options.RegisterSet("", opts, set); err != nil {
+		t.Fatal(err)
+	}
+	id := uuid.New()
+	if err := set.Getopt([]string{"test", "--id", id.String()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opts.ID != id {
+		t.Errorf("got id %v, want %v", opts.ID, id)
+	}
+	err := set.Getopt([]string{"test", "--id", "not-a-uuid"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "not-a-uuid"`) {
+		t.Errorf("got error %v, want invalid value", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// Package viper lets a github.com/pborman/options Flags take its values from a
+// viper configuration (github.com/spf13/viper), with viper's own file,
+// environment, and remote machinery, while the options package remains the
+// authority on option definitions and on precedence.  Values from viper never
+// override options set on the command line.
+//
+// This package does not import viper.  Any value with an AllSettings method,
+// such as a *viper.Viper, can be used:
+//
+//	import (
+//		"github.com/pborman/options"
+//		optviper "github.com/pborman/options/viper"
+//		"github.com/spf13/viper"
+//	)
+//
+//	v := viper.New()
+//	v.SetConfigName("app")
+//	v.AddConfigPath("/etc/app")
+//	if err := v.ReadInConfig(); err != nil {
+//		...
+//	}
+//	flags := options.NewFlags("flags")
+//	if err := optviper.Set(flags, v); err != nil {
+//		...
+//	}
+//
+// Nested viper keys, such as server.port, set options in the Set named server.
+package viper
+
+import (
+	"github.com/pborman/options"
+)
+
+// Settings is implemented by *viper.Viper.
+type Settings interface {
+	AllSettings() map[string]interface{}
+}
+
+// Name is the name used in place of a file name for values from viper.
+const Name = "viper"
+
+// Schema describes how viper names options, see options.Flags.CheckSchema.
+// Viper converts all keys to lower case and nests dotted keys.
+var Schema = options.Schema{NestedNames: true, LowerCase: true}
+
+// Set sets the options of f from the values in v.  See options.Flags.SetMap.
+func Set(f *options.Flags, v Settings) error {
+	return f.SetMap(Name, v.AllSettings())
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package viper
+
+import (
+	"testing"
+
+	getopt "github.com/pborman/getopt/v2"
+	"github.com/pborman/options"
+)
+
+// settings implements Settings as *viper.Viper does.
+type settings map[string]interface{}
+
+func (s settings) AllSettings() map[string]interface{} { return s }
+
+func TestSet(t *testing.T) {
+	getopt.CommandLine = getopt.New()
+	name := "fred"
+	count := 0
+	getopt.FlagLong(&name, "name", 'n')
+	getopt.FlagLong(&count, "count", 'c')
+	s2 := getopt.New()
+	port := 0
+	s2.FlagLong(&port, "port", 'p')
+
+	f := options.NewFlags("flags")
+	f.Sets = append(f.Sets, options.Set{Name: "server", Set: s2})
+	err := Set(f, settings{
+		"name":   "bob",
+		"count":  42,
+		"server": map[string]interface{}{"port": 8080},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" || count != 42 || port != 8080 {
+		t.Errorf("got name %q, count %d, port %d, want bob, 42, 8080", name, count, port)
+	}
+	if f.String() != Name {
+		t.Errorf("got path %q, want %q", f.String(), Name)
+	}
+}
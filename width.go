@@ -0,0 +1,55 @@
+// Copyright 2023 Paul Borman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package options
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// defaultDisplayWidth is used when the width of the terminal cannot be
+// determined.
+const defaultDisplayWidth = 80
+
+var (
+	widthOnce       sync.Once
+	displayWidthSet bool // set by SetDisplayWidth
+)
+
+// autoDisplayWidth sets getopt.DisplayWidth to the width of the terminal the
+// first time options are parsed, unless SetDisplayWidth has been called.
+func autoDisplayWidth() {
+	widthOnce.Do(func() {
+		if !displayWidthSet {
+			getopt.DisplayWidth = terminalWidth()
+		}
+	})
+}
+
+// terminalWidth returns the width of the terminal, as specified by the
+// COLUMNS environment variable or, if not set, as reported by the terminal
+// connected to standard error.  It returns defaultDisplayWidth if the width
+// cannot be determined.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if n := ttyWidth(os.Stderr); n > 0 {
+		return n
+	}
+	return defaultDisplayWidth
+}
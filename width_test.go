@@ -0,0 +1,37 @@
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTerminalWidth(t *testing.T) {
+	cols, ok := os.LookupEnv("COLUMNS")
+	defer func() {
+		if ok {
+			os.Setenv("COLUMNS", cols)
+		} else {
+			os.Unsetenv("COLUMNS")
+		}
+	}()
+
+	os.Setenv("COLUMNS", "123")
+	if w := terminalWidth(); w != 123 {
+		t.Errorf("got width %d, want 123", w)
+	}
+	os.Setenv("COLUMNS", "wide")
+	if w := terminalWidth(); w <= 0 {
+		t.Errorf("got width %d with an invalid COLUMNS", w)
+	}
+
+	f, err := ioutil.TempFile("", "width")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if w := ttyWidth(f); w != 0 {
+		t.Errorf("got width %d for a regular file, want 0", w)
+	}
+}